@@ -0,0 +1,323 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DerivedAgeFile is the data file with the version of Unicode that
+// added each character.
+const DerivedAgeFile = "DerivedAge.txt"
+
+// ReadAges sets the Age property of the records from DerivedAge.txt,
+// as in "9.0".
+func (u *UCD) ReadAges(r io.Reader) error {
+	return ScanProperties(r, func(first, last rune, fields []string) error {
+		if len(fields) == 0 {
+			return &ParseError{Field: "age", Err: errors.New("missing")}
+		}
+		u.update(first, last, func(rec *Record) { rec.Age = fields[0] })
+		return nil
+	})
+}
+
+// Age returns the version of Unicode that added char, as in "9.0",
+// or "" if char is unassigned or the ages were not read.
+func (u *UCD) Age(char rune) string {
+	rec, _ := u.Lookup(char)
+	return rec.Age
+}
+
+// TextAge returns the newest Age of the characters of s, which is the
+// version of Unicode s needs, or "" if any of them has no age.
+func (u *UCD) TextAge(s string) string {
+	newest := unicodeVersion{}
+	for _, char := range s {
+		version, err := parseVersion(u.Age(char))
+		if err != nil {
+			return ""
+		}
+		if version.compare(newest) > 0 {
+			newest = version
+		}
+	}
+	if newest == (unicodeVersion{}) {
+		return ""
+	}
+	return newest.String()
+}
+
+// unicodeVersion is a version of Unicode as ages give it: the major
+// and minor numbers.
+type unicodeVersion [2]int
+
+// parseVersion parses a version such as "9.0", "9" or "15.1.0",
+// whose update number is ignored.
+func parseVersion(s string) (unicodeVersion, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return unicodeVersion{}, fmt.Errorf("invalid Unicode version %q", s)
+	}
+	var version unicodeVersion
+	for i := 0; i < len(parts) && i < 2; i++ {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return unicodeVersion{}, fmt.Errorf("invalid Unicode version %q", s)
+		}
+		version[i] = n
+	}
+	return version, nil
+}
+
+func (v unicodeVersion) String() string {
+	return fmt.Sprintf("%d.%d", v[0], v[1])
+}
+
+// compare returns -1, 0 or 1 as v is older than, the same as or
+// newer than w.
+func (v unicodeVersion) compare(w unicodeVersion) int {
+	for i := range v {
+		switch {
+		case v[i] < w[i]:
+			return -1
+		case v[i] > w[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// ageFilter is an age term of a query, such as "age:<=9.0", which
+// matches the characters added in or before Unicode 9.0. Without an
+// operator, as in "age:15.1", it matches those added in that version.
+type ageFilter struct {
+	op      string // "<", "<=", "=", ">=" or ">"
+	version unicodeVersion
+}
+
+// agePrefix starts the age terms of a query.
+const agePrefix = "age:"
+
+// parseQuery splits query into the words of names and the age terms.
+func parseQuery(query string) (string, []ageFilter, error) {
+	words, filters := []string{}, []ageFilter{}
+	for _, word := range strings.Fields(query) {
+		if len(word) < len(agePrefix) || !strings.EqualFold(word[:len(agePrefix)], agePrefix) {
+			words = append(words, word)
+			continue
+		}
+		spec := word[len(agePrefix):]
+		filter := ageFilter{op: "="}
+		for _, op := range []string{"<=", ">=", "<", ">", "="} {
+			if strings.HasPrefix(spec, op) {
+				filter.op, spec = op, spec[len(op):]
+				break
+			}
+		}
+		var err error
+		if filter.version, err = parseVersion(spec); err != nil {
+			return "", nil, fmt.Errorf("invalid age filter %q: use a version such as age:9.0 or age:<=9.0", word)
+		}
+		filters = append(filters, filter)
+	}
+	return strings.Join(words, " "), filters, nil
+}
+
+// matchAges reports whether age, as in "9.0", passes all filters.
+// Unknown ages pass none.
+func matchAges(filters []ageFilter, age string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	version, err := parseVersion(age)
+	if err != nil {
+		return false
+	}
+	for _, filter := range filters {
+		cmp := version.compare(filter.version)
+		ok := false
+		switch filter.op {
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case "=":
+			ok = cmp == 0
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// loadAges adds the Age property to ucd from DerivedAge.txt, unless
+// it has it already.
+func (c *cli) loadAges(ucd *UCD) error {
+	if ucd.has(func(rec Record) bool { return rec.Age != "" }) {
+		return nil
+	}
+	file, err := c.openFile(DerivedAgeFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := ucd.ReadAges(file); err != nil {
+		return fmt.Errorf("%s: %w", DerivedAgeFile, err)
+	}
+	return nil
+}
+
+// loadQueryAges loads the ages into ucd if the age terms of query
+// need them, or returns the error in the terms.
+func (c *cli) loadQueryAges(ucd *UCD, query string) error {
+	_, ages, err := parseQuery(query)
+	if err != nil || len(ages) == 0 {
+		return err
+	}
+	return c.loadAges(ucd)
+}
+
+// searchRecords returns the records of the characters whose names
+// contain all the words of query, with the configured filters added,
+// loading the ages its age terms need.
+func (c *cli) searchRecords(ucd *UCD, query string) ([]Record, error) {
+	query = strings.Join(append([]string{query}, c.cfg.Filters...), " ")
+	if err := c.loadQueryAges(ucd, query); err != nil {
+		return nil, err
+	}
+	recs := []Record{}
+	for _, fields := range ucd.Filter(query) {
+		rec, _ := ucd.Lookup([]rune(fields[1])[0])
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// loadAgesIfAvailable is loadAges for commands that show ages only
+// when they have them: it reads DerivedAge.txt only if it is in the
+// data directory, never downloading it, and leaves the ages out
+// otherwise.
+func (c *cli) loadAgesIfAvailable(ucd *UCD) error {
+	if ucd.has(func(rec Record) bool { return rec.Age != "" }) {
+		return nil
+	}
+	file, err := openUCD(localSource(newSource(c.cfg, nil)), DerivedAgeFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := ucd.ReadAges(file); err != nil {
+		return fmt.Errorf("%s: %w", DerivedAgeFile, err)
+	}
+	return nil
+}
+
+// AgeReport is the version of Unicode a text needs, and the non-ASCII
+// characters that need it or older ones, newest first.
+type AgeReport struct {
+	Minimum    string    `json:"minimum"` // "" if the text has no assigned characters
+	Characters []CharAge `json:"characters"`
+}
+
+// CharAge is a character and the version of Unicode that added it,
+// or "" if it is unassigned.
+type CharAge struct {
+	Code string `json:"code"`
+	Char string `json:"char"`
+	Name string `json:"name"`
+	Age  string `json:"age"`
+}
+
+// Ages reports on the ages of the characters of text. Invalid UTF-8
+// is skipped, and so are unassigned characters when working out the
+// minimum version, although they are listed.
+func (u *UCD) Ages(text string) *AgeReport {
+	report := &AgeReport{Characters: []CharAge{}}
+	newest, seen := unicodeVersion{}, map[rune]bool{}
+	for i, char := range text {
+		if char == utf8.RuneError && !strings.HasPrefix(text[i:], "\uFFFD") || seen[char] {
+			continue
+		}
+		seen[char] = true
+		age := u.Age(char)
+		if version, err := parseVersion(age); err == nil && version.compare(newest) > 0 {
+			newest = version
+		}
+		if char >= utf8.RuneSelf {
+			report.Characters = append(report.Characters,
+				CharAge{fmt.Sprintf("U+%04X", char), string(char), u.Name(char), age})
+		}
+	}
+	if newest != (unicodeVersion{}) {
+		report.Minimum = newest.String()
+	}
+	sort.SliceStable(report.Characters, func(i, j int) bool {
+		vi, _ := parseVersion(report.Characters[i].Age)
+		vj, _ := parseVersion(report.Characters[j].Age)
+		if cmp := vi.compare(vj); cmp != 0 {
+			return cmp > 0
+		}
+		return report.Characters[i].Char < report.Characters[j].Char
+	})
+	return report
+}
+
+// WriteText writes the report as text: the minimum version, then a
+// line per character, rendered safely with u.
+func (r *AgeReport) WriteText(w io.Writer, u *UCD) {
+	minimum := r.Minimum
+	if minimum == "" {
+		minimum = "unknown"
+	}
+	fmt.Fprintf(w, "minimum version: %s\n", minimum)
+	for _, ca := range r.Characters {
+		age := ca.Age
+		if age == "" {
+			age = "unassigned"
+		}
+		glyph, _ := u.Glyphs(ca.Char)
+		fmt.Fprintf(w, "%10s  %s\t%s\t%s\n", age, ca.Code, glyph, ca.Name)
+	}
+}
+
+func runAge(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	text := strings.Join(flags.Args(), " ")
+	if flags.NArg() == 0 {
+		input, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return err
+		}
+		text = string(input)
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	if err := c.loadAges(ucd); err != nil {
+		return err
+	}
+	report := ucd.Ages(text)
+	if c.cfg.Format == formatJSON {
+		return encodeJSON(c.stdout, report)
+	}
+	report.WriteText(c.stdout, ucd)
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const ageSample = `# DerivedAge.txt
+0000..007F    ; 1.1 #  [128] <control-0000>..DELETE
+00A0..00FF    ; 1.1 #  [96] NO-BREAK SPACE..LATIN SMALL LETTER Y WITH DIAERESIS
+20A0..20AA    ; 1.1 #  [11] EURO-CURRENCY SIGN..NEW SHEQEL SIGN
+2300..237A    ; 1.1 # [123] PLACE OF INTEREST SIGN..APL FUNCTIONAL SYMBOL ALPHA
+261A..266F    ; 1.1 #  [86] BLACK LEFT POINTING INDEX..MUSIC SHARP SIGN
+1F400..1F43E  ; 6.0 #  [63] RAT..PAW PRINTS
+1F600         ; 6.1 #       GRINNING FACE
+1F601..1F610  ; 6.0 #  [16] GRINNING FACE WITH SMILING EYES..NEUTRAL FACE
+1F630..1F640  ; 6.0 #  [17] FACE WITH OPEN MOUTH AND COLD SWEAT..WEARY CAT FACE
+1F641..1F642  ; 7.0 #   [2] SLIGHTLY FROWNING FACE..SLIGHTLY SMILING FACE
+`
+
+func TestParseQuery(t *testing.T) {
+	var testCases = []struct {
+		query string
+		words string
+		ages  []ageFilter
+	}{
+		{"cat face", "cat face", []ageFilter{}},
+		{"cat age:<=9.0", "cat", []ageFilter{{"<=", unicodeVersion{9, 0}}}},
+		{"AGE:15.1 face", "face", []ageFilter{{"=", unicodeVersion{15, 1}}}},
+		{"age:>6 age:<7.0.1", "", []ageFilter{{">", unicodeVersion{6, 0}}, {"<", unicodeVersion{7, 0}}}},
+		{"stage: ages", "stage: ages", []ageFilter{}},
+	}
+	for _, tc := range testCases {
+		words, ages, err := parseQuery(tc.query)
+		if err != nil || words != tc.words || !reflect.DeepEqual(ages, tc.ages) {
+			t.Errorf("%q:\n\twant: %q %v\n\tgot:  %q %v %v", tc.query, tc.words, tc.ages, words, ages, err)
+		}
+	}
+	for _, query := range []string{"age:", "age:<=", "age:new", "age:9.x", "age:=<9", "age:1.2.3.4"} {
+		if _, _, err := parseQuery(query); err == nil {
+			t.Errorf("%q: want error", query)
+		}
+	}
+}
+
+func TestMatchAges(t *testing.T) {
+	_, filters, _ := parseQuery("age:>=6.0 age:<7.0")
+	for age, want := range map[string]bool{"1.1": false, "6.0": true, "6.1": true, "7.0": false, "": false} {
+		if got := matchAges(filters, age); got != want {
+			t.Errorf("%q: want %v; got %v", age, want, got)
+		}
+	}
+	if !matchAges(nil, "") {
+		t.Errorf("no filters: want every age to match")
+	}
+}
+
+func TestAges(t *testing.T) {
+	ucd, err := LoadUCD(strings.NewReader(ucdSample), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ucd.ReadAges(strings.NewReader(ageSample)); err != nil {
+		t.Fatal(err)
+	}
+	report := ucd.Ages("A\U0001F600 \u263A\U0001F642\u0378\U0001F600\xff")
+	want := &AgeReport{Minimum: "7.0", Characters: []CharAge{
+		{"U+1F642", "\U0001F642", "SLIGHTLY SMILING FACE", "7.0"},
+		{"U+1F600", "\U0001F600", "GRINNING FACE", "6.1"},
+		{"U+263A", "\u263A", "WHITE SMILING FACE", "1.1"},
+		{"U+0378", "\u0378", "<unassigned-0378>", ""},
+	}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("\n\twant: %v\n\tgot:  %v", want, report)
+	}
+	for text, want := range map[string]string{"A\U0001F600": "6.1", "A": "1.1", "A\u0378": "", "": ""} {
+		if got := ucd.TextAge(text); got != want {
+			t.Errorf("TextAge %q: want %q; got %q", text, want, got)
+		}
+	}
+}
+
+func TestAges_ranges(t *testing.T) {
+	ucd, err := LoadUCD(strings.NewReader(ucdSample+"4E00;<CJK Ideograph, First>;Lo;0;L;;;;;N;;;;;\n"+
+		"9FFF;<CJK Ideograph, Last>;Lo;0;L;;;;;N;;;;;\n"), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ucd.ReadAges(strings.NewReader("4E00..9FA5    ; 1.1\n9FA6..9FBB    ; 4.1\n" +
+		"9FBC..9FFC    ; 13.0\n9FFD..9FFF    ; 14.0\n")); err != nil {
+		t.Fatal(err)
+	}
+	for char, want := range map[rune]string{0x4E00: "1.1", 0x9FA5: "1.1", 0x9FA6: "4.1", 0x9FBB: "4.1",
+		0x9FFC: "13.0", 0x9FFD: "14.0", 0x9FFF: "14.0"} {
+		if got := ucd.Age(char); got != want {
+			t.Errorf("Age U+%04X: want %q; got %q", char, want, got)
+		}
+	}
+	if got := ucd.TextAge("\u4e00\u9fff"); got != "14.0" {
+		t.Errorf("TextAge: want 14.0; got %q", got)
+	}
+	if rec, ok := ucd.Lookup(0x9FFF); !ok || rec.Name != "CJK UNIFIED IDEOGRAPH-9FFF" {
+		t.Errorf("Lookup U+9FFF: got %v %v", rec, ok)
+	}
+	if got := len(ucd.Assigned(0x4E00, 0x9FFF)); got != 0x9FFF-0x4E00+1 {
+		t.Errorf("Assigned: want %d; got %d", 0x9FFF-0x4E00+1, got)
+	}
+}
+
+func TestRun_age(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: ucdSample, DerivedAgeFile: ageSample}
+	}
+	var testCases = []struct {
+		args   []string
+		status int
+		output string
+	}{
+		{[]string{"smiling", "age:>=7.0"}, exitMatch, "U+1F642\t\U0001F642\tSLIGHTLY SMILING FACE\n"},
+		{[]string{"search", "grinning", "age:6.0"}, exitMatch, "U+1F601\t\U0001F601\tGRINNING FACE WITH SMILING EYES\n" +
+			"U+1F638\t\U0001F638\tGRINNING CAT FACE WITH SMILING EYES\n"},
+		{[]string{"search", "smiling", "age:<6"}, exitMatch, "U+263A\t\u263A\tWHITE SMILING FACE\n"},
+		{[]string{"search", "cat", "age:15.1"}, exitNoMatch, ""},
+		{[]string{"search", "-age", "grinning", "face", "age:>6.0"}, exitMatch,
+			"U+1F600\t\U0001F600\tGRINNING FACE\n\tage: 6.1\n"},
+		{[]string{"age", "A\U0001F600\u263A"}, exitMatch, "minimum version: 6.1\n" +
+			"       6.1  U+1F600\t\U0001F600\tGRINNING FACE\n" +
+			"       1.1  U+263A\t\u263A\tWHITE SMILING FACE\n"},
+		{[]string{"age", "-format", "json", "\u0378"}, exitMatch, `{
+  "minimum": "",
+  "characters": [
+    {
+      "code": "U+0378",
+      "char": "` + "\u0378" + `",
+      "name": "<unassigned-0378>",
+      "age": ""
+    }
+  ]
+}
+`},
+		{[]string{"info", "U+1F642"}, exitMatch,
+			"U+1F642\t\U0001F642\tSLIGHTLY SMILING FACE\n\tcategory: So\n\tcombining class: 0\n\tbidi class: ON\n\tage: 7.0\n"},
+	}
+	for _, tc := range testCases {
+		status, output, stderr := runArgs(tc.args...)
+		if status != tc.status || output != tc.output {
+			t.Errorf("%q:\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q", tc.args, tc.status, tc.output, status, output, stderr)
+		}
+	}
+	for _, args := range [][]string{
+		{"search", "cat", "age:new"},
+		{"gen", "go", "cat", "age:<=x"},
+		{"render", "-font", "x.ttf", "cat", "age:<=x"},
+	} {
+		if status, _, stderr := runArgs(args...); status != exitUsage || !strings.Contains(stderr, "invalid age filter") {
+			t.Errorf("%q: want status %d and invalid age filter; got %d %q", args, exitUsage, status, stderr)
+		}
+	}
+}
+
+func TestRun_info_localAges(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return LayeredSource{MemSource{UCDFile: ucdSample}, &HTTPSource{Mirrors: []string{srv.URL + "/"}}}
+	}
+	status, output, stderr := runArgs("info", "U+1F642")
+	want := "U+1F642\t\U0001F642\tSLIGHTLY SMILING FACE\n\tcategory: So\n\tcombining class: 0\n\tbidi class: ON\n"
+	if status != exitMatch || output != want || stderr != "" || requests != 0 {
+		t.Errorf("want: %d %q, no warning and no downloads\ngot:  %d %q, stderr %q and %d requests",
+			exitMatch, want, status, output, stderr, requests)
+	}
+}
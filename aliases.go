@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/standupdev/strset"
+)
+
+// Alias is a user-defined name for a character or a sequence of
+// them, such as "shrug" for ¯\_(ツ)_/¯, with tags that find it too.
+type Alias struct {
+	Name string
+	Text string
+	Tags []string
+}
+
+// Words returns the set of words in the name and tags, upper-cased
+// and with hyphenated words split apart, as searches match them.
+func (a Alias) Words() strset.Set {
+	return queryTerms(a.Name + " " + strings.Join(a.Tags, " "))
+}
+
+// Fields returns the fields filter lists for a. The name is marked
+// as user-defined and followed by the tags, as in
+// "deploy [user-defined: rocket, ship]".
+func (a Alias) Fields() [3]string {
+	mark := "[user-defined]"
+	if len(a.Tags) > 0 {
+		mark = "[user-defined: " + strings.Join(a.Tags, ", ") + "]"
+	}
+	return [3]string{codePoints(a.Text), a.Text, a.Name + " " + mark}
+}
+
+// Aliases holds the entries of an aliases file, in file order.
+type Aliases []Alias
+
+// Filter returns the fields of the aliases whose name and tags
+// contain all words in the query.
+func (aliases Aliases) Filter(query string) [][3]string {
+	result := [][3]string{}
+	terms := queryTerms(query)
+	for _, a := range aliases {
+		if terms.SubsetOf(a.Words()) {
+			result = append(result, a.Fields())
+		}
+	}
+	return result
+}
+
+// Find returns the alias called name, ignoring case.
+func (aliases Aliases) Find(name string) (Alias, bool) {
+	for _, a := range aliases {
+		if strings.EqualFold(a.Name, name) {
+			return a, true
+		}
+	}
+	return Alias{}, false
+}
+
+// aliasName matches the names an alias can have: TOML bare keys.
+var aliasName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ReadAliases reads an aliases file, in which each alias is a table
+// named after it, with the text it stands for and optional tags:
+//
+//	[shrug]
+//	text = "¯\\_(ツ)_/¯"
+//	tags = ["kaomoji"]
+//
+// Names are matched ignoring case, so no two may differ only in case.
+// Errors are *ParseError values.
+func ReadAliases(r io.Reader) (Aliases, error) {
+	values, err := parseTOML(r)
+	if err != nil {
+		return nil, err
+	}
+	byName, lines, names := map[string]*Alias{}, map[string]int{}, []string{}
+	folded := map[string]string{} // names by their lower case, as Find matches them
+	for _, key := range tomlKeys(values) {
+		value := values[key]
+		name, field, ok := strings.Cut(key, ".")
+		if !ok || !aliasName.MatchString(name) {
+			return nil, &ParseError{Line: value.line, Field: key, Value: value.text,
+				Err: errors.New("want a setting of an [ALIAS] table")}
+		}
+		if other, ok := folded[strings.ToLower(name)]; ok && other != name {
+			return nil, &ParseError{Line: value.line, Field: "alias", Value: name,
+				Err: fmt.Errorf("same name as %q but for case", other)}
+		}
+		folded[strings.ToLower(name)] = name
+		a := byName[name]
+		if a == nil {
+			a = &Alias{Name: name}
+			byName[name], lines[name] = a, value.line
+			names = append(names, name)
+		}
+		switch field {
+		case "text":
+			a.Text, err = value.str()
+			if err == nil && a.Text == "" {
+				err = errors.New("empty text")
+			}
+		case "tags":
+			a.Tags, err = value.list()
+		default:
+			err = errors.New("unknown setting")
+		}
+		if err != nil {
+			return nil, &ParseError{Line: value.line, Field: key, Value: value.text, Err: err}
+		}
+	}
+	aliases := Aliases{}
+	for _, name := range names {
+		a := byName[name]
+		if a.Text == "" {
+			return nil, &ParseError{Line: lines[name], Field: "alias", Value: name, Err: errors.New("missing text")}
+		}
+		aliases = append(aliases, *a)
+	}
+	return aliases, nil
+}
+
+// writeAlias writes a as a table of an aliases file.
+func writeAlias(w io.Writer, a Alias) {
+	fmt.Fprintf(w, "[%s]\ntext = %s\n", a.Name, strconv.Quote(a.Text))
+	if len(a.Tags) > 0 {
+		tags := make([]string, len(a.Tags))
+		for i, tag := range a.Tags {
+			tags[i] = strconv.Quote(tag)
+		}
+		fmt.Fprintf(w, "tags = [%s]\n", strings.Join(tags, ", "))
+	}
+}
+
+// removeAlias returns the text of an aliases file without the table
+// of the alias called name, keeping the comments after its settings,
+// which are taken to be about the next table.
+func removeAlias(text, name string) (string, bool) {
+	var kept strings.Builder
+	removed, inside := false, false
+	pending := "" // comments and blank lines after a removed setting
+	flush := func() {
+		kept.WriteString(strings.TrimLeft(pending, "\n"))
+		pending = ""
+	}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text() + "\n"
+		content := strings.TrimSpace(stripComment(line))
+		switch {
+		case strings.HasPrefix(content, "[") && strings.HasSuffix(content, "]"):
+			flush()
+			inside = strings.EqualFold(strings.TrimSpace(content[1:len(content)-1]), name)
+			removed = removed || inside
+			if inside {
+				continue
+			}
+		case !inside:
+		case content == "":
+			pending += line
+			continue
+		default:
+			pending = ""
+			continue
+		}
+		kept.WriteString(line)
+	}
+	flush()
+	if !removed {
+		return text, false
+	}
+	if result := strings.TrimRight(kept.String(), "\n"); result != "" {
+		return result + "\n", true
+	}
+	return "", true
+}
+
+// loadAliases reads the configured aliases file. A missing file is
+// not an error: there are no aliases yet.
+func (c *cli) loadAliases() (Aliases, error) {
+	file, err := os.Open(c.cfg.AliasesPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Aliases{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	aliases, err := ReadAliases(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.cfg.AliasesPath, err)
+	}
+	return aliases, nil
+}
+
+// loadSearchUCD is loadUCD for commands that search names, which
+// find the user's aliases too.
+func (c *cli) loadSearchUCD() (*UCD, error) {
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return nil, err
+	}
+	ucd.aliases, err = c.loadAliases()
+	return ucd, err
+}
+
+// editAliases rewrites the aliases file with edit, creating it and
+// its directory if needed.
+func (c *cli) editAliases(edit func(text string) (string, error)) error {
+	data, err := os.ReadFile(c.cfg.AliasesPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	text, err := edit(string(data))
+	if err != nil {
+		return err
+	}
+	if _, err := ReadAliases(strings.NewReader(text)); err != nil {
+		return fmt.Errorf("%s: %w", c.cfg.AliasesPath, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.cfg.AliasesPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.cfg.AliasesPath, []byte(text), 0644)
+}
+
+func aliasFlags(c *cli, flags *flag.FlagSet) {
+	formatFlags(c, flags)
+	flags.StringVar(&c.opts.tags, "tags", "", "find the alias by `TAGS` too, separated by commas")
+}
+
+func runAlias(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	aliases, err := c.loadAliases()
+	if err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		if c.opts.tags != "" {
+			return &UsageError{cmd.name, "-tags needs a NAME and TEXT to define"}
+		}
+		if flags.NArg() == 1 {
+			a, ok := aliases.Find(flags.Arg(0))
+			if !ok {
+				return errNoMatch
+			}
+			aliases = Aliases{a}
+		}
+		results := [][3]string{}
+		for _, a := range aliases {
+			results = append(results, a.Fields())
+		}
+		if len(results) == 0 {
+			return errNoMatch
+		}
+		return c.output(results, nil)
+	}
+	a := Alias{Name: flags.Arg(0)}
+	if !aliasName.MatchString(a.Name) {
+		return &UsageError{cmd.name, fmt.Sprintf("invalid name %q: use letters, digits, - and _", a.Name)}
+	}
+	chars, err := parseChars(flags.Args()[1:])
+	if err != nil {
+		return &UsageError{cmd.name, err.Error()}
+	}
+	a.Text = string(chars)
+	for _, tag := range strings.Split(c.opts.tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			a.Tags = append(a.Tags, tag)
+		}
+	}
+	return c.editAliases(func(text string) (string, error) {
+		text, _ = removeAlias(text, a.Name)
+		var b strings.Builder
+		if text = strings.TrimRight(text, "\n"); text != "" {
+			b.WriteString(text + "\n\n")
+		}
+		writeAlias(&b, a)
+		return b.String(), nil
+	})
+}
+
+func runUnalias(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return &UsageError{cmd.name, "missing alias names"}
+	}
+	return c.editAliases(func(text string) (string, error) {
+		for _, name := range flags.Args() {
+			var removed bool
+			if text, removed = removeAlias(text, name); !removed {
+				return "", &UsageError{cmd.name, fmt.Sprintf("no alias %q", name)}
+			}
+		}
+		return text, nil
+	})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const aliasesSample = `# team aliases
+[shrug]
+text = "¯\\_(ツ)_/¯"
+tags = ["kaomoji", "meh"]
+
+[checkmark]
+text = "\u2713"
+
+# rockets are for releases
+[deploy]
+tags = ["ship-it"]
+text = "🚀"
+`
+
+func TestReadAliases(t *testing.T) {
+	got, err := ReadAliases(strings.NewReader(aliasesSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Aliases{
+		{"shrug", "¯\\_(ツ)_/¯", []string{"kaomoji", "meh"}},
+		{"checkmark", "\u2713", nil},
+		{"deploy", "🚀", []string{"ship-it"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\n\twant: %q\n\tgot:  %q", want, got)
+	}
+
+	var testCases = []struct {
+		text string
+		want string
+	}{
+		{"text = \"x\"\n", `line 1: text "\"x\"": want a setting of an [ALIAS] table`},
+		{"[x]\ncolour = \"red\"\n", `line 2: x.colour "\"red\"": unknown setting`},
+		{"[x]\ntext = \"\"\n", `line 2: x.text "\"\"": empty text`},
+		{"[x]\ntags = [\"y\"]\n", `line 2: alias "x": missing text`},
+		{"[z]\ntags = [\"y\"]\n[a]\ntags = [\"y\"]\n", `line 2: alias "z": missing text`},
+		{"[z]\ncolour = \"red\"\n[a]\ntext = \"\"\n[m]\nshade = 1\n", `line 2: z.colour "\"red\"": unknown setting`},
+		{"[Shrug]\ntext = \"a\"\n[shrug]\ntext = \"b\"\n", `line 4: alias "shrug": same name as "Shrug" but for case`},
+	}
+	for _, tc := range testCases {
+		_, err := ReadAliases(strings.NewReader(tc.text))
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%q:\n\twant: %s\n\tgot:  %v", tc.text, tc.want, err)
+		}
+	}
+}
+
+func TestAliases_Filter(t *testing.T) {
+	aliases, err := ReadAliases(strings.NewReader(aliasesSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var testCases = []struct {
+		query string
+		want  [][3]string
+	}{
+		{"meh", [][3]string{{"U+00AF U+005C U+005F U+0028 U+30C4 U+0029 U+005F U+002F U+00AF",
+			"¯\\_(ツ)_/¯", "shrug [user-defined: kaomoji, meh]"}}},
+		{"Checkmark", [][3]string{{"U+2713", "\u2713", "checkmark [user-defined]"}}},
+		{"ship", [][3]string{{"U+1F680", "🚀", "deploy [user-defined: ship-it]"}}},
+		{"rocket", [][3]string{}},
+	}
+	for _, tc := range testCases {
+		if got := aliases.Filter(tc.query); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q:\n\twant: %q\n\tgot:  %q", tc.query, tc.want, got)
+		}
+	}
+}
+
+func TestRemoveAlias(t *testing.T) {
+	got, ok := removeAlias(aliasesSample, "CheckMark")
+	want := strings.Replace(aliasesSample, "[checkmark]\ntext = \"\\u2713\"\n\n", "", 1)
+	if !ok || got != want {
+		t.Errorf("\n\twant: %q\n\tgot:  %t %q", want, ok, got)
+	}
+	got, ok = removeAlias(aliasesSample, "deploy")
+	want = aliasesSample[:strings.Index(aliasesSample, "[deploy]")]
+	if !ok || got != want {
+		t.Errorf("\n\twant: %q\n\tgot:  %t %q", want, ok, got)
+	}
+	if got, ok = removeAlias(aliasesSample, "nosuch"); ok || got != aliasesSample {
+		t.Errorf("nosuch: want unchanged text; got %t %q", ok, got)
+	}
+}
+
+func TestRun_alias(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runescan", "aliases.toml")
+	t.Setenv("RUNESCAN_ALIASES", path)
+	for _, args := range [][]string{
+		{"alias", "-tags", "meh", "shrug", "¯\\_(ツ)_/¯"},
+		{"alias", "checkmark", "U+2713"},
+		{"alias", "-tags", "ship, release", "deploy", "U+1F680"},
+		{"unalias", "checkmark"},
+	} {
+		if status, _, stderr := runArgs(args...); status != exitMatch {
+			t.Fatalf("%q: want status %d; got %d %q", args, exitMatch, status, stderr)
+		}
+	}
+	data, err := os.ReadFile(path)
+	wantFile := "[shrug]\ntext = \"¯\\\\_(ツ)_/¯\"\ntags = [\"meh\"]\n\n" +
+		"[deploy]\ntext = \"🚀\"\ntags = [\"ship\", \"release\"]\n"
+	if err != nil || string(data) != wantFile {
+		t.Errorf("\n\twant: %q\n\tgot:  %q, %v", wantFile, data, err)
+	}
+
+	var testCases = []struct {
+		args   []string
+		status int
+		output string
+	}{
+		{[]string{"alias"}, exitMatch,
+			"U+00AF U+005C U+005F U+0028 U+30C4 U+0029 U+005F U+002F U+00AF\t¯\\_(ツ)_/¯\tshrug [user-defined: meh]\n" +
+				"U+1F680\t🚀\tdeploy [user-defined: ship, release]\n"},
+		{[]string{"alias", "Deploy"}, exitMatch, "U+1F680\t🚀\tdeploy [user-defined: ship, release]\n"},
+		{[]string{"alias", "checkmark"}, exitNoMatch, ""},
+		{[]string{"release"}, exitMatch, "U+1F680\t🚀\tdeploy [user-defined: ship, release]\n"},
+		{[]string{"-c", "release"}, exitMatch, "U+1F680\t🚀\tdeploy [user-defined: ship, release]\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			status, output, stderr := runArgs(tc.args...)
+			if status != tc.status || output != tc.output {
+				t.Errorf("\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q",
+					tc.status, tc.output, status, output, stderr)
+			}
+		})
+	}
+
+	var usageCases = []struct {
+		args   []string
+		stderr string
+	}{
+		{[]string{"alias", "thumbs up", "U+1F44D"}, `invalid name "thumbs up"`},
+		{[]string{"alias", "-tags", "x", "shrug"}, "-tags needs a NAME and TEXT"},
+		{[]string{"unalias", "shrug", "nosuch"}, `no alias "nosuch"`},
+		{[]string{"unalias"}, "missing alias names"},
+	}
+	for _, tc := range usageCases {
+		status, _, stderr := runArgs(tc.args...)
+		if status != exitUsage || !strings.Contains(stderr, tc.stderr) {
+			t.Errorf("%q:\n\twant: %d %q\n\tgot:  %d %q", tc.args, exitUsage, tc.stderr, status, stderr)
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != wantFile {
+		t.Errorf("failed unalias changed the file: %q", data)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"unicode/utf8"
+)
+
+// Analysis reports on the characters of a text.
+type Analysis struct {
+	File       string      `json:"file"`
+	Bytes      int         `json:"bytes"`
+	Runes      int         `json:"runes"`
+	Lines      int         `json:"lines"`
+	Scripts    []Count     `json:"scripts"`
+	Blocks     []Count     `json:"blocks"`
+	Categories []Count     `json:"categories"`
+	NonASCII   []CharCount `json:"non_ascii"`
+	Findings   []Finding   `json:"findings"`
+}
+
+// Count is the number of code points with a property value.
+type Count struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// CharCount is the number of occurrences of a character.
+type CharCount struct {
+	Code  string `json:"code"`
+	Char  string `json:"char"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Finding is a suspicious character and where it is.
+type Finding struct {
+	Kind   string `json:"kind"`
+	Code   string `json:"code"`
+	Name   string `json:"name"`
+	Byte   int    `json:"byte"`   // 0-based byte offset
+	Rune   int    `json:"rune"`   // 0-based rune offset
+	Line   int    `json:"line"`   // 1-based
+	Column int    `json:"column"` // 1-based, in runes
+}
+
+// Kinds of findings.
+const (
+	findInvalid      = "invalid-utf8"
+	findBidi         = "bidi-control"
+	findInvisible    = "invisible"
+	findControl      = "control"
+	findPrivateUse   = "private-use"
+	findUnassigned   = "unassigned"
+	findNoncharacter = "noncharacter"
+)
+
+// isBidiControl reports whether char is one of the explicit
+// directional formatting characters of UAX #9.
+func isBidiControl(char rune) bool {
+	return char == 0x061C || char == 0x200E || char == 0x200F ||
+		0x202A <= char && char <= 0x202E || 0x2066 <= char && char <= 0x2069
+}
+
+// isNoncharacter reports whether char is one of the 66 code points
+// reserved for internal use: U+FDD0..U+FDEF and the last two of
+// every plane.
+func isNoncharacter(char rune) bool {
+	return 0xFDD0 <= char && char <= 0xFDEF || char&0xFFFE == 0xFFFE
+}
+
+// isInvisible reports whether rec is a character that shows nothing
+// although it is not white space: format characters and the fillers
+// and marks that render as blanks.
+func isInvisible(rec Record) bool {
+	switch rec.Char {
+	case 0x034F, 0x115F, 0x1160, 0x17B4, 0x17B5, 0x180B, 0x180C, 0x180D, 0x180F,
+		0x2800, 0x3164, 0xFFA0:
+		return true
+	}
+	return rec.Category == "Cf"
+}
+
+// kind returns the kind of finding char is, or "" if it is fine.
+func (u *UCD) kind(char rune) string {
+	rec, ok := u.Lookup(char)
+	switch {
+	case isNoncharacter(char):
+		return findNoncharacter
+	case !ok:
+		return findUnassigned
+	case isBidiControl(char):
+		return findBidi
+	case isInvisible(rec):
+		return findInvisible
+	case rec.Category == "Co":
+		return findPrivateUse
+	case rec.Category == "Cc" && char != '\t' && char != '\n' && char != '\r':
+		return findControl
+	}
+	return ""
+}
+
+// Analyze counts the code points of text by script, block and
+// general category, counts the non-ASCII characters, and finds the
+// suspicious ones: bidi controls, invisible characters, controls
+// other than tab and newlines, private-use characters, unassigned
+// code points, noncharacters and invalid UTF-8.
+func (u *UCD) Analyze(text []byte) *Analysis {
+	a := &Analysis{Bytes: len(text), Findings: []Finding{}}
+	scripts, blocks, categories := map[string]int{}, map[string]int{}, map[string]int{}
+	nonASCII := map[rune]int{}
+	line, column := 1, 0
+	for offset := 0; offset < len(text); {
+		char, size := utf8.DecodeRune(text[offset:])
+		column++
+		kind := ""
+		if char == utf8.RuneError && size == 1 {
+			kind = findInvalid
+		} else {
+			rec, _ := u.Lookup(char)
+			category := rec.Category
+			if category == "" {
+				category = "Cn"
+			}
+			scripts[u.Script(char)]++
+			blocks[u.Block(char)]++
+			categories[category]++
+			if char >= utf8.RuneSelf {
+				nonASCII[char]++
+			}
+			kind = u.kind(char)
+		}
+		if kind != "" {
+			finding := Finding{Kind: kind, Code: fmt.Sprintf("U+%04X", char), Name: u.Name(char),
+				Byte: offset, Rune: a.Runes, Line: line, Column: column}
+			if kind == findInvalid {
+				finding.Code = fmt.Sprintf("0x%02X", text[offset])
+				finding.Name = "<invalid UTF-8>"
+			}
+			a.Findings = append(a.Findings, finding)
+		}
+		if char == '\n' {
+			line++
+			column = 0
+		}
+		a.Runes++
+		offset += size
+	}
+	a.Lines = line
+	if len(text) == 0 || text[len(text)-1] == '\n' {
+		a.Lines--
+	}
+	a.Scripts, a.Blocks, a.Categories = sortCounts(scripts), sortCounts(blocks), sortCounts(categories)
+	a.NonASCII = []CharCount{}
+	for char, count := range nonASCII {
+		a.NonASCII = append(a.NonASCII, CharCount{fmt.Sprintf("U+%04X", char), string(char), u.Name(char), count})
+	}
+	sort.Slice(a.NonASCII, func(i, j int) bool {
+		x, y := a.NonASCII[i], a.NonASCII[j]
+		if x.Count != y.Count {
+			return x.Count > y.Count
+		}
+		return x.Char < y.Char
+	})
+	return a
+}
+
+// sortCounts returns counts most frequent first, then by value.
+func sortCounts(counts map[string]int) []Count {
+	result := make([]Count, 0, len(counts))
+	for value, count := range counts {
+		result = append(result, Count{value, count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result
+}
+
+// WriteText writes the analysis as a report for people, with
+// characters rendered by u.Glyphs.
+func (a *Analysis) WriteText(w io.Writer, u *UCD) {
+	if a.File != "" {
+		fmt.Fprintf(w, "%s:\n", a.File)
+	}
+	fmt.Fprintf(w, "%d bytes, %d runes, %d lines\n", a.Bytes, a.Runes, a.Lines)
+	for _, section := range []struct {
+		title  string
+		counts []Count
+	}{
+		{"scripts", a.Scripts}, {"blocks", a.Blocks}, {"categories", a.Categories},
+	} {
+		fmt.Fprintf(w, "\n%s:\n", section.title)
+		for _, count := range section.counts {
+			fmt.Fprintf(w, "%7d  %s\n", count.Count, count.Value)
+		}
+	}
+	if len(a.NonASCII) > 0 {
+		fmt.Fprint(w, "\nnon-ASCII characters:\n")
+		for _, cc := range a.NonASCII {
+			glyph, _ := u.Glyphs(cc.Char)
+			fmt.Fprintf(w, "%7d  %s\t%s\t%s\n", cc.Count, cc.Code, glyph, cc.Name)
+		}
+	}
+	if len(a.Findings) > 0 {
+		fmt.Fprint(w, "\nfindings:\n")
+		for _, f := range a.Findings {
+			fmt.Fprintf(w, "%d:%d: %s %s %s (byte %d, rune %d)\n",
+				f.Line, f.Column, f.Kind, f.Code, f.Name, f.Byte, f.Rune)
+		}
+	}
+}
+
+// loadProperties adds the Block and Script properties to ucd from
+// Blocks.txt and Scripts.txt, unless it has read them already. The
+// files are read even if the records have the properties, as UCD XML
+// files give them by their aliases, such as "ASCII" and "Latn".
+func (c *cli) loadProperties(ucd *UCD) error {
+	for _, data := range []struct {
+		name string
+		read func(io.Reader) error
+		done bool
+	}{
+		{BlocksFile, ucd.ReadBlocks, len(ucd.blocks) > 0},
+		{ScriptsFile, ucd.ReadScripts, ucd.scripts},
+	} {
+		if data.done {
+			continue
+		}
+		file, err := c.openFile(data.name)
+		if err != nil {
+			return err
+		}
+		err = data.read(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", data.name, err)
+		}
+	}
+	return nil
+}
+
+func runAnalyze(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	inputs := map[string][]byte{}
+	names := flags.Args()
+	if len(names) == 0 {
+		text, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return err
+		}
+		inputs[""] = text
+		names = []string{""}
+	}
+	for _, name := range flags.Args() {
+		text, err := os.ReadFile(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return &UsageError{cmd.name, err.Error()}
+		}
+		if err != nil {
+			return err
+		}
+		inputs[name] = text
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	if err := c.loadProperties(ucd); err != nil {
+		return err
+	}
+	analyses := []*Analysis{}
+	findings := 0
+	for _, name := range names {
+		a := ucd.Analyze(inputs[name])
+		a.File = name
+		analyses = append(analyses, a)
+		findings += len(a.Findings)
+	}
+	if c.cfg.Format == formatJSON {
+		if err := encodeJSON(c.stdout, analyses); err != nil {
+			return err
+		}
+	} else {
+		for i, a := range analyses {
+			if i > 0 {
+				fmt.Fprintln(c.stdout)
+			}
+			a.WriteText(c.stdout, ucd)
+		}
+	}
+	if findings > 0 {
+		return errFindings
+	}
+	return nil
+}
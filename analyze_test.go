@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// analyzeUCD holds UnicodeData.txt lines for the analysis tests.
+const analyzeUCD = renderUCD + `000A;<control>;Cc;0;B;;;;;N;LINE FEED (LF);;;;
+0061;LATIN SMALL LETTER A;Ll;0;L;;;;;N;;;0041;;0041
+0062;LATIN SMALL LETTER B;Ll;0;L;;;;;N;;;0042;;0042
+00E9;LATIN SMALL LETTER E WITH ACUTE;Ll;0;L;0065 0301;;;;N;LATIN SMALL LETTER E ACUTE;;00C9;;00C9
+200B;ZERO WIDTH SPACE;Cf;0;BN;;;;;N;;;;;
+E000;<Private Use, First>;Co;0;L;;;;;N;;;;;
+F8FF;<Private Use, Last>;Co;0;L;;;;;N;;;;;
+`
+
+const blocksSample = `# Blocks.txt
+0000..007F; Basic Latin
+0080..00FF; Latin-1 Supplement
+0370..03FF; Greek and Coptic
+2000..206F; General Punctuation
+E000..F8FF; Private Use Area
+FE70..FEFF; Arabic Presentation Forms-B
+`
+
+const scriptsSample = `# Scripts.txt
+0000..0020    ; Common # Cc  [32] <control-0000>..<control-001F>
+0041          ; Latin
+0061..0062    ; Latin
+00E9          ; Latin
+200B          ; Common
+202E          ; Common
+`
+
+// analyzeText has one character of each kind of finding.
+const analyzeText = "ab\u00e9\u202e\nA\u200b\u00e9\ue000\u0378\ufffe\xff\n"
+
+func TestUCD_Analyze(t *testing.T) {
+	ucd, err := LoadUCD(strings.NewReader(analyzeUCD), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ucd.ReadBlocks(strings.NewReader(blocksSample)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ucd.ReadScripts(strings.NewReader(scriptsSample)); err != nil {
+		t.Fatal(err)
+	}
+	a := ucd.Analyze([]byte(analyzeText))
+	if a.Bytes != 24 || a.Runes != 13 || a.Lines != 2 {
+		t.Errorf("want 24 bytes, 13 runes, 2 lines; got %d, %d, %d", a.Bytes, a.Runes, a.Lines)
+	}
+	wantScripts := []Count{{"Latin", 5}, {"Common", 4}, {"Unknown", 3}}
+	if !reflect.DeepEqual(a.Scripts, wantScripts) {
+		t.Errorf("scripts: want %v; got %v", wantScripts, a.Scripts)
+	}
+	wantBlocks := []Count{{"Basic Latin", 5}, {"General Punctuation", 2}, {"Latin-1 Supplement", 2},
+		{"Greek and Coptic", 1}, {"No_Block", 1}, {"Private Use Area", 1}}
+	if !reflect.DeepEqual(a.Blocks, wantBlocks) {
+		t.Errorf("blocks: want %v; got %v", wantBlocks, a.Blocks)
+	}
+	if a.NonASCII[0] != (CharCount{"U+00E9", "\u00e9", "LATIN SMALL LETTER E WITH ACUTE (LATIN SMALL LETTER E ACUTE)", 2}) {
+		t.Errorf("non-ASCII: got %v", a.NonASCII)
+	}
+	wantFindings := []Finding{
+		{findBidi, "U+202E", "RIGHT-TO-LEFT OVERRIDE", 4, 3, 1, 4},
+		{findInvisible, "U+200B", "ZERO WIDTH SPACE", 9, 6, 2, 2},
+		{findPrivateUse, "U+E000", "<private-use-E000>", 14, 8, 2, 4},
+		{findUnassigned, "U+0378", "<unassigned-0378>", 17, 9, 2, 5},
+		{findNoncharacter, "U+FFFE", "<unassigned-FFFE>", 19, 10, 2, 6},
+		{findInvalid, "0xFF", "<invalid UTF-8>", 22, 11, 2, 7},
+	}
+	if !reflect.DeepEqual(a.Findings, wantFindings) {
+		t.Errorf("findings:\n\twant: %v\n\tgot:  %v", wantFindings, a.Findings)
+	}
+}
+
+func TestRun_analyze(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: analyzeUCD, BlocksFile: blocksSample, ScriptsFile: scriptsSample}
+	}
+	var stdout, stderr strings.Builder
+	status := run([]string{"analyze"}, strings.NewReader(analyzeText), &stdout, &stderr)
+	for _, want := range []string{
+		"24 bytes, 13 runes, 2 lines\n",
+		"\nscripts:\n      5  Latin\n      4  Common\n      3  Unknown\n",
+		"      2  U+00E9\t\u00e9\tLATIN SMALL LETTER E WITH ACUTE",
+		"1:4: bidi-control U+202E RIGHT-TO-LEFT OVERRIDE (byte 4, rune 3)\n",
+		"2:7: invalid-utf8 0xFF <invalid UTF-8> (byte 22, rune 11)\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+	if status != exitFindings {
+		t.Errorf("want status %d; got %d (stderr %q)", exitFindings, status, stderr.String())
+	}
+
+	stdout.Reset()
+	status = run([]string{"analyze", "-format", "json"}, strings.NewReader("ab\n"), &stdout, &stderr)
+	var analyses []Analysis
+	if err := json.Unmarshal([]byte(stdout.String()), &analyses); err != nil {
+		t.Fatalf("%v:\n%s", err, stdout.String())
+	}
+	if status != exitMatch || len(analyses) != 1 || analyses[0].Runes != 3 ||
+		len(analyses[0].Findings) != 0 || len(analyses[0].NonASCII) != 0 {
+		t.Errorf("clean text: got %d %+v", status, analyses)
+	}
+}
+
+func TestRun_analyze_xml(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDXMLFile: xmlSample, BlocksFile: blocksSample, ScriptsFile: scriptsSample}
+	}
+	t.Setenv("UCD_PATH", filepath.Join(t.TempDir(), UCDXMLFile))
+	var stdout, stderr strings.Builder
+	status := run([]string{"analyze", "-format", "json"}, strings.NewReader("Aa"), &stdout, &stderr)
+	var analyses []Analysis
+	if err := json.Unmarshal([]byte(stdout.String()), &analyses); err != nil {
+		t.Fatalf("%v:\n%s%s", err, stdout.String(), stderr.String())
+	}
+	wantScripts := []Count{{"Latin", 2}}
+	wantBlocks := []Count{{"Basic Latin", 2}}
+	if status != exitMatch || len(analyses) != 1 ||
+		!reflect.DeepEqual(analyses[0].Scripts, wantScripts) || !reflect.DeepEqual(analyses[0].Blocks, wantBlocks) {
+		t.Errorf("want scripts %v and blocks %v; got %d %+v", wantScripts, wantBlocks, status, analyses)
+	}
+}
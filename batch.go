@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// batchResult is a result of a batch query as JSON Lines output
+// shows it: a record tagged with its query, or the query's error.
+type batchResult struct {
+	Query string `json:"query"`
+	*jsonResult
+	Error string `json:"error,omitempty"`
+}
+
+// batchQuery runs a line of a batch file: code points to look up if
+// every word is of the form U+XXXX, otherwise search words, to which
+// the configured filters are added.
+func (c *cli) batchQuery(ucd *UCD, query string) ([][3]string, error) {
+	words := strings.Fields(query)
+	lookup := true
+	for _, word := range words {
+		if len(word) <= 2 || !strings.EqualFold(word[:2], "U+") {
+			lookup = false
+		}
+	}
+	if !lookup {
+		query := strings.Join(append(words, c.cfg.Filters...), " ")
+		if err := c.loadQueryAges(ucd, query); err != nil {
+			return nil, err
+		}
+		results := ucd.Filter(query)
+		if len(results) == 0 {
+			return nil, errNoMatch
+		}
+		return results, nil
+	}
+	chars, err := parseChars(words)
+	if err != nil {
+		return nil, err
+	}
+	results := [][3]string{}
+	for _, char := range chars {
+		results = append(results, sequenceFields(ucd, string(char)))
+	}
+	return results, nil
+}
+
+// runBatch runs the queries in the file called name, or standard
+// input for "-", one per line, loading the UCD only once. Blank lines
+// and lines starting with "#" are skipped. Text output has a header
+// per query, as in "==> face eyes <==", and JSON output is JSON Lines
+// with each record tagged with its query. A query that fails is
+// reported and the batch goes on; it fails only if no query matches.
+func (c *cli) runBatch(cmd *command, name string, show details, quiet bool) error {
+	input := c.stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return &UsageError{cmd.name, err.Error()}
+		}
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	ucd, err := c.loadSearchUCD()
+	if err != nil {
+		return err
+	}
+	if show.ages {
+		if err := c.loadAges(ucd); err != nil {
+			return err
+		}
+	}
+	text := c.cfg.Format != formatJSON && !quiet
+	if text && c.safe {
+		if err := c.loadDisplayData(ucd); err != nil {
+			return err
+		}
+	}
+	matched, groups := false, 0
+	lines := bufio.NewScanner(input)
+	for lines.Scan() {
+		query := strings.TrimSpace(lines.Text())
+		if query == "" || strings.HasPrefix(query, "#") {
+			continue
+		}
+		results, err := c.batchQuery(ucd, query)
+		matched = matched || err == nil
+		switch {
+		case quiet:
+			continue
+		case !text:
+			err = c.writeBatchJSON(ucd, query, results, show, err)
+		default:
+			if groups > 0 {
+				fmt.Fprintln(c.stdout)
+			}
+			groups++
+			fmt.Fprintf(c.stdout, "==> %s <==\n", query)
+			if err != nil {
+				fmt.Fprintf(c.stderr, "runescan: %s: %v\n", query, err)
+				continue
+			}
+			err = c.writeBatchText(ucd, results, show)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return err
+	}
+	if !matched {
+		return errNoMatch
+	}
+	return nil
+}
+
+// writeBatchText writes the results of a query as search does.
+func (c *cli) writeBatchText(ucd *UCD, results [][3]string, show details) error {
+	switch {
+	case show.decompositions || show.casePartners || show.ages:
+		return displayDetails(c.stdout, formatText, ucd, results, show)
+	case !c.safe:
+		display(c.stdout, results)
+		return nil
+	}
+	return ucd.RenderList(c.stdout, results)
+}
+
+// writeBatchJSON writes the results of a query, or its error, as
+// JSON Lines.
+func (c *cli) writeBatchJSON(ucd *UCD, query string, results [][3]string, show details, queryErr error) error {
+	records := []batchResult{}
+	if queryErr != nil {
+		records = append(records, batchResult{Query: query, Error: queryErr.Error()})
+	}
+	for _, result := range detailedResults(ucd, results, show) {
+		result := result
+		records = append(records, batchResult{Query: query, jsonResult: &result})
+	}
+	return writeJSONLines(c.stdout, records)
+}
+
+// writeJSONLines writes each record as JSON on a line of its own.
+func writeJSONLines(w io.Writer, records []batchResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
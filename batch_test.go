@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const batchQueries = `# queries
+latin small
+
+u+0041 U+0378
+no such name
+U+ZZZZ
+zero-width
+`
+
+func TestRun_batch(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: analyzeUCD}
+	}
+	var stdout, stderr strings.Builder
+	status := run([]string{"-batch", "-"}, strings.NewReader(batchQueries), &stdout, &stderr)
+	want := "==> latin small <==\n" +
+		"U+0061\ta\tLATIN SMALL LETTER A\n" +
+		"U+0062\tb\tLATIN SMALL LETTER B\n" +
+		"U+00E9\t\u00e9\tLATIN SMALL LETTER E WITH ACUTE (LATIN SMALL LETTER E ACUTE)\n" +
+		"\n==> u+0041 U+0378 <==\n" +
+		"U+0041\tA\tLATIN CAPITAL LETTER A\n" +
+		"U+0378\t\u0378\t<unassigned-0378>\n" +
+		"\n==> no such name <==\n" +
+		"\n==> U+ZZZZ <==\n" +
+		"\n==> zero-width <==\n" +
+		"U+200B\t\u200b\tZERO WIDTH SPACE\n"
+	wantErrors := "runescan: no such name: no match\n" +
+		"runescan: U+ZZZZ: invalid code point \"U+ZZZZ\"\n"
+	if status != exitMatch || stdout.String() != want || stderr.String() != wantErrors {
+		t.Errorf("want: %d %q %q\ngot:  %d %q %q", exitMatch, want, wantErrors,
+			status, stdout.String(), stderr.String())
+	}
+
+	queries := filepath.Join(t.TempDir(), "queries.txt")
+	if err := os.WriteFile(queries, []byte("latin capital\nnothing here\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	stderr.Reset()
+	status = run([]string{"search", "-format", "json", "-c", "-batch", queries}, nil, &stdout, &stderr)
+	type record struct {
+		Query, Code, Char, Name, Lowercase, Error string
+	}
+	got := []record{}
+	for _, line := range strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n") {
+		var r record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("%v: %q", err, line)
+		}
+		got = append(got, r)
+	}
+	wantRecords := []record{
+		{Query: "latin capital", Code: "U+0041", Char: "A", Name: "LATIN CAPITAL LETTER A", Lowercase: "U+0061 a"},
+		{Query: "latin capital", Code: "U+FF21", Char: "\uff21", Name: "FULLWIDTH LATIN CAPITAL LETTER A", Lowercase: "U+FF41 \uff41"},
+		{Query: "nothing here", Error: "no match"},
+	}
+	if status != exitMatch || !reflect.DeepEqual(got, wantRecords) || stderr.Len() != 0 {
+		t.Errorf("want %d %+v; got %d %+v (stderr %q)", exitMatch, wantRecords, status, got, stderr.String())
+	}
+
+	testCases := []struct {
+		args  []string
+		stdin string
+		want  int
+	}{
+		{[]string{"-batch", "-", "-format", "json", "-q"}, "nothing here\n\n", exitNoMatch},
+		{[]string{"-q", "-batch", "-"}, "nothing\nlatin\n", exitMatch},
+		{[]string{"-batch", "-", "latin"}, "", exitUsage},
+		{[]string{"-batch", filepath.Join(t.TempDir(), "missing")}, "", exitUsage},
+	}
+	for _, tc := range testCases {
+		stdout.Reset()
+		if status := run(tc.args, strings.NewReader(tc.stdin), &stdout, &stderr); status != tc.want || stdout.Len() != 0 {
+			t.Errorf("%q: want %d and no output; got %d %q", tc.args, tc.want, status, stdout.String())
+		}
+	}
+}
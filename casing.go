@@ -0,0 +1,428 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Data files for case mapping and folding.
+const (
+	SpecialCasingFile = "SpecialCasing.txt"
+	CaseFoldingFile   = "CaseFolding.txt"
+)
+
+// caseModes are the transforms Casing applies.
+var caseModes = []string{"upper", "lower", "title", "fold"}
+
+// Casing maps text to upper, lower and title case and folds it, with
+// the full mappings of SpecialCasing.txt and CaseFolding.txt on top
+// of the simple mappings in fields 12 to 14 of UnicodeData.txt.
+type Casing struct {
+	ucd     *UCD
+	special map[rune][]specialCasing
+	folding map[rune]string // status C and F: full folding
+	turkic  map[rune]string // status T: dotted and dotless I
+}
+
+// specialCasing is a line of SpecialCasing.txt.
+type specialCasing struct {
+	lower, title, upper string
+	lang                string   // such as "tr", or "" for all languages
+	conditions          []string // contexts such as "Final_Sigma"
+}
+
+// NewCasing returns a Casing using only the simple mappings in u,
+// until ReadSpecialCasing and ReadCaseFolding add the others.
+func NewCasing(u *UCD) *Casing {
+	return &Casing{ucd: u, special: map[rune][]specialCasing{},
+		folding: map[rune]string{}, turkic: map[rune]string{}}
+}
+
+// ReadSpecialCasing reads SpecialCasing.txt, whose lines give the
+// lower, title and upper case mappings of a character, and then
+// optionally a language and the contexts where they apply:
+//
+//	03A3; 03C2; 03A3; 03A3; Final_Sigma; # GREEK CAPITAL LETTER SIGMA
+func (c *Casing) ReadSpecialCasing(r io.Reader) error {
+	return ScanProperties(r, func(first, last rune, fields []string) error {
+		if len(fields) < 3 {
+			return &ParseError{Field: "line", Value: strings.Join(fields, ";"),
+				Err: errors.New("want lower, title and upper mappings")}
+		}
+		mappings := [3]string{}
+		for i, name := range []string{"lowercase mapping", "titlecase mapping", "uppercase mapping"} {
+			chars, err := parseCodePoints(fields[i])
+			if err != nil {
+				return &ParseError{Field: name, Value: fields[i], Err: err}
+			}
+			mappings[i] = string(chars)
+		}
+		sc := specialCasing{lower: mappings[0], title: mappings[1], upper: mappings[2]}
+		if len(fields) > 3 {
+			for _, condition := range strings.Fields(fields[3]) {
+				if condition == strings.ToLower(condition) {
+					sc.lang = condition
+				} else {
+					sc.conditions = append(sc.conditions, condition)
+				}
+			}
+		}
+		for char := first; char <= last; char++ {
+			// Language-specific mappings take precedence.
+			if sc.lang != "" {
+				c.special[char] = append([]specialCasing{sc}, c.special[char]...)
+			} else {
+				c.special[char] = append(c.special[char], sc)
+			}
+		}
+		return nil
+	})
+}
+
+// ReadCaseFolding reads CaseFolding.txt, whose lines give a status
+// and the folding of a character:
+//
+//	00DF; F; 0073 0073; # LATIN SMALL LETTER SHARP S
+//
+// Full folding uses the mappings with status C and F; the status T
+// mappings replace them for Turkish and Azerbaijani.
+func (c *Casing) ReadCaseFolding(r io.Reader) error {
+	return ScanProperties(r, func(first, last rune, fields []string) error {
+		if len(fields) < 2 {
+			return &ParseError{Field: "line", Value: strings.Join(fields, ";"),
+				Err: errors.New("want status and mapping")}
+		}
+		chars, err := parseCodePoints(fields[1])
+		if err != nil {
+			return &ParseError{Field: "folding", Value: fields[1], Err: err}
+		}
+		for char := first; char <= last; char++ {
+			switch fields[0] {
+			case "C", "F":
+				c.folding[char] = string(chars)
+			case "T":
+				c.turkic[char] = string(chars)
+			}
+		}
+		return nil
+	})
+}
+
+// Upper returns s in upper case for the language lang, such as "tr"
+// or "lt", which may be empty.
+func (c *Casing) Upper(s, lang string) string { return c.transform("upper", s, lang) }
+
+// Lower returns s in lower case for lang.
+func (c *Casing) Lower(s, lang string) string { return c.transform("lower", s, lang) }
+
+// Title returns s with the first letter of each word in title case
+// and the others in lower case, for lang.
+func (c *Casing) Title(s, lang string) string { return c.transform("title", s, lang) }
+
+// Fold returns the full case folding of s, for lang, for caseless
+// matching.
+func (c *Casing) Fold(s, lang string) string { return c.transform("fold", s, lang) }
+
+// Transform applies mode, one of "upper", "lower", "title" and
+// "fold", to s.
+func (c *Casing) Transform(mode, s, lang string) (string, error) {
+	if err := checkCaseMode(mode); err != nil {
+		return "", err
+	}
+	return c.transform(mode, s, lang), nil
+}
+
+// checkCaseMode reports an error if mode is not a case transform.
+func checkCaseMode(mode string) error {
+	for _, known := range caseModes {
+		if mode == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown case transform %q", mode)
+}
+
+func (c *Casing) transform(mode, s, lang string) string {
+	chars := []rune(s)
+	var b strings.Builder
+	for i := range chars {
+		b.WriteString(c.mapChar(c.charMode(mode, chars, i), chars, i, lang))
+	}
+	return b.String()
+}
+
+// charMode returns the mode for chars[i]: for title, "title" at the
+// start of a word and "lower" elsewhere.
+func (c *Casing) charMode(mode string, chars []rune, i int) string {
+	if mode != "title" {
+		return mode
+	}
+	for j := i - 1; j >= 0; j-- {
+		switch {
+		case c.cased(chars[j]):
+			return "lower"
+		case !c.caseIgnorable(chars[j]):
+			return "title"
+		}
+	}
+	return "title"
+}
+
+// mapChar returns the mapping of chars[i] in mode, which is not
+// "title" for letters inside words.
+func (c *Casing) mapChar(mode string, chars []rune, i int, lang string) string {
+	char := chars[i]
+	if mode == "fold" {
+		if folded, ok := c.turkic[char]; ok && turkic(lang) {
+			return folded
+		}
+		if folded, ok := c.folding[char]; ok {
+			return folded
+		}
+		return string(char)
+	}
+	for _, sc := range c.special[char] {
+		if (sc.lang == "" || sc.lang == lang) && c.inContext(sc.conditions, chars, i) {
+			return map[string]string{"upper": sc.upper, "lower": sc.lower, "title": sc.title}[mode]
+		}
+	}
+	rec, _ := c.ucd.Lookup(char)
+	mapped := map[string]rune{"upper": rec.Upper, "lower": rec.Lower, "title": rec.Title}[mode]
+	if mapped == 0 {
+		return string(char)
+	}
+	return string(mapped)
+}
+
+// turkic reports whether lang uses the dotted and dotless I.
+func turkic(lang string) bool { return lang == "tr" || lang == "az" }
+
+// inContext reports whether all conditions hold for chars[i], as
+// defined in table 3-17 of the Unicode Standard.
+func (c *Casing) inContext(conditions []string, chars []rune, i int) bool {
+	for _, condition := range conditions {
+		var ok bool
+		switch strings.TrimPrefix(condition, "Not_") {
+		case "Final_Sigma":
+			ok = c.finalSigma(chars, i)
+		case "After_Soft_Dotted":
+			ok = c.after(chars, i, func(char rune) bool { return unicode.Is(unicode.Soft_Dotted, char) })
+		case "After_I":
+			ok = c.after(chars, i, func(char rune) bool { return char == 'I' })
+		case "More_Above":
+			ok = c.moreAbove(chars, i)
+		case "Before_Dot":
+			ok = c.beforeDot(chars, i)
+		}
+		if strings.HasPrefix(condition, "Not_") {
+			ok = !ok
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// finalSigma reports whether chars[i] ends a word: it follows a cased
+// letter and no cased letter follows it, skipping case-ignorable
+// characters.
+func (c *Casing) finalSigma(chars []rune, i int) bool {
+	before := false
+	for j := i - 1; j >= 0 && !before; j-- {
+		if !c.caseIgnorable(chars[j]) {
+			if !c.cased(chars[j]) {
+				return false
+			}
+			before = true
+		}
+	}
+	for j := i + 1; j < len(chars); j++ {
+		if !c.caseIgnorable(chars[j]) {
+			return before && !c.cased(chars[j])
+		}
+	}
+	return before
+}
+
+// after reports whether the last base character before chars[i] is
+// one for which match is true, with no combining mark above between
+// them.
+func (c *Casing) after(chars []rune, i int, match func(rune) bool) bool {
+	for j := i - 1; j >= 0; j-- {
+		switch class := c.ucd.combiningClass(chars[j]); {
+		case match(chars[j]):
+			return true
+		case class == 0 || class == 230:
+			return false
+		}
+	}
+	return false
+}
+
+// moreAbove reports whether a combining mark above follows chars[i]
+// before the next base character.
+func (c *Casing) moreAbove(chars []rune, i int) bool {
+	for _, char := range chars[i+1:] {
+		switch c.ucd.combiningClass(char) {
+		case 230:
+			return true
+		case 0:
+			return false
+		}
+	}
+	return false
+}
+
+// beforeDot reports whether COMBINING DOT ABOVE follows chars[i]
+// before the next base character or other mark above.
+func (c *Casing) beforeDot(chars []rune, i int) bool {
+	for _, char := range chars[i+1:] {
+		if char == 0x0307 {
+			return true
+		}
+		if class := c.ucd.combiningClass(char); class == 0 || class == 230 {
+			return false
+		}
+	}
+	return false
+}
+
+// cased reports whether char is a cased letter. UnicodeData.txt does
+// not list the Other_Lowercase and Other_Uppercase properties, so
+// they come from Go's tables.
+func (c *Casing) cased(char rune) bool {
+	rec, _ := c.ucd.Lookup(char)
+	switch rec.Category {
+	case "Lu", "Ll", "Lt":
+		return true
+	}
+	return unicode.In(char, unicode.Other_Lowercase, unicode.Other_Uppercase)
+}
+
+// caseIgnorable reports whether char is ignored when looking for
+// word boundaries: marks, format characters, modifiers and the
+// apostrophes and periods that may appear inside words.
+func (c *Casing) caseIgnorable(char rune) bool {
+	rec, _ := c.ucd.Lookup(char)
+	switch rec.Category {
+	case "Mn", "Me", "Cf", "Lm", "Sk":
+		return true
+	}
+	return strings.ContainsRune("'.:\u00b7\u0387\u055f\u05f4\u2018\u2019\u2024\u2027\ufe13\ufe52\ufe55\uff07\uff0e\uff1a", char)
+}
+
+// goMapping returns what Go's unicode and strings packages make of
+// char in mode, for lang. Go has no full case folding; fold is
+// lower case of upper case, as strings.EqualFold treats most text.
+func goMapping(mode, lang string, char rune) string {
+	special := unicode.SpecialCase(nil)
+	if turkic(lang) {
+		special = unicode.TurkishCase
+	}
+	s := string(char)
+	switch mode {
+	case "upper":
+		return strings.ToUpperSpecial(special, s)
+	case "lower":
+		return strings.ToLowerSpecial(special, s)
+	case "title":
+		return strings.ToTitleSpecial(special, s)
+	}
+	return strings.ToLowerSpecial(special, strings.ToUpperSpecial(special, s))
+}
+
+// caseDifference is a character Casing maps differently from Go.
+type caseDifference struct {
+	char         rune
+	ours, theirs string
+}
+
+// compareGo transforms s like Transform and also with Go's mappings,
+// returning both results and the characters where they differ.
+func (c *Casing) compareGo(mode, s, lang string) (string, string, []caseDifference) {
+	chars := []rune(s)
+	var ours, theirs strings.Builder
+	diffs := []caseDifference{}
+	for i, char := range chars {
+		charMode := c.charMode(mode, chars, i)
+		mapped, goMapped := c.mapChar(charMode, chars, i, lang), goMapping(charMode, lang, char)
+		ours.WriteString(mapped)
+		theirs.WriteString(goMapped)
+		if mapped != goMapped {
+			diffs = append(diffs, caseDifference{char, mapped, goMapped})
+		}
+	}
+	return ours.String(), theirs.String(), diffs
+}
+
+// loadCasing builds a Casing from the configured UCD file and the
+// case file mode needs: CaseFolding.txt to fold, SpecialCasing.txt
+// otherwise.
+func (c *cli) loadCasing(mode string) (*Casing, error) {
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return nil, err
+	}
+	casing := NewCasing(ucd)
+	name, read := SpecialCasingFile, casing.ReadSpecialCasing
+	if mode == "fold" {
+		name, read = CaseFoldingFile, casing.ReadCaseFolding
+	}
+	file, err := c.openFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return casing, read(file)
+}
+
+func caseFlags(c *cli, flags *flag.FlagSet) {
+	flags.StringVar(&c.cfg.Locale, "lang", c.cfg.Locale, "apply the rules of `LANGUAGE`, such as tr, az or lt")
+	flags.BoolVar(&c.opts.compare, "compare", false, "also show Go's result and the characters where it differs")
+}
+
+func runCase(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return &UsageError{cmd.name, "missing transform: upper, lower, title or fold"}
+	}
+	mode := flags.Arg(0)
+	if err := checkCaseMode(mode); err != nil {
+		return &UsageError{cmd.name, err.Error()}
+	}
+	text := strings.Join(flags.Args()[1:], " ") + "\n"
+	if flags.NArg() == 1 {
+		input, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return err
+		}
+		text = string(input)
+	}
+	casing, err := c.loadCasing(mode)
+	if err != nil {
+		return err
+	}
+	lang := strings.ToLower(c.cfg.Locale)
+	if !c.opts.compare {
+		result, _ := casing.Transform(mode, text, lang)
+		fmt.Fprint(c.stdout, result)
+		return nil
+	}
+	ours, theirs, diffs := casing.compareGo(mode, text, lang)
+	fmt.Fprintf(c.stdout, "runescan: %s", ours)
+	fmt.Fprintf(c.stdout, "Go:       %s", theirs)
+	for _, diff := range diffs {
+		fields := sequenceFields(casing.ucd, string(diff.char))
+		fmt.Fprintf(c.stdout, "%s\t%s\t%s\trunescan %q\tGo %q\n",
+			fields[0], fields[1], fields[2], diff.ours, diff.theirs)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// casingUCD holds the UnicodeData.txt lines the case tests need.
+const casingUCD = normalizeUCD + `0049;LATIN CAPITAL LETTER I;Lu;0;L;;;;;N;;;;0069;
+00CC;LATIN CAPITAL LETTER I WITH GRAVE;Lu;0;L;0049 0300;;;;N;LATIN CAPITAL LETTER I GRAVE;;;00EC;
+00DF;LATIN SMALL LETTER SHARP S;Ll;0;L;;;;;N;;;;;
+0130;LATIN CAPITAL LETTER I WITH DOT ABOVE;Lu;0;L;0049 0307;;;;N;LATIN CAPITAL LETTER I DOT;;;0069;
+0131;LATIN SMALL LETTER DOTLESS I;Ll;0;L;;;;;N;;;0049;;0049
+01C4;LATIN CAPITAL LETTER DZ WITH CARON;Lu;0;L;<compat> 0044 017D;;;;N;LATIN CAPITAL LETTER D Z HACEK;;;01C6;01C5
+01C5;LATIN CAPITAL LETTER D WITH SMALL LETTER Z WITH CARON;Lt;0;L;<compat> 0044 017E;;;;N;LATIN LETTER CAPITAL D SMALL Z HACEK;;01C4;01C6;01C5
+01C6;LATIN SMALL LETTER DZ WITH CARON;Ll;0;L;<compat> 0064 017E;;;;N;LATIN SMALL LETTER D Z HACEK;;01C4;;01C5
+0300;COMBINING GRAVE ACCENT;Mn;230;NSM;;;;;N;NON-SPACING GRAVE;;;;
+0307;COMBINING DOT ABOVE;Mn;230;NSM;;;;;N;NON-SPACING DOT ABOVE;;;;
+0394;GREEK CAPITAL LETTER DELTA;Lu;0;L;;;;;N;;;;03B4;
+039F;GREEK CAPITAL LETTER OMICRON;Lu;0;L;;;;;N;;;;03BF;
+03A3;GREEK CAPITAL LETTER SIGMA;Lu;0;L;;;;;N;;;;03C3;
+03B4;GREEK SMALL LETTER DELTA;Ll;0;L;;;;;N;;;0394;;0394
+03BF;GREEK SMALL LETTER OMICRON;Ll;0;L;;;;;N;;;039F;;039F
+03C2;GREEK SMALL LETTER FINAL SIGMA;Ll;0;L;;;;;N;;;03A3;;03A3
+03C3;GREEK SMALL LETTER SIGMA;Ll;0;L;;;;;N;;;03A3;;03A3
+`
+
+// specialCasingSample holds lines of SpecialCasing.txt.
+const specialCasingSample = `# SpecialCasing.txt
+
+# Unconditional mappings
+00DF; 00DF; 0053 0073; 0053 0053; # LATIN SMALL LETTER SHARP S
+0130; 0069 0307; 0130; 0130; # LATIN CAPITAL LETTER I WITH DOT ABOVE
+FB01; FB01; 0046 0069; 0046 0049; # LATIN SMALL LIGATURE FI
+
+# Conditional mappings
+03A3; 03C2; 03A3; 03A3; Final_Sigma; # GREEK CAPITAL LETTER SIGMA
+
+# Lithuanian
+0307; 0307; ; ; lt After_Soft_Dotted; # COMBINING DOT ABOVE
+0049; 0069 0307; 0049; 0049; lt More_Above; # LATIN CAPITAL LETTER I
+00CC; 0069 0307 0300; 00CC; 00CC; lt; # LATIN CAPITAL LETTER I WITH GRAVE
+
+# Turkish and Azeri
+0130; 0069; 0130; 0130; tr; # LATIN CAPITAL LETTER I WITH DOT ABOVE
+0307; ; ; ; tr After_I; # COMBINING DOT ABOVE
+0049; 0131; 0049; 0049; tr Not_Before_Dot; # LATIN CAPITAL LETTER I
+0069; 0069; 0130; 0130; tr; # LATIN SMALL LETTER I
+`
+
+// caseFoldingSample holds lines of CaseFolding.txt.
+const caseFoldingSample = `# CaseFolding.txt
+0049; C; 0069; # LATIN CAPITAL LETTER I
+0049; T; 0131; # LATIN CAPITAL LETTER I
+00DF; F; 0073 0073; # LATIN SMALL LETTER SHARP S
+0130; F; 0069 0307; # LATIN CAPITAL LETTER I WITH DOT ABOVE
+0130; T; 0069; # LATIN CAPITAL LETTER I WITH DOT ABOVE
+03A3; C; 03C3; # GREEK CAPITAL LETTER SIGMA
+03C2; C; 03C3; # GREEK SMALL LETTER FINAL SIGMA
+1E9E; F; 0073 0073; # LATIN CAPITAL LETTER SHARP S
+1E9E; S; 00DF; # LATIN CAPITAL LETTER SHARP S
+`
+
+func loadCasingSample(t *testing.T) *Casing {
+	t.Helper()
+	ucd, err := LoadUCD(strings.NewReader(casingUCD), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	casing := NewCasing(ucd)
+	if err := casing.ReadSpecialCasing(strings.NewReader(specialCasingSample)); err != nil {
+		t.Fatal(err)
+	}
+	if err := casing.ReadCaseFolding(strings.NewReader(caseFoldingSample)); err != nil {
+		t.Fatal(err)
+	}
+	return casing
+}
+
+func TestCasing(t *testing.T) {
+	casing := loadCasingSample(t)
+	var testCases = []struct {
+		mode, lang string
+		text, want string
+	}{
+		{"upper", "", "ß", "SS"},
+		{"upper", "", "ﬁ", "FI"},
+		{"upper", "", "ǆ", "Ǆ"},
+		{"lower", "", "\u0130", "i\u0307"},
+		{"lower", "", "ΟΔΟΣ ΟΔΟΣ", "οδος οδος"},
+		{"lower", "", "Σ", "σ"},
+		{"lower", "", "ΟΣΟ", "οσο"},
+		{"title", "", "ǆa ma'am", "ǅa Ma'am"},
+		{"title", "", "ﬁ ß", "Fi Ss"},
+		{"fold", "", "Ißẞ", "issss"},
+		{"fold", "", "Σς", "σσ"},
+		{"lower", "tr", "I\u0130I\u0307", "\u0131ii"},
+		{"upper", "tr", "i", "İ"},
+		{"fold", "tr", "I\u0130", "\u0131i"},
+		{"lower", "lt", "I\u0300 \u00cc", "i\u0307\u0300 i\u0307\u0300"},
+		{"upper", "lt", "i\u0307", "I"},
+		{"upper", "", "i\u0307", "I\u0307"},
+	}
+	for _, tc := range testCases {
+		got, err := casing.Transform(tc.mode, tc.text, tc.lang)
+		if err != nil || got != tc.want {
+			t.Errorf("%s(%+q, %q): want %+q; got %+q (error %v)",
+				tc.mode, tc.text, tc.lang, tc.want, got, err)
+		}
+	}
+	if _, err := casing.Transform("swap", "a", ""); err == nil {
+		t.Error("want error for unknown transform")
+	}
+}
+
+func TestCasing_compareGo(t *testing.T) {
+	casing := loadCasingSample(t)
+	ours, theirs, diffs := casing.compareGo("upper", "aßﬁ", "")
+	if ours != "ASSFI" || theirs != "Aßﬁ" || len(diffs) != 2 ||
+		diffs[0] != (caseDifference{'ß', "SS", "ß"}) {
+		t.Errorf("got %+q, %+q, %+q", ours, theirs, diffs)
+	}
+	if _, _, diffs := casing.compareGo("upper", "i", "tr"); len(diffs) != 0 {
+		t.Errorf("Turkish upper case: want no differences; got %+q", diffs)
+	}
+}
+
+func TestRun_case(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: casingUCD, SpecialCasingFile: specialCasingSample,
+			CaseFoldingFile: caseFoldingSample}
+	}
+	var testCases = []struct {
+		args   []string
+		stdin  string
+		status int
+		output string
+	}{
+		{[]string{"case", "upper", "aße"}, "", exitMatch, "ASSE\n"},
+		{[]string{"case", "lower"}, "ΟΔΟΣ\n", exitMatch, "οδος\n"},
+		{[]string{"case", "-lang", "tr", "upper", "i"}, "", exitMatch, "İ\n"},
+		{[]string{"case", "fold", "ß"}, "", exitMatch, "ss\n"},
+		{[]string{"case", "-compare", "upper", "aß"}, "", exitMatch,
+			"runescan: ASS\nGo:       Aß\nU+00DF\tß\tLATIN SMALL LETTER SHARP S\trunescan \"SS\"\tGo \"ß\"\n"},
+		{[]string{"case", "swap", "a"}, "", exitUsage, ""},
+		{[]string{"search", "-c", "SIGMA"}, "", exitMatch,
+			"U+03A3\tΣ\tGREEK CAPITAL LETTER SIGMA\n\tlowercase: U+03C3 σ\n" +
+				"U+03C2\tς\tGREEK SMALL LETTER FINAL SIGMA\n\tuppercase: U+03A3 Σ\n" +
+				"U+03C3\tσ\tGREEK SMALL LETTER SIGMA\n\tuppercase: U+03A3 Σ\n"},
+		{[]string{"search", "-c", "DZ"}, "", exitMatch,
+			"U+01C4\tǄ\tLATIN CAPITAL LETTER DZ WITH CARON (LATIN CAPITAL LETTER D Z HACEK)\n" +
+				"\tlowercase: U+01C6 ǆ\n\ttitlecase: U+01C5 ǅ\n" +
+				"U+01C6\tǆ\tLATIN SMALL LETTER DZ WITH CARON (LATIN SMALL LETTER D Z HACEK)\n" +
+				"\tuppercase: U+01C4 Ǆ\n\ttitlecase: U+01C5 ǅ\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			status := run(tc.args, strings.NewReader(tc.stdin), &stdout, &stderr)
+			if status != tc.status || stdout.String() != tc.output {
+				t.Errorf("\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q",
+					tc.status, tc.output, status, stdout.String(), stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Marks of a grid for the code points that are not characters.
+const (
+	gridUnassigned = "·" // reserved for future assignment
+	gridReserved   = "×" // noncharacters and surrogates, never assigned
+)
+
+// parseCodeRange parses a range of code points such as
+// "U+2190..U+21FF", or a single one such as "U+2190".
+func parseCodeRange(arg string) (rune, rune, error) {
+	text := arg
+	if len(text) > 2 && strings.EqualFold(text[:2], "U+") {
+		text = text[2:]
+	}
+	if i := strings.Index(text, ".."); i >= 0 && len(text) > i+4 && strings.EqualFold(text[i+2:i+4], "U+") {
+		text = text[:i+2] + text[i+4:]
+	}
+	first, last, err := parseRange(text)
+	if err != nil || first < 0 || last > utf8.MaxRune {
+		return 0, 0, fmt.Errorf("invalid range %q: use U+XXXX..U+YYYY", arg)
+	}
+	return first, last, nil
+}
+
+// Assigned returns the records of the characters from first to last,
+// in code point order. Surrogates are not characters, so they are
+// left out.
+func (u *UCD) Assigned(first, last rune) []Record {
+	recs := []Record{}
+	for _, rec := range u.Records {
+		if first <= rec.Char && rec.Char <= last && rec.Category != "Cs" {
+			recs = append(recs, rec)
+		}
+	}
+	for _, rng := range u.ranges {
+		if rng.first.Category == "Cs" {
+			continue
+		}
+		for char := max(rng.first.Char, first); char <= min(rng.last, last); char++ {
+			rec, _ := u.Lookup(char)
+			recs = append(recs, rec)
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].Char < recs[j].Char })
+	return recs
+}
+
+// WriteGrid writes the code points from first to last as the code
+// charts lay them out: 16 to a row, under a header with the last hex
+// digit and after a label such as "U+219x". Characters are rendered
+// by Glyph; unassigned code points are marked with gridUnassigned,
+// noncharacters and surrogates with gridReserved, and code points
+// outside the range that share its first or last row are left blank.
+func (u *UCD) WriteGrid(w io.Writer, first, last rune) {
+	labelWidth := len(fmt.Sprintf("U+%03Xx", last>>4))
+	line := func(label string, cells []string) {
+		fields := append([]string{fmt.Sprintf("%-*s", labelWidth, label)}, cells...)
+		fmt.Fprintln(w, strings.TrimRight(strings.Join(fields, " "), " "))
+	}
+	header := make([]string, 16)
+	for col := range header {
+		header[col] = fmt.Sprintf("%-2X", col)
+	}
+	line("", header)
+	for row := first &^ 0xF; row <= last; row += 0x10 {
+		cells := make([]string, 16)
+		for col := range cells {
+			char := row + rune(col)
+			rec, ok := u.Lookup(char)
+			var glyph string
+			width := 1
+			switch {
+			case char < first || char > last:
+				glyph, width = "", 0
+			case isNoncharacter(char), ok && rec.Category == "Cs":
+				glyph = gridReserved
+			case !ok:
+				glyph = gridUnassigned
+			default:
+				glyph, width = u.Glyph(char)
+			}
+			cells[col] = glyph + strings.Repeat(" ", max(2-width, 0))
+		}
+		line(fmt.Sprintf("U+%03Xx", row>>4), cells)
+	}
+}
+
+// looseName returns name with case, spaces, hyphens and underscores
+// ignored, so "box drawing" and "Box_Drawing" find "Box Drawing".
+func looseName(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(name))
+}
+
+// findBlock returns the block named name, matched loosely.
+func (u *UCD) findBlock(name string) (blockRange, bool) {
+	for _, block := range u.blocks {
+		if looseName(block.name) == looseName(name) {
+			return block, true
+		}
+	}
+	return blockRange{}, false
+}
+
+// BlockInfo is a block as the block command lists them.
+type BlockInfo struct {
+	Name  string `json:"name"`
+	First string `json:"first"`
+	Last  string `json:"last"`
+}
+
+// listRange writes the characters from first to last, as a list of
+// results or, if grid is set, as a grid.
+func (c *cli) listRange(ucd *UCD, first, last rune, grid bool) error {
+	recs := ucd.Assigned(first, last)
+	if grid {
+		if err := c.loadDisplayData(ucd); err != nil {
+			return err
+		}
+		ucd.WriteGrid(c.stdout, first, last)
+	} else if len(recs) > 0 {
+		results := make([][3]string, len(recs))
+		for i, rec := range recs {
+			results[i] = resultFields(rec)
+		}
+		if err := c.output(results, ucd); err != nil {
+			return err
+		}
+	}
+	if len(recs) == 0 {
+		return errNoMatch
+	}
+	return nil
+}
+
+// chartFlags defines the flags range and block share.
+func chartFlags(c *cli, flags *flag.FlagSet) {
+	formatFlags(c, flags)
+	flags.BoolVar(&c.opts.grid, "grid", false, "lay out the code points 16 to a row, like the code charts, marking unassigned ones with "+
+		gridUnassigned+" and noncharacters and surrogates with "+gridReserved)
+}
+
+// parseChart parses the flags range and block share.
+func (c *cli) parseChart(cmd *command, args []string) (*flag.FlagSet, bool, error) {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return nil, false, err
+	}
+	if c.opts.grid && c.cfg.Format == formatJSON {
+		return nil, false, &UsageError{cmd.name, "-grid writes text only"}
+	}
+	return flags, c.opts.grid, nil
+}
+
+func runRange(c *cli, cmd *command, args []string) error {
+	flags, grid, err := c.parseChart(cmd, args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return &UsageError{cmd.name, "name one range, such as U+2190..U+21FF"}
+	}
+	first, last, err := parseCodeRange(flags.Arg(0))
+	if err != nil {
+		return &UsageError{cmd.name, err.Error()}
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	return c.listRange(ucd, first, last, grid)
+}
+
+func runBlock(c *cli, cmd *command, args []string) error {
+	flags, grid, err := c.parseChart(cmd, args)
+	if err != nil {
+		return err
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	if err := c.loadBlocks(ucd); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		blocks := make([]BlockInfo, len(ucd.blocks))
+		for i, block := range ucd.blocks {
+			blocks[i] = BlockInfo{block.name, fmt.Sprintf("U+%04X", block.first), fmt.Sprintf("U+%04X", block.last)}
+		}
+		if c.cfg.Format == formatJSON {
+			return encodeJSON(c.stdout, blocks)
+		}
+		for _, block := range blocks {
+			fmt.Fprintf(c.stdout, "%s..%s  %s\n", block.First, block.Last, block.Name)
+		}
+		return nil
+	}
+	name := strings.Join(flags.Args(), " ")
+	block, ok := ucd.findBlock(name)
+	if !ok {
+		return &UsageError{cmd.name, fmt.Sprintf("unknown block %q; run 'runescan block' to list them, "+
+			"or 'runescan search block %s' to search names", name, name)}
+	}
+	return c.listRange(ucd, block.first, block.last, grid)
+}
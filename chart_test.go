@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// chartUCD returns the sample records with a range of ideographs and
+// one of surrogates.
+func chartUCD(t *testing.T) *UCD {
+	t.Helper()
+	ucd, err := LoadUCD(strings.NewReader(ucdSample+
+		"4E00;<CJK Ideograph, First>;Lo;0;L;;;;;N;;;;;\n9FFF;<CJK Ideograph, Last>;Lo;0;L;;;;;N;;;;;\n"+
+		"D800;<Non Private Use High Surrogate, First>;Cs;0;L;;;;;N;;;;;\n"+
+		"DB7F;<Non Private Use High Surrogate, Last>;Cs;0;L;;;;;N;;;;;\n"), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ucd
+}
+
+func TestParseCodeRange(t *testing.T) {
+	for arg, want := range map[string][2]rune{
+		"U+2190..U+21FF": {0x2190, 0x21FF},
+		"u+2190..21ff":   {0x2190, 0x21FF},
+		"2190..U+21FF":   {0x2190, 0x21FF},
+		"U+1F600":        {0x1F600, 0x1F600},
+		"0..10FFFF":      {0, 0x10FFFF},
+	} {
+		first, last, err := parseCodeRange(arg)
+		if err != nil || [2]rune{first, last} != want {
+			t.Errorf("%q: want %X; got %X %X %v", arg, want, first, last, err)
+		}
+	}
+	for _, arg := range []string{"", "U+", "U+21FF..U+2190", "U+2190..", "U+110000", "arrows", "U+-1..U+41"} {
+		if _, _, err := parseCodeRange(arg); err == nil {
+			t.Errorf("%q: want error", arg)
+		}
+	}
+}
+
+func TestAssigned(t *testing.T) {
+	ucd := chartUCD(t)
+	var testCases = []struct {
+		first, last rune
+		want        []rune
+	}{
+		{0x3E, 0x41, []rune{'>', '?', '@', 'A'}},
+		{0, 0x30, []rune{'"', '\''}},
+		{0x9FFE, 0xD900, []rune{0x9FFE, 0x9FFF}},
+		{0x1F63C, 0x1F641, []rune{0x1F63C}},
+		{0x0378, 0x037F, []rune{}},
+	}
+	for _, tc := range testCases {
+		got := []rune{}
+		for _, rec := range ucd.Assigned(tc.first, tc.last) {
+			got = append(got, rec.Char)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("U+%04X..U+%04X:\n\twant: %U\n\tgot:  %U", tc.first, tc.last, tc.want, got)
+		}
+	}
+	if recs := ucd.Assigned(0x4E01, 0x4E01); len(recs) != 1 || recs[0].Name != "CJK UNIFIED IDEOGRAPH-4E01" {
+		t.Errorf("U+4E01: want CJK UNIFIED IDEOGRAPH-4E01; got %v", recs)
+	}
+}
+
+func TestWriteGrid(t *testing.T) {
+	ucd := chartUCD(t)
+	header := "       0  1  2  3  4  5  6  7  8  9  A  B  C  D  E  F\n"
+	var testCases = []struct {
+		first, last rune
+		want        string
+	}{
+		{0x3E, 0x41, header +
+			"U+003x" + strings.Repeat("   ", 14) + " >  ?\n" +
+			"U+004x @  A\n"},
+		{0xFFFC, 0xFFFF, header + "U+FFFx" + strings.Repeat("   ", 12) + " ·  ·  ×  ×\n"},
+		{0xDB7E, 0xDB81, header + "U+DB7x" + strings.Repeat("   ", 14) + " ×  ×\nU+DB8x ·  ·\n"},
+		{0x0300, 0x0301, header + "U+030x ·  ·\n"},
+	}
+	for _, tc := range testCases {
+		var b strings.Builder
+		ucd.WriteGrid(&b, tc.first, tc.last)
+		if got := b.String(); got != tc.want {
+			t.Errorf("U+%04X..U+%04X:\n\twant: %q\n\tgot:  %q", tc.first, tc.last, tc.want, got)
+		}
+	}
+
+	// Labels align when they grow a digit; wide characters fill their cells.
+	if err := ucd.ReadEastAsianWidth(strings.NewReader("1F600..1F64F;W\n")); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	ucd.WriteGrid(&b, 0xFFFF, 0x1F601)
+	lines := strings.Split(b.String(), "\n")
+	want := []string{"        0  1  2  3  4  5  6  7  8  9  A  B  C  D  E  F", "U+FFFx  " + strings.Repeat("   ", 15) + "×"}
+	if len(lines) != 0x1F60-0xFFF+3 || !reflect.DeepEqual(lines[:2], want) ||
+		lines[len(lines)-2] != "U+1F60x \U0001F600 \U0001F601" {
+		t.Errorf("U+FFFF..U+1F601: want %q ... %q; got %q ... %q", want, "U+1F60x \U0001F600 \U0001F601",
+			lines[:2], lines[len(lines)-2])
+	}
+}
+
+func TestRun_chart(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: ucdSample, BlocksFile: "0000..007F; Basic Latin\n1F600..1F64F; Emoticons\n",
+			EastAsianWidthFile: "1F600..1F64F;W\n", EmojiDataFile: ""}
+	}
+	var testCases = []struct {
+		args   []string
+		status int
+		output string
+	}{
+		{[]string{"range", "U+003E..U+0041"}, exitMatch, "U+003E\t>\tGREATER-THAN SIGN\n" +
+			"U+003F\t?\tQUESTION MARK\nU+0040\t@\tCOMMERCIAL AT\nU+0041\tA\tLATIN CAPITAL LETTER A\n"},
+		{[]string{"range", "-format", "json", "U+263A"}, exitMatch,
+			"[\n  {\n    \"code\": \"U+263A\",\n    \"char\": \"\u263A\",\n    \"name\": \"WHITE SMILING FACE\"\n  }\n]\n"},
+		{[]string{"range", "U+0378..U+037F"}, exitNoMatch, ""},
+		{[]string{"range", "-grid", "U+0040..U+0041"}, exitMatch,
+			"       0  1  2  3  4  5  6  7  8  9  A  B  C  D  E  F\nU+004x @  A\n"},
+		{[]string{"block"}, exitMatch, "U+0000..U+007F  Basic Latin\nU+1F600..U+1F64F  Emoticons\n"},
+		{[]string{"block", "-format", "json"}, exitMatch, `[
+  {
+    "name": "Basic Latin",
+    "first": "U+0000",
+    "last": "U+007F"
+  },
+  {
+    "name": "Emoticons",
+    "first": "U+1F600",
+    "last": "U+1F64F"
+  }
+]
+`},
+		{[]string{"block", "basic_latin"}, exitMatch, "U+0022\t\"\tQUOTATION MARK\n" +
+			"U+0027\t'\tAPOSTROPHE (APOSTROPHE-QUOTE)\n" +
+			"U+003D\t=\tEQUALS SIGN\nU+003E\t>\tGREATER-THAN SIGN\nU+003F\t?\tQUESTION MARK\n" +
+			"U+0040\t@\tCOMMERCIAL AT\nU+0041\tA\tLATIN CAPITAL LETTER A\nU+0042\tB\tLATIN CAPITAL LETTER B\n" +
+			"U+0043\tC\tLATIN CAPITAL LETTER C\nU+0060\t`\tGRAVE ACCENT (SPACING GRAVE)\n"},
+		{[]string{"block", "-grid", "Emoticons"}, exitMatch, "        0  1  2  3  4  5  6  7  8  9  A  B  C  D  E  F\n" +
+			"U+1F60x \U0001F600 \U0001F601 ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·\n" +
+			"U+1F61x ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·\n" +
+			"U+1F62x ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·\n" +
+			"U+1F63x ·  ·  ·  ·  ·  ·  ·  ·  \U0001F638 \U0001F639 \U0001F63A \U0001F63B \U0001F63C ·  ·  ·\n" +
+			"U+1F64x ·  ·  \U0001F642 ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·  ·\n"},
+		{[]string{"range"}, exitUsage, ""},
+		{[]string{"range", "U+2190", "U+21FF"}, exitUsage, ""},
+		{[]string{"range", "arrows"}, exitUsage, ""},
+		{[]string{"range", "-grid", "-format", "json", "U+0041"}, exitUsage, ""},
+		{[]string{"block", "Box Drawing"}, exitUsage, ""},
+	}
+	for _, tc := range testCases {
+		status, output, stderr := runArgs(tc.args...)
+		if status != tc.status || output != tc.output {
+			t.Errorf("%q:\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q", tc.args, tc.status, tc.output, status, output, stderr)
+		}
+	}
+	if _, _, stderr := runArgs("block", "sextant"); !strings.Contains(stderr, "'runescan search block sextant'") {
+		t.Errorf("unknown block: want a pointer to search; got %q", stderr)
+	}
+}
@@ -0,0 +1,581 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// version is the runescan release. Builds may set it with
+// -ldflags "-X main.version=1.2.3".
+var version = "devel"
+
+// cli holds the streams commands read and write, and the settings
+// shared by all commands.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	cfg    *Config
+	quiet  bool // no download progress
+	safe   bool // terminal-safe, aligned text output
+	opts   options
+}
+
+// options holds the values of the flags commands define besides the
+// shared ones. Flags of the same name, such as -font, share a field.
+type options struct {
+	q        bool    // -q: search and fixtext
+	show     details // -d, -c and -age: search
+	batch    string  // -batch: search
+	font     string  // -font: search, render and serve
+	glyphs   string  // -glyphs: search
+	tags     string  // -tags: alias
+	compare  bool    // -compare: case
+	grid     bool    // -grid: range and block
+	allow    string  // -allow: diffcheck
+	deny     string  // -deny: diffcheck
+	encoding string  // -encoding: dump
+	all      bool    // -all: coverage
+	pkg      string  // -pkg: gen
+	varName  string  // -var: gen
+	flavor   string  // -flavor: gen
+	size     int     // -size: render and serve
+	dir      string  // -o: render
+	sheet    string  // -sheet: render
+	columns  int     // -columns: render
+	form     string  // -form: normalize
+	list     bool    // -list: normalize
+	addr     string  // -addr: serve
+	min      int     // -min: index
+}
+
+// command is a runescan subcommand.
+type command struct {
+	name    string
+	args    string // synopsis of the arguments, for usage messages
+	summary string
+	run     func(c *cli, cmd *command, args []string) error
+	flags   func(c *cli, flags *flag.FlagSet) // defines the flags of the command, if it has any
+}
+
+// commands lists the subcommands in the order help shows them, and
+// hiddenCommands those help leaves out.
+// They are filled in by init, since the help command refers to them.
+var commands, hiddenCommands []*command
+
+func init() {
+	commands = []*command{
+		{"search", "[-q] [-c] [-d] [-age] [-format FORMAT] [-font FILE [-glyphs WHICH]] [-batch FILE] [WORD...]", "List characters whose names contain all the words.", runSearch, searchFlags},
+		{"info", "CHAR|U+XXXX...", "Describe characters given literally or as code points.", runInfo, nil},
+		{"fetch", "[FILE...]", "Download UCD files missing from the data directory.", runFetch, nil},
+		{"update", "[FILE...]", "Download UCD files again, replacing local copies.", runFetch, nil},
+		{"confusables", "[-format FORMAT] CHAR|U+XXXX...", "List characters that can be mistaken for the given ones.", runConfusables, formatFlags},
+		{"skeleton", "STRING [STRING]", "Show UTS #39 skeletons and whether two strings are confusable.", runSkeleton, nil},
+		{"normalize", "[-form FORM] [-list] [TEXT...]", "Convert text to a Unicode normalization form.", runNormalize, normalizeFlags},
+		{"case", "[-lang LANGUAGE] [-compare] upper|lower|title|fold [TEXT...]", "Change the case of text, or fold it for caseless matching.", runCase, caseFlags},
+		{"analyze", "[-format FORMAT] [FILE...]", "Report the scripts, categories and suspicious characters of text.", runAnalyze, formatFlags},
+		{"diffcheck", "[-format FORMAT] [-allow RULES] [-deny RULES]", "Report suspicious characters in the lines a diff on standard input adds.", runDiffcheck, diffcheckFlags},
+		{"dump", "[-format FORMAT] [-encoding ENCODING] [FILE]", "Show the bytes and code points of a file, marking encoding errors.", runDump, dumpFlags},
+		{"fixtext", "[-q] [-format FORMAT] [TEXT...]", "Repair text whose UTF-8 was decoded as Windows-1252 or Latin-1.", runFixtext, fixtextFlags},
+		{"gen", "go|rangetable|regex|css [-pkg NAME] [-var NAME] [-flavor FLAVOR] WORD...", "Write code for the characters whose names contain all the words.", runGen, genFlags},
+		{"coverage", "[-format FORMAT] [-all] FONT", "Report the blocks a font has glyphs for, and how many.", runCoverage, coverageFlags},
+		{"render", "-font FILE [-size N] [-o DIR | -sheet FILE [-columns N]] WORD...", "Draw the glyphs of the characters whose names contain all the words as PNG images.", runRender, renderFlags},
+		{"serve", "[-addr HOST:PORT] [-font FILE [-size N]]", "Serve a search page, and glyph images, over HTTP.", runServe, serveFlags},
+		{"range", "[-grid] [-format FORMAT] U+XXXX..U+YYYY", "List the characters in a range of code points.", runRange, chartFlags},
+		{"block", "[-grid] [-format FORMAT] [NAME]", "List the characters in a block, or the blocks.", runBlock, chartFlags},
+		{"lsp", "", "Run a Language Server Protocol server on standard input and output.", runLSP, nil},
+		{"age", "[-format FORMAT] [TEXT...]", "Report the version of Unicode that text, or standard input, needs.", runAge, formatFlags},
+		{"index", "[-min N] [PREFIX]", "List the words used in character names, most frequent first.", runIndex, indexFlags},
+		{"alias", "[-tags TAGS] [-format FORMAT] [NAME [TEXT...]]", "List user aliases, or define one for a character or sequence.", runAlias, aliasFlags},
+		{"unalias", "NAME...", "Remove user aliases.", runUnalias, nil},
+		{"completion", "bash|zsh|fish", "Print a shell completion script.", runCompletion, nil},
+		{"version", "", "Print the runescan version.", runVersion, nil},
+		{"help", "[COMMAND]", "Show help for runescan or one of its commands.", runHelp, nil},
+	}
+	hiddenCommands = []*command{
+		{completeCommand, "WORD...", "List completions for the last word.", runComplete, nil},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range append(commands, hiddenCommands...) {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// run executes runescan with the command-line arguments in args and
+// returns the exit status, as documented in errors.go. Arguments
+// that do not start with a command name are a search query.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+	var cmd *command
+	if len(args) > 0 {
+		if cmd = findCommand(args[0]); cmd != nil {
+			args = args[1:]
+		}
+	}
+	// Help needs no settings, so it works even with a broken config.
+	help := len(args) == 0 && cmd == nil || len(args) > 0 && isHelp(args[0]) || cmd != nil && cmd.name == "help"
+	cfg, err := loadConfig(os.Getenv)
+	if err != nil && !help {
+		fmt.Fprintln(stderr, "runescan:", err)
+		return exitCode(err)
+	}
+	if err != nil {
+		cfg = defaultConfig(os.Getenv)
+	}
+	c.cfg = cfg
+	switch {
+	case cmd != nil:
+	case len(args) == 0:
+		c.usage(stderr)
+		return exitUsage
+	case isHelp(args[0]):
+		c.usage(stdout)
+		return exitMatch
+	default:
+		cmd = findCommand("search")
+	}
+	err = cmd.run(c, cmd, args)
+	var usageErr *UsageError
+	switch {
+	case err == nil, errors.Is(err, errNoMatch), errors.Is(err, errFindings), errors.Is(err, errFlags),
+		errors.Is(err, flag.ErrHelp):
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "runescan %s\nRun 'runescan help %s' for usage.\n",
+			usageErr, usageErr.Command)
+	default:
+		fmt.Fprintln(stderr, "runescan:", err)
+	}
+	return exitCode(err)
+}
+
+// isHelp reports whether arg is a flag asking for help, such as "-h".
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// usage writes the list of commands to w.
+func (c *cli) usage(w io.Writer) {
+	fmt.Fprint(w, "usage: runescan [COMMAND] [FLAGS] [ARGS]\n\n"+
+		"Find Unicode characters by name. Without a command,\n"+
+		"the arguments are a search query.\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// flagSet returns a flag set for cmd with the flags all commands
+// share and its own, reporting errors and usage on stderr.
+func (c *cli) flagSet(cmd *command) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() { c.commandUsage(flags.Output(), cmd, flags) }
+	flags.BoolVar(&c.quiet, "quiet", false, "do not report download progress")
+	flags.StringVar(&c.cfg.Unicode, "unicode", c.cfg.Unicode, "use data for Unicode `VERSION`, such as 15.1.0")
+	flags.BoolVar(&c.safe, "safe", isTerminal(c.stdout), "render characters safely, in aligned columns; the default on terminals")
+	if cmd.flags != nil {
+		cmd.flags(c, flags)
+	}
+	return flags
+}
+
+// formatFlags defines -format, for commands with no other flags.
+func formatFlags(c *cli, flags *flag.FlagSet) {
+	flags.StringVar(&c.cfg.Format, "format", c.cfg.Format, "output `FORMAT`: text or json")
+}
+
+// progress returns the ProgressFunc for downloads, reporting on
+// stderr, or nil in quiet mode.
+func (c *cli) progress() ProgressFunc {
+	if c.quiet {
+		return nil
+	}
+	return progressTo(c.stderr)
+}
+
+// openFile opens a data file, such as "Blocks.txt", from the
+// configured data directory or the mirrors.
+func (c *cli) openFile(name string) (io.ReadCloser, error) {
+	c.resolveLatest(false)
+	return openUCD(newSource(c.cfg, c.progress()), name)
+}
+
+// resolveLatest records the version "latest" stands for on the
+// mirrors, unless one is recorded already and refresh is not set. If
+// the mirrors do not tell, data files stay under "latest".
+func (c *cli) resolveLatest(refresh bool) {
+	if c.cfg.UCDPath != "" || c.cfg.Unicode != "latest" || c.cfg.version() != "latest" && !refresh {
+		return
+	}
+	if version := latestVersion(ucdMirrors); version != "" {
+		if err := c.cfg.recordLatest(version); err != nil {
+			fmt.Fprintf(c.stderr, "runescan: %v; data files stay under latest\n", err)
+		}
+	}
+}
+
+// openData opens the configured UCD file, returning it with the
+// ScanFunc that reads its format.
+func (c *cli) openData() (io.ReadCloser, ScanFunc, error) {
+	name, scan := ucdFormat(c.cfg.DataPath())
+	ucd, err := c.openFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ucd, scan, nil
+}
+
+// loadUCD reads and indexes the whole configured UCD file.
+func (c *cli) loadUCD() (*UCD, error) {
+	text, scan, err := c.openData()
+	if err != nil {
+		return nil, err
+	}
+	defer text.Close()
+	return LoadUCD(text, scan)
+}
+
+// commandUsage writes the help text of cmd to w.
+func (c *cli) commandUsage(w io.Writer, cmd *command, flags *flag.FlagSet) {
+	fmt.Fprintf(w, "usage: runescan %s %s\n\n%s\n", cmd.name, cmd.args, cmd.summary)
+	hasFlags := false
+	flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprint(w, "\nflags:\n")
+		flags.SetOutput(w)
+		flags.PrintDefaults()
+		flags.SetOutput(c.stderr)
+	}
+}
+
+// parse parses args with flags. Help requests print the usage to
+// stdout, since they are not errors.
+func (c *cli) parse(flags *flag.FlagSet, args []string) error {
+	flags.SetOutput(io.Discard)
+	err := flags.Parse(args)
+	flags.SetOutput(c.stderr)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		flags.SetOutput(c.stdout)
+		flags.Usage()
+		flags.SetOutput(c.stderr)
+		return err
+	case err != nil:
+		fmt.Fprintf(c.stderr, "runescan %s: %v\n", flags.Name(), err)
+		flags.Usage()
+		return errFlags
+	}
+	if err := c.cfg.check(); err != nil {
+		return &UsageError{flags.Name(), err.Error()}
+	}
+	return nil
+}
+
+func searchFlags(c *cli, flags *flag.FlagSet) {
+	flags.BoolVar(&c.opts.q, "q", false, "quiet: only set the exit status; implies -quiet")
+	formatFlags(c, flags)
+	flags.BoolVar(&c.opts.show.decompositions, "d", false, "show the full decompositions of the characters found")
+	flags.BoolVar(&c.opts.show.casePartners, "c", false, "show the case partners of the characters found")
+	flags.BoolVar(&c.opts.show.ages, "age", false, "show the version of Unicode that added the characters found")
+	flags.StringVar(&c.opts.batch, "batch", "", "run the queries in `FILE`, one per line; - for standard input")
+	flags.StringVar(&c.opts.font, "font", "", "mark the characters the TrueType or OpenType font in `FILE` has no glyph for")
+	flags.StringVar(&c.opts.glyphs, "glyphs", glyphsAll, "with -font, list `WHICH` characters: all, covered or missing")
+}
+
+func runSearch(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if err := checkGlyphs(cmd, c.opts.glyphs, c.opts.font); err != nil {
+		return err
+	}
+	c.quiet = c.quiet || c.opts.q
+	if c.opts.batch != "" {
+		if flags.NArg() > 0 {
+			return &UsageError{cmd.name, "query words and -batch are exclusive"}
+		}
+		return c.runBatch(cmd, c.opts.batch, c.opts.show, c.opts.q)
+	}
+	query := strings.Join(flags.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		return &UsageError{cmd.name, "missing query words"}
+	}
+	query = strings.Join(append([]string{query}, c.cfg.Filters...), " ")
+	_, ages, err := parseQuery(query)
+	if err != nil {
+		return &UsageError{cmd.name, err.Error()}
+	}
+	font, err := c.loadFont(cmd, c.opts.font)
+	if err != nil {
+		return err
+	}
+	// Only the loaded UCD has details and ages; plain searches stream.
+	if c.opts.show.decompositions || c.opts.show.casePartners || c.opts.show.ages || len(ages) > 0 {
+		ucd, err := c.loadSearchUCD()
+		if err != nil {
+			return err
+		}
+		if c.opts.show.ages || len(ages) > 0 {
+			if err := c.loadAges(ucd); err != nil {
+				return err
+			}
+		}
+		results := ucd.Filter(query)
+		if font != nil {
+			results = font.MarkGlyphs(results, c.opts.glyphs)
+		}
+		if len(results) == 0 {
+			return errNoMatch
+		}
+		if c.opts.q {
+			return nil
+		}
+		return displayDetails(c.stdout, c.cfg.Format, ucd, results, c.opts.show)
+	}
+	text, scan, err := c.openData()
+	if err != nil {
+		return err
+	}
+	defer text.Close()
+	results, err := filterScan(text, scan, query)
+	if err != nil {
+		return err
+	}
+	aliases, err := c.loadAliases()
+	if err != nil {
+		return err
+	}
+	results = append(results, aliases.Filter(query)...)
+	if font != nil {
+		results = font.MarkGlyphs(results, c.opts.glyphs)
+	}
+	if len(results) == 0 {
+		return errNoMatch
+	}
+	if c.opts.q {
+		return nil
+	}
+	return c.output(results, nil)
+}
+
+// parseChars returns the characters named by args, each either a
+// code point such as "U+00E9" or literal text.
+func parseChars(args []string) ([]rune, error) {
+	chars := []rune{}
+	for _, arg := range args {
+		if len(arg) > 2 && strings.EqualFold(arg[:2], "U+") {
+			code, err := strconv.ParseUint(arg[2:], 16, 32)
+			if err != nil || code > utf8.MaxRune {
+				return nil, fmt.Errorf("invalid code point %q", arg)
+			}
+			chars = append(chars, rune(code))
+			continue
+		}
+		chars = append(chars, []rune(arg)...)
+	}
+	return chars, nil
+}
+
+func runInfo(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	chars, err := parseChars(flags.Args())
+	if err != nil {
+		return &UsageError{cmd.name, err.Error()}
+	}
+	if len(chars) == 0 {
+		return &UsageError{cmd.name, "missing characters"}
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	if err := c.loadAgesIfAvailable(ucd); err != nil {
+		return err
+	}
+	found := 0
+	for _, char := range chars {
+		rec, ok := ucd.Lookup(char)
+		if !ok {
+			fmt.Fprintf(c.stdout, "U+%04X\t%s\n", char, ucd.Name(char))
+			continue
+		}
+		found++
+		describe(c.stdout, rec)
+	}
+	if found == 0 {
+		return errNoMatch
+	}
+	return nil
+}
+
+// describe writes the properties of rec, one per line, leaving out
+// those that are empty.
+func describe(w io.Writer, rec Record) {
+	display(w, [][3]string{resultFields(rec)})
+	property := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "\t%s: %s\n", name, value)
+		}
+	}
+	property("category", rec.Category)
+	property("combining class", strconv.Itoa(rec.CombiningClass))
+	property("bidi class", rec.BidiClass)
+	if rec.Mirrored {
+		property("mirrored", "yes")
+	}
+	property("decomposition", rec.Decomposition)
+	property("numeric value", rec.Numeric)
+	property("uppercase", mappingText(rec.Upper))
+	property("lowercase", mappingText(rec.Lower))
+	if rec.Title != rec.Upper {
+		property("titlecase", mappingText(rec.Title))
+	}
+	property("block", rec.Block)
+	property("script", rec.Script)
+	property("age", rec.Age)
+}
+
+func runFetch(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	names := flags.Args()
+	c.resolveLatest(cmd.name == "update")
+	ucdName, _ := ucdFormat(c.cfg.DataPath())
+	if len(names) == 0 {
+		names = append([]string{ucdName}, c.cfg.DataFiles...)
+	}
+	local := func(name string) string {
+		if name == ucdName {
+			return c.cfg.DataPath()
+		}
+		return filepath.Join(c.cfg.DataDir(), name)
+	}
+	replace := cmd.name == "update"
+	for _, name := range names {
+		path := local(name)
+		if _, err := os.Stat(path); err == nil && !replace {
+			fmt.Fprintf(c.stdout, "%s: already in %s\n", name, path)
+			continue
+		}
+		// Download next to the old copy, so a failure leaves it intact.
+		src := &HTTPSource{
+			Mirrors:  c.cfg.MirrorURLs(),
+			Cache:    func(string) string { return path + ".download" },
+			Progress: c.progress(),
+		}
+		file, err := src.Open(name)
+		if err != nil {
+			return err
+		}
+		file.Close()
+		if err := os.Rename(path+".download", path); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "%s: saved to %s\n", name, path)
+	}
+	return nil
+}
+
+func indexFlags(c *cli, flags *flag.FlagSet) {
+	flags.IntVar(&c.opts.min, "min", 1, "list only words used in at least `N` names")
+}
+
+func runIndex(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return &UsageError{cmd.name, "too many arguments"}
+	}
+	prefix := strings.ToUpper(flags.Arg(0))
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, wc := range ucd.WordCounts() {
+		if wc.Count >= c.opts.min && strings.HasPrefix(wc.Word, prefix) {
+			fmt.Fprintf(c.stdout, "%s\t%d\n", wc.Word, wc.Count)
+			found = true
+		}
+	}
+	if !found {
+		return errNoMatch
+	}
+	return nil
+}
+
+// WordCount is a word used in character names and the number of
+// characters whose names use it.
+type WordCount struct {
+	Word  string
+	Count int
+}
+
+// WordCounts returns the words of all names, as split for searching,
+// most frequent first and then in alphabetical order.
+func (u *UCD) WordCounts() []WordCount {
+	counts := map[string]int{}
+	for _, rec := range u.Records {
+		for _, word := range rec.WordList() {
+			counts[word]++
+		}
+	}
+	result := make([]WordCount, 0, len(counts))
+	for word, count := range counts {
+		result = append(result, WordCount{word, count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Word < result[j].Word
+	})
+	return result
+}
+
+func runVersion(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "runescan", version)
+	return nil
+}
+
+func runHelp(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	switch flags.NArg() {
+	case 0:
+		c.usage(c.stdout)
+		return nil
+	case 1:
+		if target := findCommand(flags.Arg(0)); target != nil {
+			return target.run(c, target, []string{"-h"})
+		}
+		return &UsageError{cmd.name, fmt.Sprintf("unknown command %q", flags.Arg(0))}
+	}
+	return &UsageError{cmd.name, "too many arguments"}
+}
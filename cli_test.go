@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runArgs(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(""), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRun_commands(t *testing.T) {
+	var testCases = []struct {
+		args   []string
+		status int
+		output string
+	}{
+		{[]string{"search", "cruzeiro"}, exitMatch, "U+20A2\t₢\tCRUZEIRO SIGN\n"},
+		{[]string{"cruzeiro"}, exitMatch, "U+20A2\t₢\tCRUZEIRO SIGN\n"},
+		{[]string{"search", "-q", "cruzeiro"}, exitMatch, ""},
+		{[]string{"search", "nothing"}, exitNoMatch, ""},
+		{[]string{"info", "U+0041"}, exitMatch,
+			"U+0041\tA\tLATIN CAPITAL LETTER A\n" +
+				"\tcategory: Lu\n\tcombining class: 0\n\tbidi class: L\n" +
+				"\tlowercase: U+0061 a\n"},
+		{[]string{"info", "₢"}, exitMatch,
+			"U+20A2\t₢\tCRUZEIRO SIGN\n" +
+				"\tcategory: Sc\n\tcombining class: 0\n\tbidi class: ET\n"},
+		{[]string{"info", "U+0378"}, exitNoMatch, "U+0378\t<unassigned-0378>\n"},
+		{[]string{"index", "SMIL"}, exitMatch, "SMILING\t6\nSMILE\t1\n"},
+		{[]string{"index", "-min", "7", "SMIL"}, exitNoMatch, ""},
+		{[]string{"version"}, exitMatch, "runescan devel\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			status, output, stderr := runArgs(tc.args...)
+			if status != tc.status || output != tc.output {
+				t.Errorf("\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q",
+					tc.status, tc.output, status, output, stderr)
+			}
+		})
+	}
+}
+
+func TestRun_usage(t *testing.T) {
+	var testCases = []struct {
+		args   []string
+		status int
+		stderr string
+	}{
+		{[]string{}, exitUsage, "usage: runescan"},
+		{[]string{"search"}, exitUsage, "runescan search: missing query words"},
+		{[]string{""}, exitUsage, "runescan search: missing query words"},
+		{[]string{"info"}, exitUsage, "runescan info: missing characters"},
+		{[]string{"info", "U+XYZ"}, exitUsage, `invalid code point "U+XYZ"`},
+		{[]string{"search", "-x", "cat"}, exitUsage, "flag provided but not defined: -x"},
+		{[]string{"help", "nosuch"}, exitUsage, `unknown command "nosuch"`},
+	}
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			status, _, stderr := runArgs(tc.args...)
+			if status != tc.status || !strings.Contains(stderr, tc.stderr) {
+				t.Errorf("\n\twant: %d %q\n\tgot:  %d %q", tc.status, tc.stderr, status, stderr)
+			}
+		})
+	}
+}
+
+func TestRun_help(t *testing.T) {
+	for _, args := range [][]string{
+		{"--help"}, {"-h"}, {"help"},
+	} {
+		status, output, _ := runArgs(args...)
+		if status != exitMatch || !strings.Contains(output, "commands:") {
+			t.Errorf("%q: want command list; got: %d %q", args, status, output)
+		}
+	}
+	for _, args := range [][]string{
+		{"help", "search"}, {"search", "-h"}, {"search", "--help"},
+	} {
+		status, output, _ := runArgs(args...)
+		if status != exitMatch || !strings.Contains(output, "usage: runescan search [-q] [-c] [-d] [-age] [-format FORMAT] [-font FILE [-glyphs WHICH]] [-batch FILE] [WORD...]") ||
+			!strings.Contains(output, "-q\tquiet") {
+			t.Errorf("%q: want search usage; got: %d %q", args, status, output)
+		}
+	}
+
+	config := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(config, []byte("unicode = [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RUNESCAN_CONFIG", config)
+	for _, args := range [][]string{{"help"}, {"-h"}, {"help", "search"}, {"search", "-h"}} {
+		if status, output, _ := runArgs(args...); status != exitMatch || !strings.Contains(output, "usage: runescan") {
+			t.Errorf("%q with a broken config: want usage; got: %d %q", args, status, output)
+		}
+	}
+	if status, _, stderr := runArgs("cat"); status != exitData || !strings.Contains(stderr, config) {
+		t.Errorf("search with a broken config: want status %d and the config error; got %d %q", exitData, status, stderr)
+	}
+}
+
+func TestServer(t *testing.T) {
+	ucd, err := LoadUCD(strings.NewReader(ucdSample), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newServer(ucd, nil))
+	defer srv.Close()
+	response, err := srv.Client().Get(srv.URL + "/?q=cat+smiling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	for _, want := range []string{"3 found", "1F63A", "GRINNING CAT FACE WITH SMILING EYES"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("page does not contain %q:\n%s", want, body)
+		}
+	}
+}
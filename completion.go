@@ -0,0 +1,263 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// completeCommand is the hidden command the completion scripts run
+// with the words typed so far, the last one being completed.
+const completeCommand = "__complete"
+
+// completionLimit is the most name words __complete lists, so
+// completing a short prefix stays fast and readable.
+const completionLimit = 50
+
+// flagValues lists the values of flags that take one of a few.
+var flagValues = map[string][]string{
+	"format":   {formatText, formatJSON},
+	"form":     {"NFC", "NFD", "NFKC", "NFKD"},
+	"encoding": encodings,
+	"glyphs":   {glyphsAll, glyphsCovered, glyphsMissing},
+}
+
+// pathValues are the names of flag values that complete as paths.
+var pathValues = map[string]bool{"FILE": true, "DIR": true}
+
+// commandFlags returns the flags of cmd, including those all commands
+// share, mapped to the name of their value, such as "FORMAT", or to
+// "" for boolean flags.
+func (c *cli) commandFlags(cmd *command) map[string]string {
+	flags := map[string]string{}
+	c.flagSet(cmd).VisitAll(func(f *flag.Flag) {
+		flags["-"+f.Name], _ = flag.UnquoteUsage(f)
+	})
+	return flags
+}
+
+// complete returns the completions for the last of words, which
+// follow "runescan" on the command line: commands, flags, flag
+// values, paths, or the name words that start with it, most frequent
+// first, which words lists.
+func (c *cli) complete(args []string, words func(prefix string) ([]string, error)) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	prefix, before := args[len(args)-1], args[:len(args)-1]
+	cmd := findCommand("search")
+	if len(before) > 0 {
+		if named := findCommand(before[0]); named != nil {
+			cmd = named
+		}
+	}
+	candidates := []string{}
+	flags := c.commandFlags(cmd)
+	if n := len(before); n > 0 {
+		name := strings.TrimLeft(before[n-1], "-")
+		switch value := flags["-"+name]; {
+		case pathValues[value]:
+			return paths(prefix, value == "DIR"), nil
+		case value != "":
+			return matching(flagValues[name], prefix), nil
+		}
+	}
+	switch {
+	case strings.HasPrefix(prefix, "-"):
+		for name := range flags {
+			candidates = append(candidates, name)
+		}
+		sort.Strings(candidates)
+		return matching(candidates, "-"+strings.TrimLeft(prefix, "-")), nil
+	case len(before) == 1 && cmd.name == "help":
+		return matching(commandNames(), prefix), nil
+	case len(before) == 1 && cmd.name == "completion":
+		return matching(completionShells, prefix), nil
+	case len(before) == 0:
+		candidates = matching(commandNames(), prefix)
+	case cmd.name != "search" && cmd.name != "index":
+		return candidates, nil
+	}
+	found, err := words(prefix)
+	return append(candidates, found...), err
+}
+
+func commandNames() []string {
+	names := []string{}
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+// paths returns the files and directories whose paths start with
+// prefix, or only the directories if dirs is set. Directories end in
+// a separator, so completion can go on into them. Hidden files are
+// left out unless prefix names them.
+func paths(prefix string, dirs bool) []string {
+	dir, base := filepath.Split(prefix)
+	root := dir
+	if root == "" {
+		root = "."
+	}
+	entries, err := os.ReadDir(root)
+	result := []string{}
+	if err != nil {
+		return result
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			result = append(result, dir+name+string(filepath.Separator))
+		} else if !dirs {
+			result = append(result, dir+name)
+		}
+	}
+	return result
+}
+
+// matching returns the candidates that start with prefix.
+func matching(candidates []string, prefix string) []string {
+	result := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			result = append(result, candidate)
+		}
+	}
+	return result
+}
+
+// nameWords returns up to limit words of the names in a UCD file,
+// as WordList splits them, that start with prefix, ignoring case,
+// most frequent first. They are in lower case if prefix is, so shells
+// that match case keep them.
+func nameWords(r io.Reader, scan ScanFunc, prefix string, limit int) ([]string, error) {
+	upper := strings.ToUpper(prefix)
+	counts := map[string]int{}
+	err := scan(r, func(rec Record) error {
+		if rec.IsRangeMarker() {
+			return nil
+		}
+		for _, word := range rec.WordList() {
+			if strings.HasPrefix(word, upper) {
+				counts[word]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	words := make([]string, 0, len(counts))
+	for word := range counts {
+		words = append(words, word)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if counts[words[i]] != counts[words[j]] {
+			return counts[words[i]] > counts[words[j]]
+		}
+		return words[i] < words[j]
+	})
+	if len(words) > limit {
+		words = words[:limit]
+	}
+	if strings.IndexFunc(prefix, unicode.IsLower) >= 0 && strings.IndexFunc(prefix, unicode.IsUpper) < 0 {
+		for i := range words {
+			words[i] = strings.ToLower(words[i])
+		}
+	}
+	return words, nil
+}
+
+// localSource returns src without the layers that download, so
+// completion never waits on the network.
+func localSource(src DataSource) DataSource {
+	layers, ok := src.(LayeredSource)
+	if !ok {
+		return src
+	}
+	local := LayeredSource{}
+	for _, layer := range layers {
+		if _, remote := layer.(*HTTPSource); !remote {
+			local = append(local, layer)
+		}
+	}
+	return local
+}
+
+func runComplete(c *cli, cmd *command, args []string) error {
+	candidates, err := c.complete(args, func(prefix string) ([]string, error) {
+		name, scan := ucdFormat(c.cfg.DataPath())
+		file, err := openUCD(localSource(newSource(c.cfg, nil)), name)
+		if err != nil {
+			return nil, nil // no words until the UCD is downloaded
+		}
+		defer file.Close()
+		return nameWords(file, scan, prefix, completionLimit)
+	})
+	if err != nil {
+		return err
+	}
+	for _, candidate := range candidates {
+		fmt.Fprintln(c.stdout, candidate)
+	}
+	return nil
+}
+
+// completionShells are the shells completion writes scripts for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionScripts hold the completion script for each shell. They
+// pass the words typed so far to the hidden __complete command.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for runescan; add to ~/.bashrc:
+#   source <(runescan completion bash)
+_runescan() {
+    local IFS=$'\n'
+    COMPREPLY=($(runescan __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _runescan runescan
+`,
+	"zsh": `#compdef runescan
+# zsh completion for runescan; add to ~/.zshrc:
+#   source <(runescan completion zsh)
+_runescan() {
+    local -a candidates
+    candidates=("${(@f)$(runescan __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    compadd -a candidates
+}
+compdef _runescan runescan
+`,
+	"fish": `# fish completion for runescan; save as
+#   ~/.config/fish/completions/runescan.fish
+function __runescan_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    runescan __complete $tokens[2..-1] 2>/dev/null
+end
+complete -c runescan -f -a '(__runescan_complete)'
+`,
+}
+
+func runCompletion(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return &UsageError{cmd.name, "name one shell: " + strings.Join(completionShells, ", ")}
+	}
+	script, ok := completionScripts[flags.Arg(0)]
+	if !ok {
+		return &UsageError{cmd.name, fmt.Sprintf("unknown shell %q", flags.Arg(0))}
+	}
+	fmt.Fprint(c.stdout, script)
+	return nil
+}
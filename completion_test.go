@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCLI_complete(t *testing.T) {
+	words := func(prefix string) ([]string, error) {
+		return []string{"<" + prefix + ">"}, nil
+	}
+	dir := t.TempDir() + string(filepath.Separator)
+	for _, name := range []string{"font.ttf", "sheet.png", ".hidden.png"} {
+		if err := os.WriteFile(dir+name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(dir+"fonts", 0755); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{}, append(commandNames(), "<>")},
+		{[]string{"se"}, []string{"search", "serve", "<se>"}},
+		{[]string{"search", "sm"}, []string{"<sm>"}},
+		{[]string{"face", "sm"}, []string{"<sm>"}},
+		{[]string{"index", "sm"}, []string{"<sm>"}},
+		{[]string{"info", "sm"}, []string{}},
+		{[]string{"search", "-f"}, []string{"-font", "-format"}},
+		{[]string{"search", "--f"}, []string{"-font", "-format"}},
+		{[]string{"search", "-format", ""}, []string{"text", "json"}},
+		{[]string{"search", "--format", "j"}, []string{"json"}},
+		{[]string{"search", "-q", "sm"}, []string{"<sm>"}},
+		{[]string{"search", "-batch", dir + "x"}, []string{}},
+		{[]string{"search", "-font", dir + "f"}, []string{dir + "font.ttf", dir + "fonts" + string(filepath.Separator)}},
+		{[]string{"search", "-font", dir + "font.ttf", "-glyphs", "m"}, []string{"missing"}},
+		{[]string{"render", "-sheet", dir}, []string{dir + "font.ttf", dir + "fonts" + string(filepath.Separator), dir + "sheet.png"}},
+		{[]string{"render", "-sheet", dir + "."}, []string{dir + ".hidden.png"}},
+		{[]string{"render", "-o", dir}, []string{dir + "fonts" + string(filepath.Separator)}},
+		{[]string{"dump", "-encoding", "UTF-32"}, []string{"UTF-32LE", "UTF-32BE"}},
+		{[]string{"help", "f"}, []string{"fetch", "fixtext"}},
+		{[]string{"completion", ""}, []string{"bash", "zsh", "fish"}},
+	}
+	c := &cli{cfg: &Config{}}
+	for _, tc := range testCases {
+		got, err := c.complete(tc.args, words)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q:\n\twant: %q\n\tgot:  %q, %v", tc.args, tc.want, got, err)
+		}
+	}
+}
+
+func TestNameWords(t *testing.T) {
+	testCases := []struct {
+		prefix string
+		limit  int
+		want   []string
+	}{
+		{"SMIL", 10, []string{"SMILING", "SMILE"}},
+		{"smil", 10, []string{"smiling", "smile"}},
+		{"Smil", 1, []string{"SMILING"}},
+		{"XYZ", 10, []string{}},
+	}
+	for _, tc := range testCases {
+		got, err := nameWords(strings.NewReader(ucdSample), ScanText, tc.prefix, tc.limit)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q %d:\n\twant: %q\n\tgot:  %q, %v", tc.prefix, tc.limit, tc.want, got, err)
+		}
+	}
+}
+
+func TestRun_completion(t *testing.T) {
+	status, output, stderr := runArgs(completeCommand, "search", "smil")
+	if want := "smiling\nsmile\n"; status != exitMatch || output != want {
+		t.Errorf("\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q", exitMatch, want, status, output, stderr)
+	}
+	for _, shell := range completionShells {
+		status, output, _ := runArgs("completion", shell)
+		if status != exitMatch || !strings.Contains(output, completeCommand) {
+			t.Errorf("%s: want a script running %s; got: %d %q", shell, completeCommand, status, output)
+		}
+	}
+	status, _, stderr = runArgs("completion", "tcsh")
+	if want := `unknown shell "tcsh"`; status != exitUsage || !strings.Contains(stderr, want) {
+		t.Errorf("\n\twant: %d %q\n\tgot:  %d %q", exitUsage, want, status, stderr)
+	}
+	if _, output, _ := runArgs("help"); strings.Contains(output, completeCommand) {
+		t.Errorf("help lists %s: %q", completeCommand, output)
+	}
+}
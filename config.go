@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config holds the runescan settings. They come from, in increasing
+// order of precedence: built-in defaults, the config file, the
+// environment and command-line flags.
+//
+// The config file is $XDG_CONFIG_HOME/runescan/config.toml, or the
+// file named by RUNESCAN_CONFIG, and the aliases file is aliases.toml
+// beside it. Data files are kept in
+// $XDG_CACHE_HOME/runescan/<unicode-version>/ unless UCD_PATH names
+// the UCD file; "latest" is kept under the version it stands for.
+type Config struct {
+	UCDPath   string   // UCD file: ucd_path, UCD_PATH
+	CacheDir  string   // data directory root: $XDG_CACHE_HOME/runescan
+	Unicode   string   // Unicode version: unicode, RUNESCAN_UNICODE, -unicode
+	Format    string   // output format: format, RUNESCAN_FORMAT, -format
+	Mirrors   []string // download base URLs: mirrors, RUNESCAN_MIRRORS
+	Locale    string   // language for case mapping: locale, RUNESCAN_LOCALE
+	DataFiles []string // extra files fetch downloads: data_files
+	Filters   []string // terms added to every search: filters
+	Allow     []string // characters diffcheck accepts: [diffcheck] allow, -allow
+	Deny      []string // characters diffcheck reports: [diffcheck] deny, -deny
+
+	AliasesPath string // user aliases file: aliases_path, RUNESCAN_ALIASES
+}
+
+// Output formats.
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// defaultConfig returns the built-in settings. The locale defaults
+// to the language of the POSIX locale, if any.
+func defaultConfig(getenv func(string) string) *Config {
+	cfg := &Config{
+		CacheDir: filepath.Join(xdgDir(getenv, "XDG_CACHE_HOME", ".cache"), "runescan"),
+		Unicode:  "latest",
+		Format:   formatText,
+
+		AliasesPath: filepath.Join(filepath.Dir(configPath(getenv)), "aliases.toml"),
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if lang := getenv(name); lang != "" {
+			// The language is what comes before the territory, codeset
+			// or modifier, as in "pt_BR.UTF-8"; "C.UTF-8" has none.
+			fields := strings.FieldsFunc(lang, func(r rune) bool {
+				return r == '_' || r == '.' || r == '@' || r == '-'
+			})
+			if len(fields) > 0 && fields[0] != "C" && fields[0] != "POSIX" {
+				cfg.Locale = strings.ToLower(fields[0])
+			}
+			break
+		}
+	}
+	return cfg
+}
+
+// xdgDir returns the directory named by the XDG variable, or the
+// fallback under the home directory.
+func xdgDir(getenv func(string) string, variable, fallback string) string {
+	if dir := getenv(variable); filepath.IsAbs(dir) {
+		return dir
+	}
+	home := getenv("HOME")
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	return filepath.Join(home, fallback)
+}
+
+// configPath returns the path of the config file.
+func configPath(getenv func(string) string) string {
+	if path := getenv("RUNESCAN_CONFIG"); path != "" {
+		return path
+	}
+	return filepath.Join(xdgDir(getenv, "XDG_CONFIG_HOME", ".config"), "runescan", "config.toml")
+}
+
+// loadConfig returns the settings from the defaults, the config file
+// and the environment. A missing config file is not an error.
+func loadConfig(getenv func(string) string) (*Config, error) {
+	cfg := defaultConfig(getenv)
+	path := configPath(getenv)
+	file, err := os.Open(path)
+	switch {
+	case err == nil:
+		err = cfg.read(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+	cfg.applyEnv(getenv)
+	return cfg, cfg.check()
+}
+
+// read applies the settings in a config file.
+func (cfg *Config) read(r io.Reader) error {
+	values, err := parseTOML(r)
+	if err != nil {
+		return err
+	}
+	for _, key := range tomlKeys(values) {
+		value := values[key]
+		var err error
+		switch key {
+		case "ucd_path":
+			cfg.UCDPath, err = value.str()
+		case "aliases_path":
+			cfg.AliasesPath, err = value.str()
+		case "unicode":
+			cfg.Unicode, err = value.str()
+		case "format":
+			cfg.Format, err = value.str()
+		case "locale":
+			cfg.Locale, err = value.str()
+		case "mirrors":
+			cfg.Mirrors, err = value.list()
+		case "data_files":
+			cfg.DataFiles, err = value.list()
+		case "filters":
+			cfg.Filters, err = value.list()
+		case "diffcheck.allow":
+			cfg.Allow, err = value.list()
+		case "diffcheck.deny":
+			cfg.Deny, err = value.list()
+		default:
+			err = errors.New("unknown setting")
+		}
+		if err != nil {
+			return &ParseError{Line: value.line, Field: key, Value: value.text, Err: err}
+		}
+	}
+	return nil
+}
+
+// applyEnv applies the settings in environment variables.
+func (cfg *Config) applyEnv(getenv func(string) string) {
+	if path := getenv("UCD_PATH"); path != "" {
+		cfg.UCDPath = path
+	}
+	if path := getenv("RUNESCAN_ALIASES"); path != "" {
+		cfg.AliasesPath = path
+	}
+	if version := getenv("RUNESCAN_UNICODE"); version != "" {
+		cfg.Unicode = version
+	}
+	if format := getenv("RUNESCAN_FORMAT"); format != "" {
+		cfg.Format = format
+	}
+	if locale := getenv("RUNESCAN_LOCALE"); locale != "" {
+		cfg.Locale = locale
+	}
+	if mirrors := getenv("RUNESCAN_MIRRORS"); mirrors != "" {
+		cfg.Mirrors = strings.Fields(mirrors)
+	}
+}
+
+// check reports invalid settings.
+func (cfg *Config) check() error {
+	if cfg.Format != formatText && cfg.Format != formatJSON {
+		return fmt.Errorf("unknown output format %q", cfg.Format)
+	}
+	if cfg.Unicode == "" || strings.ContainsAny(cfg.Unicode, `/\`) {
+		return fmt.Errorf("invalid Unicode version %q", cfg.Unicode)
+	}
+	if _, _, err := parseQuery(strings.Join(cfg.Filters, " ")); err != nil {
+		return fmt.Errorf("filters: %w", err)
+	}
+	return nil
+}
+
+// latestFile, in the cache directory, records the version of Unicode
+// that "latest" stood for when data files were first downloaded, so
+// that all of them come from that version until update moves on.
+const latestFile = "latest.txt"
+
+// versionPattern matches concrete Unicode versions, such as "15.1.0".
+var versionPattern = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// version returns the Unicode version data files are kept and
+// downloaded for: the configured one, with "latest" replaced by the
+// version recorded in latestFile, if any.
+func (cfg *Config) version() string {
+	if cfg.Unicode != "latest" {
+		return cfg.Unicode
+	}
+	data, err := os.ReadFile(filepath.Join(cfg.CacheDir, latestFile))
+	if version := strings.TrimSpace(string(data)); err == nil && versionPattern.MatchString(version) {
+		return version
+	}
+	return cfg.Unicode
+}
+
+// recordLatest saves version as the one "latest" stands for.
+func (cfg *Config) recordLatest(version string) error {
+	if err := os.MkdirAll(cfg.CacheDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cfg.CacheDir, latestFile), []byte(version+"\n"), 0o644)
+}
+
+// DataDir returns the directory holding the data files for the
+// configured Unicode version.
+func (cfg *Config) DataDir() string {
+	if cfg.UCDPath != "" {
+		return filepath.Dir(cfg.UCDPath)
+	}
+	return filepath.Join(cfg.CacheDir, cfg.version())
+}
+
+// DataPath returns the path of the UCD file.
+func (cfg *Config) DataPath() string {
+	if cfg.UCDPath != "" {
+		return cfg.UCDPath
+	}
+	return filepath.Join(cfg.DataDir(), UCDFile)
+}
+
+// MirrorURLs returns the base URLs to download data files from. In
+// configured mirrors, "{version}" stands for the Unicode version.
+// The UTS #39 security data directory is always tried last, since
+// UCD mirrors do not carry confusables.txt.
+func (cfg *Config) MirrorURLs() []string {
+	version := cfg.version()
+	mirrors := cfg.Mirrors
+	if len(mirrors) == 0 {
+		mirrors = ucdMirrors
+		if version != "latest" {
+			mirrors = []string{"https://www.unicode.org/Public/{version}/ucd/"}
+		}
+	}
+	urls := []string{}
+	for _, mirror := range append(mirrors[:len(mirrors):len(mirrors)], securityMirrors...) {
+		url := strings.Replace(mirror, "{version}", version, -1)
+		if !slices.Contains(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// tomlValue is a value in a config file: a string or a list of them.
+type tomlValue struct {
+	line   int
+	text   string
+	scalar *string
+	items  []string
+}
+
+func (v tomlValue) str() (string, error) {
+	if v.scalar == nil {
+		return "", errors.New("want a string")
+	}
+	return *v.scalar, nil
+}
+
+func (v tomlValue) list() ([]string, error) {
+	if v.scalar != nil {
+		return nil, errors.New("want a list of strings")
+	}
+	return v.items, nil
+}
+
+// parseTOML parses the subset of TOML runescan config files use:
+// comments, [table] headers, and keys set to strings, booleans,
+// numbers or single-line arrays of strings. Keys inside a table are
+// returned as "table.key".
+func parseTOML(r io.Reader) (map[string]tomlValue, error) {
+	values := map[string]tomlValue{}
+	scanner := bufio.NewScanner(r)
+	table, lineNum := "", 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			table = strings.TrimSpace(line[1:len(line)-1]) + "."
+			continue
+		}
+		key, text, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, &ParseError{Line: lineNum, Field: "line", Value: line,
+				Err: errors.New("want key = value")}
+		}
+		key, text = table+strings.TrimSpace(key), strings.TrimSpace(text)
+		value, err := parseTOMLValue(text)
+		if err != nil {
+			return nil, &ParseError{Line: lineNum, Field: key, Value: text, Err: err}
+		}
+		value.line, value.text = lineNum, text
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// tomlKeys returns the keys of values in the order of their lines,
+// so errors are reported for the first bad line.
+func tomlKeys(values map[string]tomlValue) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return values[keys[i]].line < values[keys[j]].line })
+	return keys
+}
+
+func parseTOMLValue(text string) (tomlValue, error) {
+	if strings.HasPrefix(text, "[") {
+		if !strings.HasSuffix(text, "]") {
+			return tomlValue{}, errors.New("unterminated array")
+		}
+		items := []string{}
+		rest := strings.TrimSpace(text[1 : len(text)-1])
+		for rest != "" {
+			item, tail, err := cutTOMLString(rest)
+			if err != nil {
+				return tomlValue{}, err
+			}
+			items = append(items, item)
+			rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tail), ","))
+		}
+		return tomlValue{items: items}, nil
+	}
+	if text == "true" || text == "false" {
+		return tomlValue{scalar: &text}, nil
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return tomlValue{scalar: &text}, nil
+	}
+	str, tail, err := cutTOMLString(text)
+	if err == nil && strings.TrimSpace(tail) != "" {
+		err = errors.New("unexpected text after string")
+	}
+	return tomlValue{scalar: &str}, err
+}
+
+// cutTOMLString parses the string at the start of text, returning it
+// and the text after it. Basic strings use Go escapes, which match
+// TOML's for all common cases; literal strings have no escapes.
+func cutTOMLString(text string) (string, string, error) {
+	if strings.HasPrefix(text, "'") {
+		end := strings.Index(text[1:], "'")
+		if end < 0 {
+			return "", "", errors.New("unterminated string")
+		}
+		return text[1 : end+1], text[end+2:], nil
+	}
+	if !strings.HasPrefix(text, `"`) {
+		return "", "", errors.New("want a quoted string")
+	}
+	prefix, err := strconv.QuotedPrefix(text)
+	if err != nil {
+		return "", "", errors.New("unterminated string")
+	}
+	str, err := strconv.Unquote(prefix)
+	return str, text[len(prefix):], err
+}
+
+// stripComment removes a # comment that is not inside a string.
+func stripComment(line string) string {
+	var quote byte
+	escaped := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && c == '\\':
+			escaped = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// envMap returns a getenv function reading from env.
+func envMap(env map[string]string) func(string) string {
+	return func(name string) string { return env[name] }
+}
+
+const configSample = `# runescan settings
+unicode = "15.1.0"
+format = 'json'   # for scripts
+mirrors = ["https://example.com/ucd/{version}/", "https://mirror.test/#ucd/"]
+data_files = [ "Blocks.txt", "Scripts.txt", ]
+filters = []
+`
+
+func TestParseTOML(t *testing.T) {
+	values, err := parseTOML(strings.NewReader(configSample + "\n[extra]\nanswer = 42\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := values["format"].str(); got != "json" {
+		t.Errorf("format: want %q; got %q", "json", got)
+	}
+	want := []string{"https://example.com/ucd/{version}/", "https://mirror.test/#ucd/"}
+	if got, _ := values["mirrors"].list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("mirrors: want %q; got %q", want, got)
+	}
+	if got, _ := values["filters"].list(); got == nil || len(got) != 0 {
+		t.Errorf("filters: want empty list; got %q", got)
+	}
+	if got, _ := values["extra.answer"].str(); got != "42" {
+		t.Errorf("extra.answer: want %q; got %q", "42", got)
+	}
+}
+
+func TestConfig_read_errors(t *testing.T) {
+	var testCases = []struct {
+		text string
+		want string
+	}{
+		{"unicode\n", `line 1: line "unicode": want key = value`},
+		{"\nformat = \"json\n", `line 2: format "\"json": unterminated string`},
+		{"format = [\"json\"]\n", `line 1: format "[\"json\"]": want a string`},
+		{"mirrors = \"x\"\n", `line 1: mirrors "\"x\"": want a list of strings`},
+		{"colour = \"red\"\n", `line 1: colour "\"red\"": unknown setting`},
+		{"zz = 1\nmirrors = \"x\"\nformat = [\"json\"]\ncolour = \"red\"\n", `line 1: zz "1": unknown setting`},
+		{"format = \"json\"\nmirrors = \"x\"\nfilters = \"y\"\naa = 1\n", `line 2: mirrors "\"x\"": want a list of strings`},
+	}
+	for _, tc := range testCases {
+		err := defaultConfig(envMap(nil)).read(strings.NewReader(tc.text))
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%q:\n\twant: %s\n\tgot:  %v", tc.text, tc.want, err)
+		}
+	}
+}
+
+func TestDefaultConfig_locale(t *testing.T) {
+	for lang, want := range map[string]string{
+		"tr_TR.UTF-8": "tr", "pt-BR": "pt", "de@euro": "de", "C": "", "C.UTF-8": "", "POSIX": "",
+		"_": "", ".": "", "@-": "",
+	} {
+		if got := defaultConfig(envMap(map[string]string{"LANG": lang})).Locale; got != want {
+			t.Errorf("LANG=%q: want locale %q; got %q", lang, want, got)
+		}
+	}
+}
+
+func TestLoadConfig_precedence(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "config", "runescan"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config", "runescan", "config.toml")
+	if err := os.WriteFile(path, []byte(configSample), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"HOME":            "/home/nobody",
+		"XDG_CONFIG_HOME": filepath.Join(dir, "config"),
+		"LANG":            "tr_TR.UTF-8",
+	}
+
+	cfg, err := loadConfig(envMap(map[string]string{"HOME": dir}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Format != formatText || cfg.Unicode != "latest" {
+		t.Errorf("defaults: got format %q, unicode %q", cfg.Format, cfg.Unicode)
+	}
+
+	cfg, err = loadConfig(envMap(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Format != formatJSON || cfg.Locale != "tr" {
+		t.Errorf("config file: got format %q, locale %q", cfg.Format, cfg.Locale)
+	}
+	wantDir := filepath.Join("/home/nobody", ".cache", "runescan", "15.1.0")
+	if got := cfg.DataPath(); got != filepath.Join(wantDir, UCDFile) {
+		t.Errorf("DataPath: want %q; got %q", filepath.Join(wantDir, UCDFile), got)
+	}
+	wantMirrors := []string{"https://example.com/ucd/15.1.0/", "https://mirror.test/#ucd/",
+		"https://www.unicode.org/Public/security/15.1.0/"}
+	if got := cfg.MirrorURLs(); !reflect.DeepEqual(got, wantMirrors) {
+		t.Errorf("MirrorURLs: want %q; got %q", wantMirrors, got)
+	}
+
+	env["RUNESCAN_FORMAT"] = "text"
+	env["UCD_PATH"] = "/data/UnicodeData.txt"
+	cfg, err = loadConfig(envMap(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Format != formatText || cfg.DataDir() != "/data" {
+		t.Errorf("environment: got format %q, data dir %q", cfg.Format, cfg.DataDir())
+	}
+
+	env["RUNESCAN_FORMAT"] = "yaml"
+	if _, err := loadConfig(envMap(env)); err == nil {
+		t.Error("want error for unknown format")
+	}
+}
+
+func TestConfig_defaultMirrors(t *testing.T) {
+	cfg := defaultConfig(envMap(nil))
+	want := append(append([]string{}, ucdMirrors...), "https://www.unicode.org/Public/security/latest/")
+	if got := cfg.MirrorURLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("latest: want %q; got %q", want, got)
+	}
+	cfg.Unicode = "14.0.0"
+	want = []string{"https://www.unicode.org/Public/14.0.0/ucd/",
+		"https://www.unicode.org/Public/security/14.0.0/"}
+	if got := cfg.MirrorURLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("14.0.0: want %q; got %q", want, got)
+	}
+	cfg.Mirrors = []string{"https://example.com/{version}/", "https://www.unicode.org/Public/security/{version}/"}
+	want = []string{"https://example.com/14.0.0/", "https://www.unicode.org/Public/security/14.0.0/"}
+	if got := cfg.MirrorURLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("configured: want %q; got %q", want, got)
+	}
+}
+
+func TestResolveLatest(t *testing.T) {
+	latestBefore := latestVersion
+	defer func() { latestVersion = latestBefore }()
+	latest := ""
+	latestVersion = func([]string) string { return latest }
+
+	cfg := defaultConfig(envMap(nil))
+	cfg.CacheDir = t.TempDir()
+	c := &cli{stderr: io.Discard, cfg: cfg}
+	c.resolveLatest(false)
+	if got, want := cfg.DataDir(), filepath.Join(cfg.CacheDir, "latest"); got != want {
+		t.Errorf("unknown latest version: want %q; got %q", want, got)
+	}
+
+	latest = "15.1.0"
+	c.resolveLatest(false)
+	latest = "16.0.0"
+	c.resolveLatest(false)
+	if got, want := cfg.DataDir(), filepath.Join(cfg.CacheDir, "15.1.0"); got != want {
+		t.Errorf("recorded: want %q; got %q", want, got)
+	}
+	want := []string{"https://www.unicode.org/Public/15.1.0/ucd/", "https://www.unicode.org/Public/security/15.1.0/"}
+	if got := cfg.MirrorURLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("recorded: want mirrors %q; got %q", want, got)
+	}
+	c.resolveLatest(true)
+	if got, want := cfg.DataDir(), filepath.Join(cfg.CacheDir, "16.0.0"); got != want {
+		t.Errorf("refreshed: want %q; got %q", want, got)
+	}
+}
+
+func TestReadmeLatest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ucd/ReadMe.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "# This directory contains the final data files\n"+
+			"# for the Unicode Character Database, for Version 15.1.0 of the Unicode Standard.\n")
+	}))
+	defer srv.Close()
+	if got := readmeLatest([]string{srv.URL + "/none/", srv.URL + "/ucd/"}); got != "15.1.0" {
+		t.Errorf("want 15.1.0; got %q", got)
+	}
+	if got := readmeLatest([]string{srv.URL + "/none/"}); got != "" {
+		t.Errorf("no ReadMe.txt: want no version; got %q", got)
+	}
+}
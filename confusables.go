@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfusablesFile is the UTS #39 data file mapping characters to
+// the prototypes they can be mistaken for.
+const ConfusablesFile = "confusables.txt"
+
+// securityMirrors are the base URLs of the UTS #39 data files, which
+// unicode.org publishes apart from the UCD.
+var securityMirrors = []string{"https://www.unicode.org/Public/security/{version}/"}
+
+// Confusables holds the mappings in confusables.txt.
+type Confusables struct {
+	prototypes map[rune]string   // character → prototype
+	sources    map[string][]rune // prototype → characters, in code point order
+}
+
+// LoadConfusables reads confusables.txt. Each line maps a source
+// character to a prototype of one or more characters:
+//
+//	0251 ;	0061 ;	MA	# ( ɑ → a ) LATIN SMALL LETTER ALPHA → LATIN SMALL LETTER A
+//
+// Errors are *ParseError values.
+func LoadConfusables(r io.Reader) (*Confusables, error) {
+	conf := &Confusables{prototypes: map[rune]string{}, sources: map[string][]rune{}}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		if len(fields) < 2 {
+			return nil, &ParseError{Line: lineNum, Field: "line", Value: line,
+				Err: errors.New("want source ; prototype")}
+		}
+		source, err := parseCodePoints(fields[0])
+		if err == nil && len(source) != 1 {
+			err = errors.New("want one code point")
+		}
+		if err != nil {
+			return nil, &ParseError{Line: lineNum, Field: "source", Value: fields[0], Err: err}
+		}
+		target, err := parseCodePoints(fields[1])
+		if err == nil && len(target) == 0 {
+			err = errors.New("empty prototype")
+		}
+		if err != nil {
+			return nil, &ParseError{Line: lineNum, Field: "prototype", Value: fields[1], Err: err}
+		}
+		prototype := string(target)
+		conf.prototypes[source[0]] = prototype
+		conf.sources[prototype] = append(conf.sources[prototype], source[0])
+	}
+	for _, chars := range conf.sources {
+		sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+	}
+	return conf, scanner.Err()
+}
+
+// parseCodePoints parses code points in hex separated by spaces.
+func parseCodePoints(field string) ([]rune, error) {
+	chars := []rune{}
+	for _, hex := range strings.Fields(field) {
+		code, err := strconv.ParseInt(hex, 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		chars = append(chars, rune(code))
+	}
+	return chars, nil
+}
+
+// Prototype returns what char maps to: its prototype, or the
+// character itself if it has none.
+func (c *Confusables) Prototype(char rune) string {
+	if prototype, ok := c.prototypes[char]; ok {
+		return prototype
+	}
+	return string(char)
+}
+
+// Of returns the characters and sequences that can be mistaken for
+// char: its prototype and every other character with the same one.
+func (c *Confusables) Of(char rune) []string {
+	prototype := c.Prototype(char)
+	result := []string{}
+	if prototype != string(char) {
+		result = append(result, prototype)
+	}
+	for _, other := range c.sources[prototype] {
+		if other != char {
+			result = append(result, string(other))
+		}
+	}
+	return result
+}
+
+// Skeleton returns the UTS #39 skeleton of s: its NFD form with each
+// character replaced by its prototype, converted to NFD again. Two
+// strings are confusable when their skeletons are equal.
+func Skeleton(u *UCD, conf *Confusables, s string) string {
+	var b strings.Builder
+	for _, char := range u.NFD(s) {
+		b.WriteString(conf.Prototype(char))
+	}
+	return u.NFD(b.String())
+}
+
+// Confusable reports whether a and b can be mistaken for each other.
+func Confusable(u *UCD, conf *Confusables, a, b string) bool {
+	return Skeleton(u, conf, a) == Skeleton(u, conf, b)
+}
+
+// sequenceFields returns the List fields for a character or a
+// sequence of them, whose code points and names are joined with
+// spaces and " + ".
+func sequenceFields(u *UCD, seq string) [3]string {
+	names := []string{}
+	for _, char := range seq {
+		names = append(names, u.Name(char))
+	}
+	return [3]string{codePoints(seq), seq, strings.Join(names, " + ")}
+}
+
+// loadConfusables reads and indexes the configured confusables.txt.
+func (c *cli) loadConfusables() (*Confusables, error) {
+	file, err := c.openFile(ConfusablesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadConfusables(file)
+}
+
+func runConfusables(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	chars, err := parseChars(flags.Args())
+	if err != nil {
+		return &UsageError{cmd.name, err.Error()}
+	}
+	if len(chars) == 0 {
+		return &UsageError{cmd.name, "missing characters"}
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	conf, err := c.loadConfusables()
+	if err != nil {
+		return err
+	}
+	results := [][3]string{}
+	seen := map[string]bool{}
+	for _, char := range chars {
+		seen[string(char)] = true
+	}
+	for _, char := range chars {
+		for _, seq := range conf.Of(char) {
+			if !seen[seq] {
+				seen[seq] = true
+				results = append(results, sequenceFields(ucd, seq))
+			}
+		}
+	}
+	if len(results) == 0 {
+		return errNoMatch
+	}
+	return c.output(results, ucd)
+}
+
+func runSkeleton(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 || flags.NArg() > 2 {
+		return &UsageError{cmd.name, "want one or two strings"}
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	conf, err := c.loadConfusables()
+	if err != nil {
+		return err
+	}
+	skeletons := []string{}
+	for _, s := range flags.Args() {
+		skeleton := Skeleton(ucd, conf, s)
+		skeletons = append(skeletons, skeleton)
+		fmt.Fprintf(c.stdout, "%+q\tskeleton %+q\n", s, skeleton)
+	}
+	if len(skeletons) == 1 {
+		return nil
+	}
+	if skeletons[0] != skeletons[1] {
+		fmt.Fprintln(c.stdout, "confusable: no")
+		return errNoMatch
+	}
+	fmt.Fprintln(c.stdout, "confusable: yes")
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// confusablesUCD holds the UnicodeData.txt lines the confusables
+// tests need.
+const confusablesUCD = `0031;DIGIT ONE;Nd;0;EN;;1;1;1;N;;;;;
+0061;LATIN SMALL LETTER A;Ll;0;L;;;;;N;;;0041;;0041
+0065;LATIN SMALL LETTER E;Ll;0;L;;;;;N;;;0045;;0045
+006C;LATIN SMALL LETTER L;Ll;0;L;;;;;N;;;004C;;004C
+006D;LATIN SMALL LETTER M;Ll;0;L;;;;;N;;;004D;;004D
+006E;LATIN SMALL LETTER N;Ll;0;L;;;;;N;;;004E;;004E
+0070;LATIN SMALL LETTER P;Ll;0;L;;;;;N;;;0050;;0050
+0072;LATIN SMALL LETTER R;Ll;0;L;;;;;N;;;0052;;0052
+0079;LATIN SMALL LETTER Y;Ll;0;L;;;;;N;;;0059;;0059
+00E9;LATIN SMALL LETTER E WITH ACUTE;Ll;0;L;0065 0301;;;;N;LATIN SMALL LETTER E ACUTE;;00C9;;00C9
+0251;LATIN SMALL LETTER ALPHA;Ll;0;L;;;;;N;LATIN SMALL LETTER SCRIPT A;;2C6D;;2C6D
+0301;COMBINING ACUTE ACCENT;Mn;230;NSM;;;;;N;NON-SPACING ACUTE;;;;
+0323;COMBINING DOT BELOW;Mn;220;NSM;;;;;N;NON-SPACING DOT BELOW;;;;
+0430;CYRILLIC SMALL LETTER A;Ll;0;L;;;;;N;;;0410;;0410
+0435;CYRILLIC SMALL LETTER IE;Ll;0;L;;;;;N;;;0415;;0415
+0440;CYRILLIC SMALL LETTER ER;Ll;0;L;;;;;N;;;0420;;0420
+`
+
+// confusablesSample holds lines of confusables.txt, which starts
+// with a byte order mark.
+const confusablesSample = "\ufeff# confusables.txt\n" + `
+0251 ;	0061 ;	MA	# ( ɑ → a ) LATIN SMALL LETTER ALPHA → LATIN SMALL LETTER A	# 
+0430 ;	0061 ;	MA	# ( а → a ) CYRILLIC SMALL LETTER A → LATIN SMALL LETTER A	# 
+0435 ;	0065 ;	MA	# ( е → e ) CYRILLIC SMALL LETTER IE → LATIN SMALL LETTER E	# 
+0440 ;	0070 ;	MA	# ( р → p ) CYRILLIC SMALL LETTER ER → LATIN SMALL LETTER P	# 
+006D ;	0072 006E ;	MA	# ( m → rn ) LATIN SMALL LETTER M → LATIN SMALL LETTER R, LATIN SMALL LETTER N	# 
+`
+
+func loadConfusablesSample(t *testing.T) (*UCD, *Confusables) {
+	t.Helper()
+	ucd, err := LoadUCD(strings.NewReader(confusablesUCD), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := LoadConfusables(strings.NewReader(confusablesSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ucd, conf
+}
+
+func TestConfusables_Of(t *testing.T) {
+	_, conf := loadConfusablesSample(t)
+	var testCases = []struct {
+		char rune
+		want []string
+	}{
+		{'a', []string{"ɑ", "а"}},
+		{'ɑ', []string{"a", "а"}},
+		{'m', []string{"rn"}},
+		{'z', []string{}},
+	}
+	for _, tc := range testCases {
+		if got := conf.Of(tc.char); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Of(%q): want %q; got %q", tc.char, tc.want, got)
+		}
+	}
+}
+
+func TestConfusable(t *testing.T) {
+	ucd, conf := loadConfusablesSample(t)
+	var testCases = []struct {
+		a, b string
+		want bool
+	}{
+		{"p\u0430yp\u0430l", "paypal", true},
+		{"modern", "rnodern", true},
+		{"\u00e9", "\u0435\u0301", true},
+		{"paypal", "paypa1", false},
+	}
+	for _, tc := range testCases {
+		if got := Confusable(ucd, conf, tc.a, tc.b); got != tc.want {
+			t.Errorf("Confusable(%+q, %+q): want %v; got %v (skeletons %+q, %+q)", tc.a, tc.b,
+				tc.want, got, Skeleton(ucd, conf, tc.a), Skeleton(ucd, conf, tc.b))
+		}
+	}
+}
+
+func TestLoadConfusables_errors(t *testing.T) {
+	_, err := LoadConfusables(strings.NewReader("# header\n0251 ;\tZZ ;\tMA\n"))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 2 || parseErr.Field != "prototype" {
+		t.Errorf("want prototype error on line 2; got %v", err)
+	}
+}
+
+func TestRun_confusables(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: confusablesUCD, ConfusablesFile: confusablesSample}
+	}
+	var testCases = []struct {
+		args   []string
+		status int
+		output string
+	}{
+		{[]string{"confusables", "a"}, exitMatch,
+			"U+0251\tɑ\tLATIN SMALL LETTER ALPHA (LATIN SMALL LETTER SCRIPT A)\n" +
+				"U+0430\tа\tCYRILLIC SMALL LETTER A\n"},
+		{[]string{"confusables", "U+006D"}, exitMatch,
+			"U+0072 U+006E\trn\tLATIN SMALL LETTER R + LATIN SMALL LETTER N\n"},
+		{[]string{"confusables", "y"}, exitNoMatch, ""},
+		{[]string{"skeleton", "p\u0430ypal", "paypal"}, exitMatch,
+			"\"p\\u0430ypal\"\tskeleton \"paypal\"\n\"paypal\"\tskeleton \"paypal\"\nconfusable: yes\n"},
+		{[]string{"skeleton", "paypal", "paypa1"}, exitNoMatch,
+			"\"paypal\"\tskeleton \"paypal\"\n\"paypa1\"\tskeleton \"paypa1\"\nconfusable: no\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			status, output, stderr := runArgs(tc.args...)
+			if status != tc.status || output != tc.output {
+				t.Errorf("\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q",
+					tc.status, tc.output, status, output, stderr)
+			}
+		})
+	}
+}
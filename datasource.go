@@ -0,0 +1,221 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// UCDFile is the name of the main Unicode Character Database file.
+const UCDFile = "UnicodeData.txt"
+
+// DataSource provides named UCD files, such as "UnicodeData.txt"
+// or "Blocks.txt", as readers. Open must return an error wrapping
+// fs.ErrNotExist when the source does not have the file, so a
+// LayeredSource can move on to the next source.
+type DataSource interface {
+	Open(name string) (io.ReadCloser, error)
+	String() string
+}
+
+// DirSource reads UCD files from a local directory.
+type DirSource string
+
+// Open opens the file name in the directory.
+func (d DirSource) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), name))
+}
+
+func (d DirSource) String() string { return "dir " + string(d) }
+
+// FileSource maps UCD file names to explicit paths, so a file may
+// live anywhere under any name, as UCD_PATH allows.
+type FileSource map[string]string
+
+// Open opens the path registered for name.
+func (f FileSource) Open(name string) (io.ReadCloser, error) {
+	path, ok := f[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return os.Open(path)
+}
+
+func (f FileSource) String() string {
+	paths := []string{}
+	for _, path := range f {
+		paths = append(paths, path)
+	}
+	return "files " + strings.Join(paths, ", ")
+}
+
+// ZipSource reads UCD files from a zip archive such as the UCD.zip
+// published by unicode.org. Files are matched by base name, so the
+// archive may keep them in a subdirectory.
+type ZipSource string
+
+// Open opens the archive and the member called name.
+func (z ZipSource) Open(name string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(string(z))
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range archive.File {
+		if path.Base(member.Name) != name {
+			continue
+		}
+		rc, err := member.Open()
+		if err != nil {
+			archive.Close()
+			return nil, err
+		}
+		return &zipFile{rc, archive}, nil
+	}
+	archive.Close()
+	return nil, &fs.PathError{Op: "open", Path: string(z) + ":" + name, Err: fs.ErrNotExist}
+}
+
+func (z ZipSource) String() string { return "zip " + string(z) }
+
+// zipFile closes the archive along with the member read from it.
+type zipFile struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+func (f *zipFile) Close() error {
+	err := f.ReadCloser.Close()
+	if err2 := f.archive.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// FSSource reads UCD files from an fs.FS, typically an embed.FS
+// holding data compiled into the binary.
+type FSSource struct {
+	FS   fs.FS
+	Name string
+}
+
+// Open opens name in the file system.
+func (s FSSource) Open(name string) (io.ReadCloser, error) {
+	return s.FS.Open(name)
+}
+
+func (s FSSource) String() string { return "fs " + s.Name }
+
+// MemSource holds UCD files in memory, keyed by name. It is meant
+// for tests and small fixtures.
+type MemSource map[string]string
+
+// Open returns a reader over the contents stored for name.
+func (m MemSource) Open(name string) (io.ReadCloser, error) {
+	text, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(strings.NewReader(text)), nil
+}
+
+func (m MemSource) String() string { return "memory" }
+
+// HTTPSource fetches UCD files from a list of mirrors, each a base
+// URL such as "https://www.unicode.org/Public/UNIDATA/". Mirrors
+// are tried in order. If Cache is set, each download is saved to
+// the path Cache returns and then read from disk. If Progress is set,
+// it reports on each download.
+type HTTPSource struct {
+	Mirrors  []string
+	Client   *http.Client
+	Cache    func(name string) string
+	Progress ProgressFunc
+}
+
+// Open fetches name from the first mirror that has it. If no mirror
+// has it, the error wraps ErrDownload when some mirror could not be
+// reached, and ErrNotFound otherwise.
+func (h *HTTPSource) Open(name string) (io.ReadCloser, error) {
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var failure error
+	for _, mirror := range h.Mirrors {
+		url := strings.TrimSuffix(mirror, "/") + "/" + name
+		response, err := client.Get(url)
+		if err != nil {
+			failure = err
+			continue
+		}
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			if response.StatusCode != http.StatusNotFound {
+				failure = fmt.Errorf("%s: %s", url, response.Status)
+			}
+			continue
+		}
+		var body io.Reader = response.Body
+		if h.Progress != nil {
+			body = h.Progress(body, response.ContentLength, url)
+		}
+		if h.Cache == nil {
+			return readCloser{body, response.Body}, nil
+		}
+		err = saveUCD(body, h.Cache(name))
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(h.Cache(name))
+	}
+	if failure != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrDownload, name, failure)
+	}
+	return nil, &fs.PathError{Op: "fetch", Path: name, Err: ErrNotFound}
+}
+
+// readCloser reads from a wrapper of the body it closes.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (h *HTTPSource) String() string {
+	return "mirrors " + strings.Join(h.Mirrors, ", ")
+}
+
+// LayeredSource tries each DataSource in order and opens the file
+// from the first one that has it.
+type LayeredSource []DataSource
+
+// Open opens name from the first layer that has it. Errors other
+// than a missing file stop the search; if no layer has the file,
+// the error wraps ErrNotFound.
+func (l LayeredSource) Open(name string) (io.ReadCloser, error) {
+	for _, src := range l {
+		rc, err := src.Open(name)
+		if err == nil {
+			return rc, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: ErrNotFound}
+}
+
+func (l LayeredSource) String() string {
+	names := []string{}
+	for _, src := range l {
+		names = append(names, src.String())
+	}
+	return strings.Join(names, "; ")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func readAll(t *testing.T, src DataSource, name string) string {
+	t.Helper()
+	rc, err := src.Open(name)
+	if err != nil {
+		t.Fatalf("%s: Open(%q): %v", src, name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDataSources(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, UCDFile), []byte(lines3Dto43), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(t.TempDir(), "UCD.zip")
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zipFile)
+	w, _ := zw.Create("ucd/" + UCDFile)
+	w.Write([]byte(lines3Dto43))
+	zw.Close()
+	zipFile.Close()
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	sources := []DataSource{
+		DirSource(dir),
+		FileSource{UCDFile: filepath.Join(dir, UCDFile)},
+		ZipSource(zipPath),
+		FSSource{fstest.MapFS{UCDFile: {Data: []byte(lines3Dto43)}}, "test"},
+		MemSource{UCDFile: lines3Dto43},
+		&HTTPSource{Mirrors: []string{srv.URL + "/missing/", srv.URL}},
+	}
+	for _, src := range sources {
+		t.Run(src.String(), func(t *testing.T) {
+			if got := readAll(t, src, UCDFile); got != lines3Dto43 {
+				t.Errorf("want: %q; got: %q", lines3Dto43, got)
+			}
+			_, err := src.Open("Missing.txt")
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Open(missing): want fs.ErrNotExist; got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLayeredSource(t *testing.T) {
+	src := LayeredSource{
+		MemSource{"Blocks.txt": "first"},
+		MemSource{"Blocks.txt": "second", UCDFile: lines3Dto43},
+	}
+	if got := readAll(t, src, "Blocks.txt"); got != "first" {
+		t.Errorf("Blocks.txt: want first layer; got: %q", got)
+	}
+	if got := readAll(t, src, UCDFile); got != lines3Dto43 {
+		t.Errorf("%s: want second layer; got: %q", UCDFile, got)
+	}
+	if _, err := src.Open("Scripts.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open(missing): want fs.ErrNotExist; got: %v", err)
+	}
+}
+
+func TestHTTPSource_cache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(lines3Dto43))
+		}))
+	defer srv.Close()
+	dir := t.TempDir()
+	src := &HTTPSource{
+		Mirrors: []string{srv.URL},
+		Cache:   func(name string) string { return filepath.Join(dir, name) },
+	}
+	readAll(t, src, UCDFile)
+	if got := readAll(t, DirSource(dir), UCDFile); got != lines3Dto43 {
+		t.Errorf("cached copy: want: %q; got: %q", lines3Dto43, got)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// AddedLine is a line a diff adds, numbered as in the new file.
+type AddedLine struct {
+	File string
+	Line int
+	Text string
+}
+
+// ParseDiff reads a unified diff, such as git diff writes, and
+// returns the lines it adds. File names lose the "b/" prefix git
+// gives them. Errors are *ParseError values.
+func ParseDiff(r io.Reader) ([]AddedLine, error) {
+	added := []AddedLine{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20) // minified files have long lines
+	file, lineNum := "", 0
+	newLine, oldLeft, newLeft := 0, 0, 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if oldLeft > 0 || newLeft > 0 { // inside a hunk
+			switch {
+			case strings.HasPrefix(line, "+"):
+				added = append(added, AddedLine{file, newLine, line[1:]})
+				newLine++
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+			case strings.HasPrefix(line, `\`): // "\ No newline at end of file"
+			default: // context, which editors may strip to ""
+				newLine++
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = diffFileName(line[4:])
+		case strings.HasPrefix(line, "@@ "):
+			var err error
+			if oldLeft, newLine, newLeft, err = parseHunk(line); err != nil {
+				return added, &ParseError{Line: lineNum, Field: "hunk header", Value: line, Err: err}
+			}
+		}
+	}
+	return added, scanner.Err()
+}
+
+// diffFileName returns the file name in a "+++" line without the
+// quotes, "b/" prefix and timestamp it may have, or "" for /dev/null.
+func diffFileName(field string) string {
+	if unquoted, err := strconv.Unquote(field); err == nil {
+		field = unquoted
+	} else if name, _, ok := strings.Cut(field, "\t"); ok {
+		field = name
+	}
+	if field == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(field, "b/")
+}
+
+// parseHunk parses a hunk header such as "@@ -1,5 +1,6 @@ func f()",
+// returning the number of old lines, the first new line and the
+// number of new lines.
+func parseHunk(line string) (oldCount, newStart, newCount int, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[3] != "@@" ||
+		!strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, errors.New("want @@ -OLD +NEW @@")
+	}
+	if _, oldCount, err = hunkRange(fields[1][1:]); err != nil {
+		return 0, 0, 0, err
+	}
+	newStart, newCount, err = hunkRange(fields[2][1:])
+	return oldCount, newStart, newCount, err
+}
+
+// hunkRange parses "START,COUNT" or "START", where COUNT is 1.
+func hunkRange(field string) (start, count int, err error) {
+	startText, countText, ok := strings.Cut(field, ",")
+	if start, err = strconv.Atoi(startText); err != nil {
+		return 0, 0, err
+	}
+	if !ok {
+		return start, 1, nil
+	}
+	count, err = strconv.Atoi(countText)
+	return start, count, err
+}
+
+// Kinds of findings diffcheck adds to those of Analyze.
+const (
+	findConfusable = "confusable"
+	findScript     = "unusual-script"
+	findDenied     = "denied"
+)
+
+// usualScripts are the scripts whose characters diffcheck accepts
+// unless a rule denies them.
+var usualScripts = map[string]bool{"Latin": true, "Common": true, "Inherited": true}
+
+// charRule selects characters by code point range or script,
+// optionally only in the files matching a glob.
+type charRule struct {
+	glob        string
+	first, last rune
+	script      string
+}
+
+// parseCharRule parses a rule such as "U+00B5", "U+0370..U+03FF",
+// "Greek" or "math/*:Greek".
+func parseCharRule(text string) (charRule, error) {
+	rule := charRule{}
+	if glob, item, ok := strings.Cut(text, ":"); ok {
+		if _, err := path.Match(glob, ""); err != nil {
+			return rule, err
+		}
+		rule.glob, text = glob, item
+	}
+	if !strings.HasPrefix(text, "U+") {
+		if text == "" {
+			return rule, errors.New("empty rule")
+		}
+		rule.script = text
+		return rule, nil
+	}
+	var err error
+	rule.first, rule.last, err = parseRange(strings.Replace(text[2:], "..U+", "..", 1))
+	return rule, err
+}
+
+// matches reports whether the rule selects char in file. A glob
+// matches the file or any directory containing it.
+func (r charRule) matches(u *UCD, file string, char rune) bool {
+	if r.script != "" {
+		if u.Script(char) != r.script {
+			return false
+		}
+	} else if char < r.first || r.last < char {
+		return false
+	}
+	if r.glob == "" {
+		return true
+	}
+	for name := file; name != "." && name != "/" && name != ""; name = path.Dir(name) {
+		if ok, _ := path.Match(r.glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffPolicy holds the rules for the characters diffcheck accepts
+// although they are unusual, and those it reports although they are
+// not. Deny rules win over allow rules.
+type DiffPolicy struct {
+	allow, deny []charRule
+}
+
+// NewDiffPolicy parses allow and deny rules, as in "U+00B5",
+// "U+0370..U+03FF", "Greek", or "math/*:Greek" to limit a rule to
+// the files matching a glob.
+func NewDiffPolicy(allow, deny []string) (*DiffPolicy, error) {
+	policy := &DiffPolicy{}
+	for _, list := range []struct {
+		texts []string
+		rules *[]charRule
+	}{
+		{allow, &policy.allow}, {deny, &policy.deny},
+	} {
+		for _, text := range list.texts {
+			rule, err := parseCharRule(strings.TrimSpace(text))
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %v", text, err)
+			}
+			*list.rules = append(*list.rules, rule)
+		}
+	}
+	return policy, nil
+}
+
+func anyMatches(rules []charRule, u *UCD, file string, char rune) bool {
+	for _, rule := range rules {
+		if rule.matches(u, file, char) {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffFinding is a Finding in an added line. Line is the line number
+// in the new file; Byte and Rune are offsets in the line.
+type DiffFinding struct {
+	File string `json:"file"`
+	Finding
+}
+
+// CheckDiff finds the suspicious characters in lines: those Analyze
+// finds, those confusable with ASCII, those of scripts other than
+// Latin, Common and Inherited, and those policy denies. Characters
+// policy allows are accepted, except for invalid UTF-8.
+func (u *UCD) CheckDiff(conf *Confusables, policy *DiffPolicy, lines []AddedLine) []DiffFinding {
+	findings := []DiffFinding{}
+	for _, line := range lines {
+		found := map[int]Finding{}
+		for _, f := range u.Analyze([]byte(line.Text)).Findings {
+			found[f.Rune] = f
+		}
+		for offset, index := 0, 0; offset < len(line.Text); index++ {
+			char, size := utf8.DecodeRuneInString(line.Text[offset:])
+			f, ok := found[index]
+			if !ok {
+				f = Finding{Byte: offset, Rune: index, Column: index + 1}
+			}
+			switch {
+			case f.Kind == findInvalid:
+			case anyMatches(policy.deny, u, line.File, char):
+				if f.Kind == "" {
+					f.Kind = findDenied
+				}
+			case anyMatches(policy.allow, u, line.File, char):
+				f.Kind = ""
+			case f.Kind != "" || char < utf8.RuneSelf:
+			case isASCII(conf.Prototype(char)):
+				f.Kind = findConfusable
+			case !usualScripts[u.Script(char)]:
+				f.Kind = findScript
+			}
+			if f.Kind != "" {
+				if !ok {
+					f.Code, f.Name = fmt.Sprintf("U+%04X", char), u.Name(char)
+				}
+				f.Line = line.Line
+				findings = append(findings, DiffFinding{line.File, f})
+			}
+			offset += size
+		}
+	}
+	return findings
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func diffcheckFlags(c *cli, flags *flag.FlagSet) {
+	formatFlags(c, flags)
+	flags.StringVar(&c.opts.allow, "allow", "", "accept characters matching `RULES`, separated by commas")
+	flags.StringVar(&c.opts.deny, "deny", "", "report characters matching `RULES`, separated by commas")
+}
+
+func runDiffcheck(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return &UsageError{cmd.name, "the diff is read from standard input"}
+	}
+	for _, list := range []struct {
+		text  string
+		rules *[]string
+	}{
+		{c.opts.allow, &c.cfg.Allow}, {c.opts.deny, &c.cfg.Deny},
+	} {
+		if list.text != "" {
+			*list.rules = append(*list.rules, strings.Split(list.text, ",")...)
+		}
+	}
+	policy, err := NewDiffPolicy(c.cfg.Allow, c.cfg.Deny)
+	if err != nil {
+		return &UsageError{cmd.name, err.Error()}
+	}
+	lines, err := ParseDiff(c.stdin)
+	if err != nil {
+		return &UsageError{cmd.name, "diff: " + err.Error()}
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	if err := c.loadProperties(ucd); err != nil {
+		return err
+	}
+	conf, err := c.loadConfusables()
+	if err != nil {
+		return err
+	}
+	findings := ucd.CheckDiff(conf, policy, lines)
+	if c.cfg.Format == formatJSON {
+		if err := encodeJSON(c.stdout, findings); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range findings {
+			fmt.Fprintf(c.stdout, "%s:%d:%d: %s %s %s\n", f.File, f.Line, f.Column, f.Kind, f.Code, f.Name)
+		}
+	}
+	if len(findings) > 0 {
+		return errFindings
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// diffUCD holds UnicodeData.txt lines for the diffcheck tests.
+const diffUCD = analyzeUCD + `0251;LATIN SMALL LETTER ALPHA;Ll;0;L;;;;;N;LATIN SMALL LETTER SCRIPT A;;2C6D;;2C6D
+03C0;GREEK SMALL LETTER PI;Ll;0;L;;;;;N;;;03A0;;03A0
+0430;CYRILLIC SMALL LETTER A;Ll;0;L;;;;;N;;;0410;;0410
+`
+
+const diffScripts = scriptsSample + `0251          ; Latin
+03C0          ; Greek
+0430          ; Cyrillic
+05D0          ; Hebrew
+`
+
+// diffSample changes three files: it adds suspicious characters to
+// main.go, creates math/geom/vec.go and deletes old.go.
+const diffSample = "diff --git a/main.go b/main.go\n" +
+	"index 3b18e51..a8c7f0d 100644\n" +
+	"--- a/main.go\n" +
+	"+++ b/main.go\n" +
+	"@@ -1,3 +1,4 @@\n" +
+	" A\n" +
+	"-b\n" +
+	"+a\u202eb\n" +
+	"+\u0430b\n" +
+	" A\n" +
+	"\\ No newline at end of file\n" +
+	"@@ -10 +11,2 @@ func main() {\n" +
+	"-a\n" +
+	"+\u03c0\n" +
+	"+\u0251\n" +
+	"diff --git a/math/geom/vec.go b/math/geom/vec.go\n" +
+	"new file mode 100644\n" +
+	"--- /dev/null\n" +
+	"+++ b/math/geom/vec.go\n" +
+	"@@ -0,0 +1,2 @@\n" +
+	"+\u03c0\n" +
+	"+\u05d0\xffb\n" +
+	"diff --git a/old.go b/old.go\n" +
+	"deleted file mode 100644\n" +
+	"--- a/old.go\n" +
+	"+++ /dev/null\n" +
+	"@@ -1 +0,0 @@\n" +
+	"-\u202e\n"
+
+func TestParseDiff(t *testing.T) {
+	lines, err := ParseDiff(strings.NewReader(diffSample))
+	want := []AddedLine{
+		{"main.go", 2, "a\u202eb"},
+		{"main.go", 3, "\u0430b"},
+		{"main.go", 11, "\u03c0"},
+		{"main.go", 12, "\u0251"},
+		{"math/geom/vec.go", 1, "\u03c0"},
+		{"math/geom/vec.go", 2, "\u05d0\xffb"},
+	}
+	if err != nil || !reflect.DeepEqual(lines, want) {
+		t.Errorf("want %q; got %q (error %v)", want, lines, err)
+	}
+
+	lines, err = ParseDiff(strings.NewReader("--- \"a/caf\\303\\251.go\"\n" +
+		"+++ \"b/caf\\303\\251.go\"\n@@ -1 +1 @@\n-a\n++++\n"))
+	want = []AddedLine{{"caf\u00e9.go", 1, "+++"}}
+	if err != nil || !reflect.DeepEqual(lines, want) {
+		t.Errorf("want %q; got %q (error %v)", want, lines, err)
+	}
+
+	_, err = ParseDiff(strings.NewReader("+++ b/x.go\n@@ -1 +a @@\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2: hunk header") {
+		t.Errorf("want error on line 2; got %v", err)
+	}
+}
+
+func TestNewDiffPolicy(t *testing.T) {
+	testCases := []struct {
+		rule string
+		want charRule
+	}{
+		{"Greek", charRule{script: "Greek"}},
+		{"U+00B5", charRule{first: 0xB5, last: 0xB5}},
+		{"U+0370..U+03FF", charRule{first: 0x370, last: 0x3FF}},
+		{"math/*:Greek", charRule{glob: "math/*", script: "Greek"}},
+	}
+	for _, tc := range testCases {
+		policy, err := NewDiffPolicy([]string{tc.rule}, nil)
+		if err != nil || !reflect.DeepEqual(policy.allow, []charRule{tc.want}) {
+			t.Errorf("%q: want %+v; got %+v (error %v)", tc.rule, tc.want, policy, err)
+		}
+	}
+	for _, rule := range []string{"", "U+03FF..U+0370", "U+XYZ", "[:Greek"} {
+		if _, err := NewDiffPolicy(nil, []string{rule}); err == nil {
+			t.Errorf("%q: want error", rule)
+		}
+	}
+}
+
+func TestRun_diffcheck(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: diffUCD, BlocksFile: blocksSample, ScriptsFile: diffScripts,
+			ConfusablesFile: confusablesSample}
+	}
+	config := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(config, []byte("[diffcheck]\nallow = [\"math/*:Greek\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RUNESCAN_CONFIG", config)
+
+	var stdout, stderr strings.Builder
+	status := run([]string{"diffcheck", "-deny", "math/*:U+0062"}, strings.NewReader(diffSample), &stdout, &stderr)
+	want := "main.go:2:2: bidi-control U+202E RIGHT-TO-LEFT OVERRIDE\n" +
+		"main.go:3:1: confusable U+0430 CYRILLIC SMALL LETTER A\n" +
+		"main.go:11:1: unusual-script U+03C0 GREEK SMALL LETTER PI\n" +
+		"main.go:12:1: confusable U+0251 LATIN SMALL LETTER ALPHA (LATIN SMALL LETTER SCRIPT A)\n" +
+		"math/geom/vec.go:2:1: unusual-script U+05D0 HEBREW LETTER ALEF\n" +
+		"math/geom/vec.go:2:2: invalid-utf8 0xFF <invalid UTF-8>\n" +
+		"math/geom/vec.go:2:3: denied U+0062 LATIN SMALL LETTER B\n"
+	if stdout.String() != want || status != exitFindings {
+		t.Errorf("want: %d %q\ngot:  %d %q\nstderr: %q", exitFindings, want, status, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	status = run([]string{"diffcheck", "-format", "json", "-allow", "U+202E,Cyrillic,Greek,U+0251"},
+		strings.NewReader(diffSample), &stdout, &stderr)
+	var findings []DiffFinding
+	if err := json.Unmarshal([]byte(stdout.String()), &findings); err != nil {
+		t.Fatalf("%v:\n%s", err, stdout.String())
+	}
+	wantFinding := DiffFinding{"math/geom/vec.go", Finding{Kind: findScript, Code: "U+05D0",
+		Name: "HEBREW LETTER ALEF", Line: 2, Column: 1}}
+	if status != exitFindings || len(findings) != 2 || findings[0] != wantFinding {
+		t.Errorf("want %+v and invalid UTF-8; got %d %+v", wantFinding, status, findings)
+	}
+
+	testCases := []struct {
+		args  []string
+		stdin string
+		want  int
+	}{
+		{[]string{"diffcheck"}, "--- a/x\n+++ b/x\n@@ -1 +1 @@\n-\u0430\n+a\n", exitMatch},
+		{[]string{"diffcheck", "-deny", "U+0061"}, "+++ b/x\n@@ -0,0 +1 @@\n+a\n", exitFindings},
+		{[]string{"diffcheck", "-allow", "U+2..U+1"}, "", exitUsage},
+		{[]string{"diffcheck", "x.diff"}, "", exitUsage},
+		{[]string{"diffcheck"}, "+++ b/x\n@@ -1 @@\n", exitUsage},
+	}
+	for _, tc := range testCases {
+		stdout.Reset()
+		stderr.Reset()
+		if status := run(tc.args, strings.NewReader(tc.stdin), &stdout, &stderr); status != tc.want {
+			t.Errorf("%q: want %d; got %d (stdout %q, stderr %q)", tc.args, tc.want, status, stdout.String(), stderr.String())
+		}
+	}
+}
+
+func TestRun_diffcheck_xml(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDXMLFile: xmlSample, BlocksFile: blocksSample,
+			ScriptsFile: scriptsSample + "00C9          ; Latin\n", ConfusablesFile: confusablesSample}
+	}
+	t.Setenv("UCD_PATH", filepath.Join(t.TempDir(), UCDXMLFile))
+	const diff = "+++ b/x\n@@ -0,0 +1 @@\n+A\u00c9\n"
+
+	var stdout, stderr strings.Builder
+	if status := run([]string{"diffcheck"}, strings.NewReader(diff), &stdout, &stderr); status != exitMatch {
+		t.Errorf("Latin letters: want %d; got %d (stdout %q, stderr %q)", exitMatch, status, stdout.String(), stderr.String())
+	}
+	stdout.Reset()
+	status := run([]string{"diffcheck", "-deny", "Latin"}, strings.NewReader(diff), &stdout, &stderr)
+	want := "x:1:1: denied U+0041 LATIN CAPITAL LETTER A\n" +
+		"x:1:2: denied U+00C9 LATIN CAPITAL LETTER E WITH ACUTE (LATIN CAPITAL LETTER E ACUTE)\n"
+	if stdout.String() != want || status != exitFindings {
+		t.Errorf("-deny Latin: want %d %q; got %d %q (stderr %q)", exitFindings, want, status, stdout.String(), stderr.String())
+	}
+}
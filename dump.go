@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Encodings dump decodes.
+const (
+	encUTF8    = "UTF-8"
+	encUTF16LE = "UTF-16LE"
+	encUTF16BE = "UTF-16BE"
+	encUTF32LE = "UTF-32LE"
+	encUTF32BE = "UTF-32BE"
+)
+
+var encodings = []string{encUTF8, encUTF16LE, encUTF16BE, encUTF32LE, encUTF32BE}
+
+// byteOrderMarks are the encodings of U+FEFF that identify an
+// encoding at the start of a file. UTF-32LE comes before UTF-16LE,
+// whose mark it starts with.
+var byteOrderMarks = []struct {
+	encoding string
+	bom      string
+}{
+	{encUTF8, "\xEF\xBB\xBF"},
+	{encUTF32LE, "\xFF\xFE\x00\x00"},
+	{encUTF32BE, "\x00\x00\xFE\xFF"},
+	{encUTF16LE, "\xFF\xFE"},
+	{encUTF16BE, "\xFE\xFF"},
+}
+
+// DetectEncoding returns the encoding of data and how it was found:
+// from its byte order mark, or guessed from the zero bytes that
+// UTF-16 and UTF-32 have in the high bytes of most code units. Data
+// without a mark or zero bytes is taken as UTF-8.
+func DetectEncoding(data []byte) (encoding, reason string) {
+	for _, mark := range byteOrderMarks {
+		if strings.HasPrefix(string(data), mark.bom) {
+			return mark.encoding, "byte order mark"
+		}
+	}
+	sample := data
+	if len(sample) > 4096 {
+		sample = sample[:4096]
+	}
+	zeros := [4]int{}
+	for i, b := range sample {
+		if b == 0 {
+			zeros[i%4]++
+		}
+	}
+	units := len(sample) / 4
+	switch {
+	case units == 0 || zeros == [4]int{}:
+		return encUTF8, "default"
+	case len(data)%4 == 0 && zeros[3] == units && zeros[2]*2 >= units:
+		return encUTF32LE, "guessed from zero bytes"
+	case len(data)%4 == 0 && zeros[0] == units && zeros[1]*2 >= units:
+		return encUTF32BE, "guessed from zero bytes"
+	}
+	even, odd := zeros[0]+zeros[2], zeros[1]+zeros[3]
+	switch {
+	case len(data)%2 == 0 && odd >= units && even*4 < odd:
+		return encUTF16LE, "guessed from zero bytes"
+	case len(data)%2 == 0 && even >= units && odd*4 < even:
+		return encUTF16BE, "guessed from zero bytes"
+	}
+	return encUTF8, "default"
+}
+
+// DumpEntry is a code point of a file, or a sequence of bytes that
+// does not decode to one, in which case Char is -1.
+type DumpEntry struct {
+	Offset int
+	Bytes  []byte
+	Char   rune
+	Note   string // what is wrong or special about the bytes
+}
+
+// Dump decodes data in encoding, keeping every byte: sequences that
+// cannot be decoded become entries with a note saying why, and so do
+// UTF-8 overlong encodings and surrogates, which are decoded. Byte
+// order marks are noted too.
+func Dump(data []byte, encoding string) []DumpEntry {
+	decode := decodeUTF8
+	switch encoding {
+	case encUTF16LE, encUTF16BE:
+		decode = utf16Decoder(encoding == encUTF16BE)
+	case encUTF32LE, encUTF32BE:
+		decode = utf32Decoder(encoding == encUTF32BE)
+	}
+	entries := []DumpEntry{}
+	for offset := 0; offset < len(data); {
+		char, size, note := decode(data[offset:])
+		if char == 0xFEFF {
+			note = "byte order mark"
+			if offset > 0 {
+				note += " not at start of file"
+			}
+		}
+		entries = append(entries, DumpEntry{offset, data[offset : offset+size], char, note})
+		offset += size
+	}
+	return entries
+}
+
+// decodeUTF8 decodes the first code point of b. Unlike the decoder
+// in unicode/utf8, it decodes overlong forms and surrogates, noting
+// that they are invalid, so a dump can show what they meant.
+func decodeUTF8(b []byte) (rune, int, string) {
+	lead := b[0]
+	var size int
+	var char rune
+	switch {
+	case lead < 0x80:
+		return rune(lead), 1, ""
+	case lead < 0xC0:
+		return -1, 1, "unexpected continuation byte"
+	case lead < 0xE0:
+		size, char = 2, rune(lead&0x1F)
+	case lead < 0xF0:
+		size, char = 3, rune(lead&0x0F)
+	case lead < 0xF8:
+		size, char = 4, rune(lead&0x07)
+	default:
+		return -1, 1, "invalid byte"
+	}
+	for i := 1; i < size; i++ {
+		if i == len(b) || b[i]&0xC0 != 0x80 {
+			return -1, i, "truncated sequence"
+		}
+		char = char<<6 | rune(b[i]&0x3F)
+	}
+	minimum := [...]rune{2: 0x80, 3: 0x800, 4: 0x10000}[size]
+	switch {
+	case char < minimum:
+		return -1, size, fmt.Sprintf("overlong encoding of U+%04X", char)
+	case 0xD800 <= char && char <= 0xDFFF:
+		return char, size, "surrogate encoded in UTF-8"
+	case char > 0x10FFFF:
+		return -1, size, "beyond U+10FFFF"
+	}
+	return char, size, ""
+}
+
+// utf16Decoder returns a decoder for UTF-16 in either byte order,
+// which notes unpaired surrogates.
+func utf16Decoder(bigEndian bool) func([]byte) (rune, int, string) {
+	unit := func(b []byte) rune {
+		if bigEndian {
+			return rune(b[0])<<8 | rune(b[1])
+		}
+		return rune(b[1])<<8 | rune(b[0])
+	}
+	return func(b []byte) (rune, int, string) {
+		if len(b) < 2 {
+			return -1, len(b), "truncated code unit"
+		}
+		char := unit(b)
+		switch {
+		case char < 0xD800 || char > 0xDFFF:
+			return char, 2, ""
+		case char < 0xDC00 && len(b) >= 4:
+			if low := unit(b[2:]); 0xDC00 <= low && low <= 0xDFFF {
+				return 0x10000 + (char-0xD800)<<10 + (low - 0xDC00), 4, ""
+			}
+		}
+		return char, 2, "unpaired surrogate"
+	}
+}
+
+// utf32Decoder returns a decoder for UTF-32 in either byte order.
+func utf32Decoder(bigEndian bool) func([]byte) (rune, int, string) {
+	return func(b []byte) (rune, int, string) {
+		if len(b) < 4 {
+			return -1, len(b), "truncated code unit"
+		}
+		var value uint32
+		for i := 0; i < 4; i++ {
+			shift := 8 * i
+			if bigEndian {
+				shift = 8 * (3 - i)
+			}
+			value |= uint32(b[i]) << shift
+		}
+		switch {
+		case value > 0x10FFFF:
+			return -1, 4, "beyond U+10FFFF"
+		case 0xD800 <= value && value <= 0xDFFF:
+			return rune(value), 4, "surrogate in UTF-32"
+		}
+		return rune(value), 4, ""
+	}
+}
+
+// WriteDump writes entries one per line: the offset and bytes in
+// hex, the code point, the character rendered by u.Glyph, its name
+// and the note, if any, in brackets.
+func (u *UCD) WriteDump(w io.Writer, entries []DumpEntry) error {
+	for _, e := range entries {
+		code, glyph, width, name := "-", string(replacement), 1, "<invalid>"
+		if e.Char >= 0 {
+			code, name = fmt.Sprintf("U+%04X", e.Char), u.Name(e.Char)
+			glyph, width = u.Glyph(e.Char)
+		}
+		if e.Note != "" {
+			name += " [" + e.Note + "]"
+		}
+		_, err := fmt.Fprintf(w, "%08x  %-11s  %-8s %s%*s  %s\n", e.Offset,
+			fmt.Sprintf("% x", e.Bytes), code, glyph, 2-width, "", name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonDump is a dump as JSON output shows it.
+type jsonDump struct {
+	Encoding string          `json:"encoding"`
+	Reason   string          `json:"reason"`
+	Entries  []jsonDumpEntry `json:"entries"`
+}
+
+// jsonDumpEntry is a DumpEntry as JSON output shows it.
+type jsonDumpEntry struct {
+	Offset int    `json:"offset"`
+	Bytes  string `json:"bytes"`
+	Code   string `json:"code,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+func dumpFlags(c *cli, flags *flag.FlagSet) {
+	formatFlags(c, flags)
+	flags.StringVar(&c.opts.encoding, "encoding", "", "decode as `ENCODING`: "+strings.Join(encodings, ", ")+
+		" (default: detected)")
+}
+
+func runDump(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	var data []byte
+	var err error
+	switch flags.NArg() {
+	case 0:
+		data, err = io.ReadAll(c.stdin)
+	case 1:
+		data, err = os.ReadFile(flags.Arg(0))
+		if errors.Is(err, fs.ErrNotExist) {
+			return &UsageError{cmd.name, err.Error()}
+		}
+	default:
+		return &UsageError{cmd.name, "dump one file at a time"}
+	}
+	if err != nil {
+		return err
+	}
+	reason := "-encoding"
+	if c.opts.encoding == "" {
+		c.opts.encoding, reason = DetectEncoding(data)
+	}
+	known := false
+	for _, name := range encodings {
+		if strings.EqualFold(c.opts.encoding, name) {
+			c.opts.encoding, known = name, true
+		}
+	}
+	if !known {
+		return &UsageError{cmd.name, fmt.Sprintf("unknown encoding %q", c.opts.encoding)}
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	entries := Dump(data, c.opts.encoding)
+	if c.cfg.Format == formatJSON {
+		dump := jsonDump{c.opts.encoding, reason, make([]jsonDumpEntry, len(entries))}
+		for i, e := range entries {
+			dump.Entries[i] = jsonDumpEntry{Offset: e.Offset, Bytes: fmt.Sprintf("% x", e.Bytes), Note: e.Note}
+			if e.Char >= 0 {
+				dump.Entries[i].Code, dump.Entries[i].Name = fmt.Sprintf("U+%04X", e.Char), ucd.Name(e.Char)
+			}
+		}
+		return encodeJSON(c.stdout, dump)
+	}
+	if err := c.loadDisplayData(ucd); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "# %s (%s)\n", c.opts.encoding, reason)
+	return ucd.WriteDump(c.stdout, entries)
+}
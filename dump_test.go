@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dumpUCD holds UnicodeData.txt lines for the dump tests.
+const dumpUCD = analyzeUCD + `D800;<Non Private Use High Surrogate, First>;Cs;0;L;;;;;N;;;;;
+DB7F;<Non Private Use High Surrogate, Last>;Cs;0;L;;;;;N;;;;;
+FEFF;ZERO WIDTH NO-BREAK SPACE;Cf;0;BN;;;;;N;BYTE ORDER MARK;;;;
+`
+
+func TestDetectEncoding(t *testing.T) {
+	testCases := []struct {
+		data     string
+		encoding string
+		reason   string
+	}{
+		{"", encUTF8, "default"},
+		{"abc\u00e9", encUTF8, "default"},
+		{"\xef\xbb\xbfab", encUTF8, "byte order mark"},
+		{"\xff\xfea\x00", encUTF16LE, "byte order mark"},
+		{"\xfe\xff\x00a", encUTF16BE, "byte order mark"},
+		{"\xff\xfe\x00\x00a\x00\x00\x00", encUTF32LE, "byte order mark"},
+		{"\x00\x00\xfe\xff\x00\x00\x00a", encUTF32BE, "byte order mark"},
+		{"a\x00b\x00c\x00d\x00", encUTF16LE, "guessed from zero bytes"},
+		{"\x00a\x00b\x00c\x00\xe9", encUTF16BE, "guessed from zero bytes"},
+		{"a\x00\x00\x00\x00\xf6\x01\x00", encUTF32LE, "guessed from zero bytes"},
+		{"\x00\x00\x00a\x00\x00\x00b", encUTF32BE, "guessed from zero bytes"},
+		{"ab\x00cdefgh", encUTF8, "default"},
+	}
+	for _, tc := range testCases {
+		encoding, reason := DetectEncoding([]byte(tc.data))
+		if encoding != tc.encoding || reason != tc.reason {
+			t.Errorf("%q: want %s (%s); got %s (%s)", tc.data, tc.encoding, tc.reason, encoding, reason)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	testCases := []struct {
+		data     string
+		encoding string
+		want     []DumpEntry
+	}{
+		{"A\xc3\xa9", encUTF8, []DumpEntry{
+			{0, []byte("A"), 'A', ""},
+			{1, []byte("\xc3\xa9"), 0xE9, ""},
+		}},
+		{"\x80\xc0\xaf\xe0\x80\xaf\xed\xb0\x80\xf4\x90\x80\x80\xe2\x82A\xf8\xef\xbb\xbf", encUTF8, []DumpEntry{
+			{0, []byte("\x80"), -1, "unexpected continuation byte"},
+			{1, []byte("\xc0\xaf"), -1, "overlong encoding of U+002F"},
+			{3, []byte("\xe0\x80\xaf"), -1, "overlong encoding of U+002F"},
+			{6, []byte("\xed\xb0\x80"), 0xDC00, "surrogate encoded in UTF-8"},
+			{9, []byte("\xf4\x90\x80\x80"), -1, "beyond U+10FFFF"},
+			{13, []byte("\xe2\x82"), -1, "truncated sequence"},
+			{15, []byte("A"), 'A', ""},
+			{16, []byte("\xf8"), -1, "invalid byte"},
+			{17, []byte("\xef\xbb\xbf"), 0xFEFF, "byte order mark not at start of file"},
+		}},
+		{"\xfe\xff\xd8\x3d\xde\x00\xdc\x00\x00", encUTF16BE, []DumpEntry{
+			{0, []byte("\xfe\xff"), 0xFEFF, "byte order mark"},
+			{2, []byte("\xd8\x3d\xde\x00"), 0x1F600, ""},
+			{6, []byte("\xdc\x00"), 0xDC00, "unpaired surrogate"},
+			{8, []byte("\x00"), -1, "truncated code unit"},
+		}},
+		{"\x00\xf6\x01\x00\x00\x00\x11\x00\x00\xd8\x00\x00", encUTF32LE, []DumpEntry{
+			{0, []byte("\x00\xf6\x01\x00"), 0x1F600, ""},
+			{4, []byte("\x00\x00\x11\x00"), -1, "beyond U+10FFFF"},
+			{8, []byte("\x00\xd8\x00\x00"), 0xD800, "surrogate in UTF-32"},
+		}},
+	}
+	for _, tc := range testCases {
+		if got := Dump([]byte(tc.data), tc.encoding); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %q:\n\twant %q\n\tgot  %q", tc.encoding, tc.data, tc.want, got)
+		}
+	}
+}
+
+func TestRun_dump(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: dumpUCD, EastAsianWidthFile: eastAsianWidthSample,
+			EmojiDataFile: emojiDataSample}
+	}
+	var stdout, stderr strings.Builder
+	stdin := "\xef\xbb\xbfA\u202e\u4e00\xed\xa0\x80\xff"
+	status := run([]string{"dump"}, strings.NewReader(stdin), &stdout, &stderr)
+	want := "# UTF-8 (byte order mark)\n" +
+		"00000000  ef bb bf     U+FEFF   \u2b1a   ZERO WIDTH NO-BREAK SPACE (BYTE ORDER MARK) [byte order mark]\n" +
+		"00000003  41           U+0041   A   LATIN CAPITAL LETTER A\n" +
+		"00000004  e2 80 ae     U+202E   \u2b1a   RIGHT-TO-LEFT OVERRIDE\n" +
+		"00000007  e4 b8 80     U+4E00   \u4e00  CJK UNIFIED IDEOGRAPH-4E00\n" +
+		"0000000a  ed a0 80     U+D800   \ufffd   <surrogate-D800> [surrogate encoded in UTF-8]\n" +
+		"0000000d  ff           -        \ufffd   <invalid> [invalid byte]\n"
+	if stdout.String() != want || status != exitMatch {
+		t.Errorf("want: %d %q\ngot:  %d %q\nstderr: %q", exitMatch, want, status, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	status = run([]string{"dump", "-format", "json", "-encoding", "utf-16le"}, strings.NewReader("A\x00\x00"), &stdout, &stderr)
+	var dump jsonDump
+	if err := json.Unmarshal([]byte(stdout.String()), &dump); err != nil {
+		t.Fatalf("%v:\n%s", err, stdout.String())
+	}
+	wantDump := jsonDump{encUTF16LE, "-encoding", []jsonDumpEntry{
+		{Offset: 0, Bytes: "41 00", Code: "U+0041", Name: "LATIN CAPITAL LETTER A"},
+		{Offset: 2, Bytes: "00", Note: "truncated code unit"},
+	}}
+	if status != exitMatch || !reflect.DeepEqual(dump, wantDump) {
+		t.Errorf("want %+v; got %d %+v", wantDump, status, dump)
+	}
+
+	for _, args := range [][]string{
+		{"dump", "-encoding", "latin1"},
+		{"dump", "no-such-file"},
+		{"dump", "a", "b"},
+	} {
+		if status := run(args, strings.NewReader(""), &stdout, &stderr); status != exitUsage {
+			t.Errorf("%q: want %d; got %d", args, exitUsage, status)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+)
+
+// Exit codes. As with grep, 1 means the search ran fine but found
+// nothing; errors use 2 and up, so scripts can tell them apart.
+const (
+	exitMatch    = 0 // at least one character matched
+	exitNoMatch  = 1 // no character matched
+	exitFindings = 1 // a check such as analyze flagged characters
+	exitUsage    = 2 // bad flags or arguments
+	exitNotFound = 3 // a UCD file is missing and could not be fetched
+	exitDownload = 4 // a download failed
+	exitData     = 5 // a UCD file could not be read or parsed
+)
+
+var (
+	// ErrNotFound means no data source has the requested UCD file.
+	// It matches fs.ErrNotExist as well.
+	ErrNotFound = fmt.Errorf("UCD file not found: %w", fs.ErrNotExist)
+
+	// ErrDownload means a UCD file could not be fetched from any
+	// mirror because of network or server errors.
+	ErrDownload = errors.New("download failed")
+
+	// errNoMatch is returned by commands that ran fine but found
+	// nothing. It sets the exit status without printing a message.
+	errNoMatch = errors.New("no match")
+
+	// errFindings is returned by checks that ran fine and flagged
+	// characters, which they have already reported.
+	errFindings = errors.New("suspicious characters found")
+
+	// errFlags reports invalid flags, which the flag package has
+	// already explained.
+	errFlags = errors.New("invalid flags")
+)
+
+// UsageError reports invalid command-line arguments.
+type UsageError struct {
+	Command string
+	Msg     string
+}
+
+func (e *UsageError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Command, e.Msg)
+}
+
+// ParseError reports a malformed field in a UCD file.
+type ParseError struct {
+	Line  int    // 1-based line number, 0 if unknown
+	Field string // name of the field
+	Value string // text of the field
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s %q: %v", e.Line, e.Field, e.Value, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// exitCode returns the exit status main uses for err.
+func exitCode(err error) int {
+	var usageErr *UsageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitMatch
+	case errors.Is(err, errNoMatch):
+		return exitNoMatch
+	case errors.Is(err, errFindings):
+		return exitFindings
+	case errors.Is(err, errFlags), errors.As(err, &usageErr):
+		return exitUsage
+	case errors.Is(err, ErrDownload):
+		return exitDownload
+	case errors.Is(err, fs.ErrNotExist):
+		return exitNotFound
+	}
+	return exitData
+}
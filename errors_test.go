@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseRecord_errors(t *testing.T) {
+	var testCases = []struct {
+		line  string
+		field string
+	}{
+		{"0041;LATIN CAPITAL LETTER A", "line"},
+		{"ZZZZ;LATIN CAPITAL LETTER A;Lu;0;L;;;;;N;;;;0061;", "code point"},
+		{"0041;LATIN CAPITAL LETTER A;Lu;X;L;;;;;N;;;;0061;", "combining class"},
+		{"0041;LATIN CAPITAL LETTER A;Lu;0;L;;;;;N;;;;0G61;", "lowercase mapping"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.field, func(t *testing.T) {
+			_, err := ParseRecord(tc.line)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Field != tc.field {
+				t.Errorf("ParseRecord(%q)\n\twant ParseError in %s; got: %v",
+					tc.line, tc.field, err)
+			}
+		})
+	}
+}
+
+func TestScanText_errorLine(t *testing.T) {
+	text := lines3Dto43 + "0044;LATIN CAPITAL LETTER D\n"
+	_, err := LoadText(strings.NewReader(text))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 9 {
+		t.Errorf("want ParseError at line 9; got: %v", err)
+	}
+}
+
+func TestHTTPSource_errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/broken/"+UCDFile) {
+				http.Error(w, "oops", http.StatusInternalServerError)
+				return
+			}
+			http.NotFound(w, r)
+		}))
+	defer srv.Close()
+	_, err := (&HTTPSource{Mirrors: []string{srv.URL}}).Open(UCDFile)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing everywhere: want ErrNotFound; got: %v", err)
+	}
+	_, err = (&HTTPSource{Mirrors: []string{srv.URL + "/broken", srv.URL}}).Open(UCDFile)
+	if !errors.Is(err, ErrDownload) {
+		t.Errorf("server error: want ErrDownload; got: %v", err)
+	}
+}
+
+func TestRun_exitCodes(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	var testCases = []struct {
+		name   string
+		data   MemSource
+		args   []string
+		status int
+		output string
+	}{
+		{"match", MemSource{UCDFile: lines3Dto43}, []string{"MARK"},
+			exitMatch, "U+003F\t?\tQUESTION MARK\n"},
+		{"quiet", MemSource{UCDFile: lines3Dto43}, []string{"-q", "MARK"},
+			exitMatch, ""},
+		{"no match", MemSource{UCDFile: lines3Dto43}, []string{"ZZZZZZ"},
+			exitNoMatch, ""},
+		{"usage", MemSource{UCDFile: lines3Dto43}, []string{"-no-such-flag"},
+			exitUsage, ""},
+		{"not found", MemSource{}, []string{"MARK"},
+			exitNotFound, ""},
+		{"parse error", MemSource{UCDFile: "003F;QUESTION MARK"}, []string{"MARK"},
+			exitData, ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			newSource = func(*Config, ProgressFunc) DataSource { return tc.data }
+			var stdout, stderr bytes.Buffer
+			status := run(tc.args, strings.NewReader(""), &stdout, &stderr)
+			if status != tc.status || stdout.String() != tc.output {
+				t.Errorf("run(%q)\n\twant: %d %q\n\tgot:  %d %q (stderr: %q)",
+					tc.args, tc.status, tc.output, status, stdout.String(), stderr.String())
+			}
+		})
+	}
+}
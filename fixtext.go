@@ -0,0 +1,259 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// windows1252 holds the characters Windows-1252 decodes from the
+// bytes 0x80 to 0x9F. The five bytes it leaves undefined decode to
+// C1 controls, as in Latin-1, which is how most decoders treat them.
+var windows1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// mojibakeBytes maps the characters Windows-1252 or Latin-1 decode
+// from the bytes 0x80 to 0xFF back to those bytes.
+var mojibakeBytes = func() map[rune]byte {
+	bytes := map[rune]byte{}
+	for b := 0x80; b <= 0xFF; b++ {
+		bytes[rune(b)] = byte(b)
+	}
+	for i, char := range windows1252 {
+		bytes[char] = byte(0x80 + i)
+	}
+	return bytes
+}()
+
+// maxMojibakeRounds limits how many rounds of mis-decoding
+// FixMojibake undoes.
+const maxMojibakeRounds = 8
+
+// Repair is a character rebuilt from the mojibake its UTF-8 bytes
+// became when decoded as Windows-1252 or Latin-1.
+type Repair struct {
+	Round    int    // 1 for the outermost round of mis-decoding
+	Mojibake string // e.g. "Ã©"
+	Char     rune   // e.g. 'é'
+	Count    int    // occurrences in the round
+}
+
+// MojibakeFix is the result of FixMojibake.
+type MojibakeFix struct {
+	Text         string
+	Rounds       int
+	Repairs      []Repair
+	Confidence   float64 // from 0 to 1; 0 when nothing was repaired
+	Replacements int     // U+FFFD characters, which cannot be repaired
+}
+
+// FixMojibake repairs text whose UTF-8 was decoded as Windows-1252
+// or Latin-1, as in "CafÃ©" for "Café", one round at a time until no
+// sequence of characters spells out the UTF-8 encoding of another.
+// Text without such sequences is returned unchanged. The confidence
+// grows with the number and length of the repairs and shrinks with
+// repairs to unlikely characters and with Latin-1 characters left
+// alone, which suggest the text was not mojibake after all.
+func (u *UCD) FixMojibake(text string) MojibakeFix {
+	fix := MojibakeFix{Text: text, Repairs: []Repair{}}
+	for fix.Rounds < maxMojibakeRounds {
+		fixed, repairs := fixMojibakeRound(fix.Text)
+		if len(repairs) == 0 {
+			break
+		}
+		fix.Rounds++
+		for i := range repairs {
+			repairs[i].Round = fix.Rounds
+		}
+		fix.Text, fix.Repairs = fixed, append(fix.Repairs, repairs...)
+	}
+	fix.Replacements = strings.Count(fix.Text, string(replacement))
+	if fix.Rounds == 0 {
+		return fix
+	}
+	score, repaired := 1.0, map[rune]bool{}
+	for _, r := range fix.Repairs {
+		rec, ok := u.Lookup(r.Char)
+		if ok && !strings.HasPrefix(rec.Category, "C") {
+			score += float64(utf8.RuneCountInString(r.Mojibake)-1) * float64(r.Count)
+		} else {
+			score -= float64(r.Count)
+		}
+		repaired[r.Char] = true
+	}
+	for _, char := range fix.Text {
+		if _, ok := mojibakeBytes[char]; ok && !repaired[char] {
+			score--
+		}
+	}
+	if score > 0 {
+		fix.Confidence = 1 - math.Pow(0.5, score)
+	}
+	return fix
+}
+
+// fixMojibakeRound replaces each sequence of characters that
+// Windows-1252 or Latin-1 decode from the UTF-8 encoding of a
+// character with that character.
+func fixMojibakeRound(text string) (string, []Repair) {
+	var fixed strings.Builder
+	repairs := []Repair{}
+	index := map[string]int{}
+	chars := []rune(text)
+	for i := 0; i < len(chars); i++ {
+		seq := mojibakeSequence(chars[i:])
+		if seq == 0 {
+			fixed.WriteRune(chars[i])
+			continue
+		}
+		mojibake := string(chars[i : i+seq])
+		bytes := make([]byte, seq)
+		for j := range bytes {
+			bytes[j] = mojibakeBytes[chars[i+j]]
+		}
+		char, _ := utf8.DecodeRune(bytes)
+		fixed.WriteRune(char)
+		if n, ok := index[mojibake]; ok {
+			repairs[n].Count++
+		} else {
+			index[mojibake] = len(repairs)
+			repairs = append(repairs, Repair{Mojibake: mojibake, Char: char, Count: 1})
+		}
+		i += seq - 1
+	}
+	return fixed.String(), repairs
+}
+
+// mojibakeSequence returns the length of the sequence at the start
+// of chars that decodes, byte for byte, to a valid UTF-8 encoding of
+// a non-ASCII character, or 0 if there is none.
+func mojibakeSequence(chars []rune) int {
+	lead, ok := mojibakeBytes[chars[0]]
+	if !ok || lead < 0xC2 || lead > 0xF4 {
+		return 0
+	}
+	size := 2
+	if lead >= 0xF0 {
+		size = 4
+	} else if lead >= 0xE0 {
+		size = 3
+	}
+	if len(chars) < size {
+		return 0
+	}
+	bytes := []byte{lead}
+	for _, char := range chars[1:size] {
+		b, ok := mojibakeBytes[char]
+		if !ok {
+			return 0
+		}
+		bytes = append(bytes, b)
+	}
+	if char, n := utf8.DecodeRune(bytes); char == utf8.RuneError || n != size {
+		return 0
+	}
+	return size
+}
+
+// WriteExplanation tells what fix did, naming the characters of each
+// repair, as in `round 1: "Ã©" → "é": U+00C3 ... + U+00A9 ... are the
+// bytes c3 a9, the UTF-8 encoding of U+00E9 ...`.
+func (fix MojibakeFix) WriteExplanation(w io.Writer, u *UCD) {
+	if fix.Rounds == 0 {
+		fmt.Fprintln(w, "no mojibake found")
+	} else {
+		fmt.Fprintf(w, "confidence %.2f, %d %s of UTF-8 read as Windows-1252 or Latin-1\n",
+			fix.Confidence, fix.Rounds, plural(fix.Rounds, "round", "rounds"))
+	}
+	for _, r := range fix.Repairs {
+		names := []string{}
+		bytes := []byte{}
+		for _, char := range r.Mojibake {
+			names = append(names, fmt.Sprintf("U+%04X %s", char, u.Name(char)))
+			bytes = append(bytes, mojibakeBytes[char])
+		}
+		count := ""
+		if r.Count > 1 {
+			count = fmt.Sprintf(" (%d times)", r.Count)
+		}
+		fmt.Fprintf(w, "round %d: %q → %q%s: %s are the bytes % x, the UTF-8 encoding of U+%04X %s\n",
+			r.Round, r.Mojibake, string(r.Char), count, strings.Join(names, " + "), bytes, r.Char, u.Name(r.Char))
+	}
+	if fix.Replacements > 0 {
+		fmt.Fprintf(w, "%d U+FFFD REPLACEMENT CHARACTER: bytes that were not valid UTF-8 were replaced and cannot be recovered\n",
+			fix.Replacements)
+	}
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// jsonMojibakeFix is a MojibakeFix as JSON output shows it.
+type jsonMojibakeFix struct {
+	Text         string       `json:"text"`
+	Fixed        string       `json:"fixed"`
+	Confidence   float64      `json:"confidence"`
+	Rounds       int          `json:"rounds"`
+	Repairs      []jsonRepair `json:"repairs"`
+	Replacements int          `json:"replacement_characters"`
+}
+
+type jsonRepair struct {
+	Round    int    `json:"round"`
+	Mojibake string `json:"mojibake"`
+	Code     string `json:"code"`
+	Char     string `json:"char"`
+	Name     string `json:"name"`
+	Count    int    `json:"count"`
+}
+
+func fixtextFlags(c *cli, flags *flag.FlagSet) {
+	formatFlags(c, flags)
+	flags.BoolVar(&c.opts.q, "q", false, "print only the repaired text; implies -quiet")
+}
+
+func runFixtext(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	c.quiet = c.quiet || c.opts.q
+	text := strings.Join(flags.Args(), " ") + "\n"
+	if flags.NArg() == 0 {
+		input, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return err
+		}
+		text = string(input)
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	fix := ucd.FixMojibake(text)
+	if c.cfg.Format == formatJSON {
+		result := jsonMojibakeFix{text, fix.Text, math.Round(fix.Confidence*100) / 100,
+			fix.Rounds, []jsonRepair{}, fix.Replacements}
+		for _, r := range fix.Repairs {
+			result.Repairs = append(result.Repairs, jsonRepair{r.Round, r.Mojibake,
+				fmt.Sprintf("U+%04X", r.Char), string(r.Char), ucd.Name(r.Char), r.Count})
+		}
+		return encodeJSON(c.stdout, result)
+	}
+	fmt.Fprint(c.stdout, fix.Text)
+	if !c.opts.q {
+		fix.WriteExplanation(c.stderr, ucd)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixtextUCD holds UnicodeData.txt lines for the fixtext tests.
+const fixtextUCD = analyzeUCD + `00A9;COPYRIGHT SIGN;So;0;ON;;;;;N;;;;;
+00C2;LATIN CAPITAL LETTER A WITH CIRCUMFLEX;Lu;0;L;0041 0302;;;;N;LATIN CAPITAL LETTER A CIRCUMFLEX;;;00E2;
+00C3;LATIN CAPITAL LETTER A WITH TILDE;Lu;0;L;0041 0303;;;;N;LATIN CAPITAL LETTER A TILDE;;;00E3;
+00E2;LATIN SMALL LETTER A WITH CIRCUMFLEX;Ll;0;L;0061 0302;;;;N;LATIN SMALL LETTER A CIRCUMFLEX;;00C2;;00C2
+00EF;LATIN SMALL LETTER I WITH DIAERESIS;Ll;0;L;0069 0308;;;;N;LATIN SMALL LETTER I DIAERESIS;;00CF;;00CF
+0192;LATIN SMALL LETTER F WITH HOOK;Ll;0;L;;;;;N;LATIN SMALL LETTER SCRIPT F;;0191;;0191
+2019;RIGHT SINGLE QUOTATION MARK;Pf;0;ON;;;;;N;SINGLE COMMA QUOTATION MARK;;;;
+20AC;EURO SIGN;Sc;0;ET;;;;;N;;;;;
+2122;TRADE MARK SIGN;So;0;ON;<super> 0054 004D;;;;N;TRADEMARK;;;;
+`
+
+func TestUCD_FixMojibake(t *testing.T) {
+	ucd, err := LoadUCD(strings.NewReader(fixtextUCD), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		text       string
+		want       string
+		rounds     int
+		confidence float64
+	}{
+		{"Caf\u00c3\u00a9", "Caf\u00e9", 1, 0.75},
+		{"it\u00e2\u20ac\u2122s", "it\u2019s", 1, 0.875},
+		{"Caf\u00c3\u0192\u00c2\u00a9", "Caf\u00e9", 2, 0.9375},
+		{"na\u00efve Caf\u00c3\u00a9", "na\u00efve Caf\u00e9", 1, 0.5},
+		{"\u00c3\u0081", "\u00c1", 1, 0}, // not in the UCD sample
+		{"\u00c3\u00a9\ufffd", "\u00e9\ufffd", 1, 0.75},
+		{"na\u00efve caf\u00e9 \u2019", "na\u00efve caf\u00e9 \u2019", 0, 0},
+		{"\u00c3 \u00a9 \u00c3", "\u00c3 \u00a9 \u00c3", 0, 0},
+	}
+	for _, tc := range testCases {
+		fix := ucd.FixMojibake(tc.text)
+		if fix.Text != tc.want || fix.Rounds != tc.rounds || fix.Confidence != tc.confidence {
+			t.Errorf("%q: want %q in %d rounds, confidence %v; got %q in %d, %v",
+				tc.text, tc.want, tc.rounds, tc.confidence, fix.Text, fix.Rounds, fix.Confidence)
+		}
+	}
+	fix := ucd.FixMojibake("\u00c3\u00a9\u00c3\u00a9\ufffd")
+	want := []Repair{{1, "\u00c3\u00a9", '\u00e9', 2}}
+	if !reflect.DeepEqual(fix.Repairs, want) || fix.Replacements != 1 {
+		t.Errorf("want %v and 1 replacement; got %v and %d", want, fix.Repairs, fix.Replacements)
+	}
+}
+
+func TestRun_fixtext(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: fixtextUCD}
+	}
+	testCases := []struct {
+		args   []string
+		stdin  string
+		stdout string
+		stderr string
+	}{
+		{[]string{"fixtext", "Caf\u00c3\u0192\u00c2\u00a9"}, "", "Caf\u00e9\n",
+			"confidence 0.94, 2 rounds of UTF-8 read as Windows-1252 or Latin-1\n" +
+				"round 1: \"\u00c3\u0192\" \u2192 \"\u00c3\": U+00C3 LATIN CAPITAL LETTER A WITH TILDE (LATIN CAPITAL LETTER A TILDE) + " +
+				"U+0192 LATIN SMALL LETTER F WITH HOOK (LATIN SMALL LETTER SCRIPT F) are the bytes c3 83, " +
+				"the UTF-8 encoding of U+00C3 LATIN CAPITAL LETTER A WITH TILDE (LATIN CAPITAL LETTER A TILDE)\n" +
+				"round 1: \"\u00c2\u00a9\" \u2192 \"\u00a9\": U+00C2 LATIN CAPITAL LETTER A WITH CIRCUMFLEX (LATIN CAPITAL LETTER A CIRCUMFLEX) + " +
+				"U+00A9 COPYRIGHT SIGN are the bytes c2 a9, the UTF-8 encoding of U+00A9 COPYRIGHT SIGN\n" +
+				"round 2: \"\u00c3\u00a9\" \u2192 \"\u00e9\": U+00C3 LATIN CAPITAL LETTER A WITH TILDE (LATIN CAPITAL LETTER A TILDE) + " +
+				"U+00A9 COPYRIGHT SIGN are the bytes c3 a9, the UTF-8 encoding of U+00E9 LATIN SMALL LETTER E WITH ACUTE (LATIN SMALL LETTER E ACUTE)\n"},
+		{[]string{"fixtext", "-q"}, "a\u00e2\u20ac\u2122\n\u00c3\u00a9\n", "a\u2019\n\u00e9\n", ""},
+		{[]string{"fixtext"}, "caf\u00e9\ufffd\n", "caf\u00e9\ufffd\n",
+			"no mojibake found\n1 U+FFFD REPLACEMENT CHARACTER: bytes that were not valid UTF-8 were replaced and cannot be recovered\n"},
+	}
+	for _, tc := range testCases {
+		var stdout, stderr strings.Builder
+		status := run(tc.args, strings.NewReader(tc.stdin), &stdout, &stderr)
+		if status != exitMatch || stdout.String() != tc.stdout || stderr.String() != tc.stderr {
+			t.Errorf("%q:\n\twant: %d %q %q\n\tgot:  %d %q %q", tc.args, exitMatch, tc.stdout, tc.stderr,
+				status, stdout.String(), stderr.String())
+		}
+	}
+
+	var stdout, stderr strings.Builder
+	run([]string{"fixtext", "-format", "json", "it\u00e2\u20ac\u2122s"}, nil, &stdout, &stderr)
+	var result jsonMojibakeFix
+	if err := json.Unmarshal([]byte(stdout.String()), &result); err != nil {
+		t.Fatalf("%v:\n%s", err, stdout.String())
+	}
+	want := jsonMojibakeFix{"it\u00e2\u20ac\u2122s\n", "it\u2019s\n", 0.88, 1, []jsonRepair{
+		{1, "\u00e2\u20ac\u2122", "U+2019", "\u2019", "RIGHT SINGLE QUOTATION MARK (SINGLE COMMA QUOTATION MARK)", 1},
+	}, 0}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("want %+v; got %+v", want, result)
+	}
+}
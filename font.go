@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"unicode/utf8"
+)
+
+// Font is a TrueType or OpenType font, read far enough to tell which
+// characters it has glyphs for. Of a collection, it is the first font.
+type Font struct {
+	Name   string            // file name
+	data   []byte            // the whole file
+	tables map[string][]byte // by tag, such as "cmap"
+	glyphs map[rune]uint16   // glyph index of each character, from the cmap
+}
+
+// errFontData reports font data that is truncated or malformed.
+var errFontData = errors.New("malformed font data")
+
+// fontReader reads big-endian numbers from font data, remembering
+// that a read was out of bounds instead of failing each time.
+type fontReader struct {
+	data []byte
+	bad  bool
+}
+
+func (r *fontReader) u16(offset int) uint16 {
+	if offset < 0 || offset+2 > len(r.data) {
+		r.bad = true
+		return 0
+	}
+	return binary.BigEndian.Uint16(r.data[offset:])
+}
+
+func (r *fontReader) u32(offset int) uint32 {
+	if offset < 0 || offset+4 > len(r.data) {
+		r.bad = true
+		return 0
+	}
+	return binary.BigEndian.Uint32(r.data[offset:])
+}
+
+// ReadFont reads the font file at path.
+func ReadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	font, err := ParseFont(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	font.Name = path
+	return font, nil
+}
+
+// ParseFont reads a TrueType or OpenType font, or the first font of
+// a collection, from data: its tables and the Unicode subtables of
+// its cmap.
+func ParseFont(data []byte) (*Font, error) {
+	r := &fontReader{data: data}
+	offset := 0
+	if r.u32(0) == 0x74746366 { // "ttcf": a collection
+		if r.u32(8) == 0 {
+			return nil, errors.New("empty font collection")
+		}
+		offset = int(r.u32(12))
+	}
+	switch r.u32(offset) {
+	case 0x00010000, 0x4F54544F, 0x74727565: // TrueType, "OTTO", "true"
+	default:
+		return nil, errors.New("not a TrueType or OpenType font")
+	}
+	font := &Font{data: data, tables: map[string][]byte{}, glyphs: map[rune]uint16{}}
+	for i := 0; i < int(r.u16(offset+4)); i++ {
+		record := offset + 12 + 16*i
+		start, length := int(r.u32(record+8)), int(r.u32(record+12))
+		if r.bad || start < 0 || length < 0 || start+length > len(data) || start+length < start {
+			return nil, errFontData
+		}
+		font.tables[string(data[record:record+4])] = data[start : start+length]
+	}
+	if r.bad {
+		return nil, errFontData
+	}
+	if _, ok := font.tables["cmap"]; !ok {
+		return nil, errors.New("font has no cmap table")
+	}
+	if err := font.readCmap(); err != nil {
+		return nil, fmt.Errorf("cmap: %w", err)
+	}
+	return font, nil
+}
+
+// readCmap maps characters to glyphs from the Unicode subtables of
+// the cmap: those of platform 0 and the BMP and full repertoire ones
+// of platform 3 (Windows). Where subtables disagree, the first wins.
+// Glyph 0 is the missing glyph, so characters mapped to it, or to a
+// glyph past the last, are left out.
+func (f *Font) readCmap() error {
+	r := &fontReader{data: f.tables["cmap"]}
+	numGlyphs := 0x10000
+	if maxp := f.tables["maxp"]; len(maxp) >= 6 {
+		numGlyphs = int(binary.BigEndian.Uint16(maxp[4:]))
+	}
+	add := func(char rune, glyph uint32) {
+		if _, ok := f.glyphs[char]; !ok && glyph != 0 && glyph < uint32(numGlyphs) {
+			f.glyphs[char] = uint16(glyph)
+		}
+	}
+	for i := 0; i < int(r.u16(2)); i++ {
+		platform, encoding := r.u16(4+8*i), r.u16(6+8*i)
+		if platform == 0 && encoding == 5 || // variation sequences
+			platform != 0 && !(platform == 3 && (encoding == 1 || encoding == 10)) {
+			continue
+		}
+		sub := int(r.u32(8 + 8*i))
+		switch r.u16(sub) {
+		case 4:
+			segments := int(r.u16(sub+6)) / 2
+			ends, starts := sub+14, sub+16+2*segments
+			deltas, rangeOffsets := starts+2*segments, starts+4*segments
+			for s := 0; s < segments && !r.bad; s++ {
+				start, end := rune(r.u16(starts+2*s)), rune(r.u16(ends+2*s))
+				delta, rangeOffset := uint32(r.u16(deltas+2*s)), int(r.u16(rangeOffsets+2*s))
+				for char := start; char <= end && char != 0xFFFF && !r.bad; char++ {
+					glyph := (uint32(char) + delta) & 0xFFFF
+					if rangeOffset != 0 {
+						glyph = uint32(r.u16(rangeOffsets + 2*s + rangeOffset + 2*int(char-start)))
+						if glyph != 0 {
+							glyph = (glyph + delta) & 0xFFFF
+						}
+					}
+					add(char, glyph)
+				}
+			}
+		case 6:
+			first := rune(r.u16(sub + 6))
+			for j := 0; j < int(r.u16(sub+8)) && !r.bad; j++ {
+				add(first+rune(j), uint32(r.u16(sub+10+2*j)))
+			}
+		case 10:
+			first := rune(r.u32(sub + 12))
+			for j := 0; j < int(r.u32(sub+16)) && first+rune(j) <= utf8.MaxRune && !r.bad; j++ {
+				add(first+rune(j), uint32(r.u16(sub+20+2*j)))
+			}
+		case 12, 13:
+			many := r.u16(sub) == 13 // all characters of a group map to the same glyph
+			// Groups do not overlap, so together they have no more
+			// characters than Unicode; malformed ones may each claim
+			// all of them.
+			left := uint32(utf8.MaxRune + 1)
+			for j := 0; j < int(r.u32(sub+12)) && left > 0 && !r.bad; j++ {
+				group := sub + 16 + 12*j
+				start, end, glyph := r.u32(group), min(r.u32(group+4), utf8.MaxRune), r.u32(group+8)
+				if start > end || !many && glyph >= uint32(numGlyphs) {
+					continue
+				}
+				if !many { // past the last glyph, characters are missing
+					end = min(end, start+uint32(numGlyphs)-1-glyph)
+				}
+				end = min(end, start+left-1)
+				left -= end - start + 1
+				for char := start; char <= end; char++ {
+					if many {
+						add(rune(char), glyph)
+					} else {
+						add(rune(char), glyph+char-start)
+					}
+				}
+			}
+		}
+		if r.bad {
+			return errFontData
+		}
+	}
+	return nil
+}
+
+// Has reports whether the font has a glyph for char.
+func (f *Font) Has(char rune) bool {
+	_, ok := f.glyphs[char]
+	return ok
+}
+
+// HasAll reports whether the font has a glyph for every character
+// of s, as a sequence of characters needs.
+func (f *Font) HasAll(s string) bool {
+	for _, char := range s {
+		if !f.Has(char) {
+			return false
+		}
+	}
+	return true
+}
+
+// Glyph filters for search results.
+const (
+	glyphsAll     = "all"
+	glyphsCovered = "covered"
+	glyphsMissing = "missing"
+)
+
+// noGlyphMark is appended to the names of results the font has no
+// glyph for.
+const noGlyphMark = " [no glyph]"
+
+// MarkGlyphs returns the results the font has glyphs for, those it
+// has none for, or all of them, as which is glyphsCovered,
+// glyphsMissing or glyphsAll. The names of those without glyphs get
+// noGlyphMark.
+func (f *Font) MarkGlyphs(results [][3]string, which string) [][3]string {
+	marked := [][3]string{}
+	for _, fields := range results {
+		covered := f.HasAll(fields[1])
+		if which == glyphsCovered && !covered || which == glyphsMissing && covered {
+			continue
+		}
+		if !covered {
+			fields[2] += noGlyphMark
+		}
+		marked = append(marked, fields)
+	}
+	return marked
+}
+
+// BlockCoverage is how many of the assigned characters of a block a
+// font has glyphs for.
+type BlockCoverage struct {
+	Block    string `json:"block"`
+	First    string `json:"first"`
+	Last     string `json:"last"`
+	Assigned int    `json:"assigned"`
+	Covered  int    `json:"covered"`
+}
+
+// Percent returns the share of the assigned characters covered.
+func (bc BlockCoverage) Percent() float64 {
+	return 100 * float64(bc.Covered) / float64(bc.Assigned)
+}
+
+// Coverage returns the coverage by font of the blocks read by
+// ReadBlocks that have assigned characters, in file order.
+// Surrogates are not characters, so they are left out.
+func (u *UCD) Coverage(font *Font) []BlockCoverage {
+	coverage := []BlockCoverage{}
+	for _, block := range u.blocks {
+		bc := BlockCoverage{Block: block.name,
+			First: fmt.Sprintf("U+%04X", block.first), Last: fmt.Sprintf("U+%04X", block.last)}
+		count := func(char rune) {
+			bc.Assigned++
+			if font.Has(char) {
+				bc.Covered++
+			}
+		}
+		for char := block.first; char <= block.last; char++ {
+			if i, ok := u.byChar[char]; ok && u.Records[i].Category != "Cs" {
+				count(char)
+			}
+		}
+		for _, rng := range u.ranges {
+			if rng.first.Category == "Cs" {
+				continue
+			}
+			for char := max(rng.first.Char, block.first); char <= min(rng.last, block.last); char++ {
+				count(char)
+			}
+		}
+		if bc.Assigned > 0 {
+			coverage = append(coverage, bc)
+		}
+	}
+	return coverage
+}
+
+// loadFont reads the font file at path for cmd, or returns nil if
+// path is empty.
+func (c *cli) loadFont(cmd *command, path string) (*Font, error) {
+	if path == "" {
+		return nil, nil
+	}
+	font, err := ReadFont(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, &UsageError{cmd.name, err.Error()}
+	}
+	return font, err
+}
+
+// checkGlyphs returns a usage error if which, the value of -glyphs,
+// is not a glyph filter, or filters without a font.
+func checkGlyphs(cmd *command, which, fontPath string) error {
+	switch {
+	case which != glyphsAll && which != glyphsCovered && which != glyphsMissing:
+		return &UsageError{cmd.name, fmt.Sprintf("invalid -glyphs %q: use all, covered or missing", which)}
+	case which != glyphsAll && fontPath == "":
+		return &UsageError{cmd.name, "-glyphs needs -font"}
+	}
+	return nil
+}
+
+func coverageFlags(c *cli, flags *flag.FlagSet) {
+	formatFlags(c, flags)
+	flags.BoolVar(&c.opts.all, "all", false, "list the blocks the font has no glyphs for, too")
+}
+
+func runCoverage(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return &UsageError{cmd.name, "name one font file"}
+	}
+	font, err := c.loadFont(cmd, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	if err := c.loadBlocks(ucd); err != nil {
+		return err
+	}
+	coverage := []BlockCoverage{}
+	for _, bc := range ucd.Coverage(font) {
+		if bc.Covered > 0 || c.opts.all {
+			coverage = append(coverage, bc)
+		}
+	}
+	if len(coverage) == 0 {
+		return errNoMatch
+	}
+	if c.cfg.Format == formatJSON {
+		return encodeJSON(c.stdout, coverage)
+	}
+	writeCoverage(c.stdout, coverage)
+	return nil
+}
+
+// loadBlocks reads Blocks.txt into ucd, unless it has read it
+// already. It is loadProperties for commands that need no scripts.
+func (c *cli) loadBlocks(ucd *UCD) error {
+	if len(ucd.blocks) > 0 {
+		return nil
+	}
+	file, err := c.openFile(BlocksFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := ucd.ReadBlocks(file); err != nil {
+		return fmt.Errorf("%s: %w", BlocksFile, err)
+	}
+	return nil
+}
+
+// writeCoverage writes coverage as a table with a line per block.
+func writeCoverage(w io.Writer, coverage []BlockCoverage) {
+	for _, bc := range coverage {
+		fmt.Fprintf(w, "%5.1f%%  %5d/%-5d  %s..%s  %s\n", bc.Percent(), bc.Covered, bc.Assigned,
+			bc.First, bc.Last, bc.Block)
+	}
+}
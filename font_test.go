@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// sfnt returns a font file with tables, in tag order, as ParseFont
+// reads them; checksums are left zero.
+func sfnt(tables map[string][]byte, tags ...string) []byte {
+	font := binary.BigEndian.AppendUint32(nil, 0x00010000)
+	font = binary.BigEndian.AppendUint16(font, uint16(len(tags)))
+	font = append(font, make([]byte, 6)...)
+	offset := 12 + 16*len(tags)
+	for _, tag := range tags {
+		font = append(font, tag...)
+		font = binary.BigEndian.AppendUint32(font, 0)
+		font = binary.BigEndian.AppendUint32(font, uint32(offset))
+		font = binary.BigEndian.AppendUint32(font, uint32(len(tables[tag])))
+		offset += len(tables[tag])
+	}
+	for _, tag := range tags {
+		font = append(font, tables[tag]...)
+	}
+	return font
+}
+
+// u16s returns values as big-endian 16-bit numbers.
+func u16s(values ...int) []byte {
+	b := []byte{}
+	for _, v := range values {
+		b = binary.BigEndian.AppendUint16(b, uint16(v))
+	}
+	return b
+}
+
+// u32s returns values as big-endian 32-bit numbers.
+func u32s(values ...int) []byte {
+	b := []byte{}
+	for _, v := range values {
+		b = binary.BigEndian.AppendUint32(b, uint32(v))
+	}
+	return b
+}
+
+// testFont returns a font with 20 glyphs mapping, in a format 4
+// subtable, 'A'..'C' by delta, 'a' and 'c' by the glyph array and
+// U+2190 to the missing glyph; and in a format 12 subtable, U+1F600
+// and U+1F601, and U+1F640 to a glyph past the last.
+func testFont() []byte {
+	format4 := u16s(4, 0, 0, 8, 0, 0, 0)
+	format4 = append(format4, u16s(0x43, 0x63, 0x2190, 0xFFFF)...)          // ends
+	format4 = append(format4, u16s(0)...)                                   // padding
+	format4 = append(format4, u16s(0x41, 0x61, 0x2190, 0xFFFF)...)          // starts
+	format4 = append(format4, u16s(-0x40, 0, 0, 1)...)                      // deltas
+	format4 = append(format4, u16s(0, 6, 0, 0)...)                          // range offsets
+	format4 = append(format4, u16s(4, 0, 5)...)                             // glyphs of 'a'..'c'
+	binary.BigEndian.PutUint16(format4[2:], uint16(len(format4)))           // length
+	format12 := append(u16s(12, 0), u32s(0, 0, 2, 0x1F600, 0x1F601, 10)...) // two groups
+	format12 = append(format12, u32s(0x1F640, 0x1F640, 20)...)
+	cmap := append(u16s(0, 2), u16s(3, 1)...)
+	cmap = append(cmap, u32s(20)...)
+	cmap = append(cmap, u16s(3, 10)...)
+	cmap = append(cmap, u32s(20+len(format4))...)
+	cmap = append(append(cmap, format4...), format12...)
+	maxp := append(u32s(0x5000), u16s(20)...)
+	return sfnt(map[string][]byte{"cmap": cmap, "maxp": maxp}, "cmap", "maxp")
+}
+
+func TestParseFont(t *testing.T) {
+	font, err := ParseFont(testFont())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[rune]uint16{'A': 1, 'B': 2, 'C': 3, 'a': 4, 'c': 5, 0x1F600: 10, 0x1F601: 11}
+	if !reflect.DeepEqual(font.glyphs, want) {
+		t.Errorf("\n\twant: %v\n\tgot:  %v", want, font.glyphs)
+	}
+
+	// The same font as the second of a collection.
+	collection := append([]byte("ttcf"), u32s(0x10000, 1, 16)...)
+	collection = append(collection, testFont()...)
+	for offset := 16; offset < 16+12+16*2; offset += 16 { // move the table offsets
+		binary.BigEndian.PutUint32(collection[offset+12+8:], binary.BigEndian.Uint32(collection[offset+12+8:])+16)
+	}
+	if font, err := ParseFont(collection); err != nil || !font.Has(0x1F601) {
+		t.Errorf("collection: want U+1F601; got %v", err)
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":     {},
+		"not font":  []byte("GIF89a, not a font at all"),
+		"no cmap":   sfnt(map[string][]byte{"maxp": u32s(0x5000, 0)}, "maxp"),
+		"truncated": testFont()[:60],
+	} {
+		if _, err := ParseFont(data); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+// TestParseFont_malformedGroups checks that format 12 and 13
+// subtables whose groups claim every character, over and over, or are
+// backwards or past Unicode, are read quickly.
+func TestParseFont_malformedGroups(t *testing.T) {
+	const groups = 5000
+	subtable := func(format int) []byte {
+		sub := append(u16s(format, 0), u32s(0, 0, 2+groups, 0x50, 0x40, 1, 0xFFFFFF00, 0xFFFFFFFF, 1)...)
+		for i := 0; i < groups; i++ {
+			sub = append(sub, u32s(0, utf8.MaxRune, 1)...)
+		}
+		return sub
+	}
+	maxp := append(u32s(0x5000), u16s(20)...)
+	for format, want := range map[int]int{12: 19, 13: utf8.MaxRune + 1} {
+		cmap := append(u16s(0, 1), u16s(3, 10)...)
+		cmap = append(append(cmap, u32s(12)...), subtable(format)...)
+		font, err := ParseFont(sfnt(map[string][]byte{"cmap": cmap, "maxp": maxp}, "cmap", "maxp"))
+		if err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		if len(font.glyphs) != want || font.glyphs[0x50] != map[int]uint16{12: 0, 13: 1}[format] {
+			t.Errorf("format %d: want %d characters; got %d", format, want, len(font.glyphs))
+		}
+	}
+}
+
+func TestMarkGlyphs(t *testing.T) {
+	font, err := ParseFont(testFont())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := [][3]string{{"U+0041", "A", "A"}, {"U+0062", "b", "B"}, {"", "Ac", "AC"}, {"", "Ab", "AB"}}
+	var testCases = []struct {
+		which string
+		want  [][3]string
+	}{
+		{glyphsAll, [][3]string{{"U+0041", "A", "A"}, {"U+0062", "b", "B [no glyph]"},
+			{"", "Ac", "AC"}, {"", "Ab", "AB [no glyph]"}}},
+		{glyphsCovered, [][3]string{{"U+0041", "A", "A"}, {"", "Ac", "AC"}}},
+		{glyphsMissing, [][3]string{{"U+0062", "b", "B [no glyph]"}, {"", "Ab", "AB [no glyph]"}}},
+	}
+	for _, tc := range testCases {
+		if got := font.MarkGlyphs(results, tc.which); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s:\n\twant: %q\n\tgot:  %q", tc.which, tc.want, got)
+		}
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	font, err := ParseFont(testFont())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ucd, err := LoadUCD(strings.NewReader(ucdSample), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ucd.ReadBlocks(strings.NewReader("0000..007F; Basic Latin\n2300..23FF; Miscellaneous Technical\n" +
+		"27C0..27EF; Miscellaneous Mathematical Symbols-A\n1F600..1F64F; Emoticons\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	writeCoverage(&b, ucd.Coverage(font))
+	want := ` 30.0%      3/10     U+0000..U+007F  Basic Latin
+  0.0%      0/2      U+2300..U+23FF  Miscellaneous Technical
+ 25.0%      2/8      U+1F600..U+1F64F  Emoticons
+`
+	if got := b.String(); got != want {
+		t.Errorf("\n\twant: %q\n\tgot:  %q", want, got)
+	}
+}
+
+func TestRun_font(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: ucdSample, BlocksFile: "0000..007F; Basic Latin\n2300..23FF; Miscellaneous Technical\n1F600..1F64F; Emoticons\n",
+			ScriptsFile: scriptsSample}
+	}
+	path := filepath.Join(t.TempDir(), "test.ttf")
+	if err := os.WriteFile(path, testFont(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var testCases = []struct {
+		args   []string
+		status int
+		output string
+	}{
+		{[]string{"search", "-font", path, "grinning"}, exitMatch, "U+1F600\t\U0001F600\tGRINNING FACE\n" +
+			"U+1F601\t\U0001F601\tGRINNING FACE WITH SMILING EYES\n" +
+			"U+1F638\t\U0001F638\tGRINNING CAT FACE WITH SMILING EYES [no glyph]\n"},
+		{[]string{"-font", path, "-glyphs", "missing", "grinning"}, exitMatch,
+			"U+1F638\t\U0001F638\tGRINNING CAT FACE WITH SMILING EYES [no glyph]\n"},
+		{[]string{"-font", path, "-glyphs", "covered", "cat"}, exitNoMatch, ""},
+		{[]string{"-glyphs", "covered", "cat"}, exitUsage, ""},
+		{[]string{"-font", path, "-glyphs", "some", "cat"}, exitUsage, ""},
+		{[]string{"-font", path + ".missing", "cat"}, exitUsage, ""},
+		{[]string{"coverage", path}, exitMatch, " 30.0%      3/10     U+0000..U+007F  Basic Latin\n" +
+			" 25.0%      2/8      U+1F600..U+1F64F  Emoticons\n"},
+		{[]string{"coverage", "-all", path}, exitMatch, " 30.0%      3/10     U+0000..U+007F  Basic Latin\n" +
+			"  0.0%      0/2      U+2300..U+23FF  Miscellaneous Technical\n" +
+			" 25.0%      2/8      U+1F600..U+1F64F  Emoticons\n"},
+		{[]string{"coverage", "-format", "json", path}, exitMatch, `[
+  {
+    "block": "Basic Latin",
+    "first": "U+0000",
+    "last": "U+007F",
+    "assigned": 10,
+    "covered": 3
+  },
+  {
+    "block": "Emoticons",
+    "first": "U+1F600",
+    "last": "U+1F64F",
+    "assigned": 8,
+    "covered": 2
+  }
+]
+`},
+		{[]string{"coverage"}, exitUsage, ""},
+		{[]string{"coverage", "font_test.go"}, exitData, ""},
+	}
+	for _, tc := range testCases {
+		status, output, stderr := runArgs(tc.args...)
+		if status != tc.status || output != tc.output {
+			t.Errorf("%q:\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q", tc.args, tc.status, tc.output, status, output, stderr)
+		}
+	}
+}
+
+func TestRun_coverage_xml(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDXMLFile: xmlSample, BlocksFile: "0000..007F; Basic Latin\n1F600..1F64F; Emoticons\n"}
+	}
+	t.Setenv("UCD_PATH", filepath.Join(t.TempDir(), UCDXMLFile))
+	path := filepath.Join(t.TempDir(), "test.ttf")
+	if err := os.WriteFile(path, testFont(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := " 40.0%      2/5      U+0000..U+007F  Basic Latin\n" +
+		"100.0%      1/1      U+1F600..U+1F64F  Emoticons\n"
+	if status, output, stderr := runArgs("coverage", path); status != exitMatch || output != want {
+		t.Errorf("want: %d %q\ngot:  %d %q\nstderr: %q", exitMatch, want, status, output, stderr)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// goConst is a character constant of generated Go code.
+type goConst struct {
+	ident string
+	rec   Record
+}
+
+// goIdent returns the Go identifier for a character name, as in
+// "LeftwardsArrow" for "LEFTWARDS ARROW": its words capitalized and
+// run together. Words with digits, as in "CJK UNIFIED IDEOGRAPH-4E00",
+// are kept as they are.
+func goIdent(name string) string {
+	var ident strings.Builder
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if strings.IndexFunc(word, unicode.IsDigit) < 0 {
+			word = word[:1] + strings.ToLower(word[1:])
+		}
+		ident.WriteString(word)
+	}
+	if ident.Len() == 0 || !unicode.IsLetter(rune(ident.String()[0])) {
+		return "Char" + ident.String()
+	}
+	return ident.String()
+}
+
+// goConsts names the characters of recs. Controls are named after
+// their Unicode 1.0 names, if any, and characters without a name after
+// their code points. Identifiers that would be the same get the code
+// point of their character appended, as in "ArrowU2190".
+func goConsts(recs []Record) []goConst {
+	consts := make([]goConst, len(recs))
+	uses := map[string]int{}
+	for i, rec := range recs {
+		name := rec.Name
+		if strings.HasPrefix(name, "<") {
+			name = rec.OldName
+		}
+		if name == "" {
+			name = fmt.Sprintf("U%04X", rec.Char)
+		}
+		consts[i] = goConst{goIdent(name), rec}
+		uses[consts[i].ident]++
+	}
+	for i := range consts {
+		if uses[consts[i].ident] > 1 {
+			consts[i].ident += fmt.Sprintf("U%04X", consts[i].rec.Char)
+		}
+	}
+	return consts
+}
+
+// goRune returns the Go rune literal for rec's character, escaped
+// if the character is invisible, combining or a separator, which
+// would be hard to read or edit in source code.
+func goRune(rec Record) string {
+	if rec.Char >= 0x80 && strings.IndexAny(rec.Category, "CMZ") == 0 {
+		return strconv.QuoteRuneToASCII(rec.Char)
+	}
+	return strconv.QuoteRune(rec.Char)
+}
+
+// WriteGo writes a gofmt-formatted Go file declaring a constant for
+// each character of recs, in package pkg. The header says it was
+// generated by command, so tools leave it alone.
+func WriteGo(w io.Writer, pkg, command string, recs []Record) error {
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by %q; DO NOT EDIT.\n\n", command)
+	fmt.Fprintf(&src, "package %s\n\n", pkg)
+	fmt.Fprintf(&src, "// Characters found by %q.\n", command)
+	fmt.Fprintf(&src, "const (\n")
+	for _, c := range goConsts(recs) {
+		fmt.Fprintf(&src, "\t%s = %s // U+%04X %s\n", c.ident, goRune(c.rec), c.rec.Char, c.rec.FullName())
+	}
+	fmt.Fprintf(&src, ")\n")
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+// Languages gen writes code for.
+const (
+	genGo         = "go"
+	genRangeTable = "rangetable"
+	genRegex      = "regex"
+	genCSS        = "css"
+)
+
+var generators = []string{genGo, genRangeTable, genRegex, genCSS}
+
+func genFlags(c *cli, flags *flag.FlagSet) {
+	flags.StringVar(&c.opts.pkg, "pkg", "chars", "declare the Go constants or table in package `NAME`")
+	flags.StringVar(&c.opts.varName, "var", "", "call the Go table `NAME` (default: from the query words)")
+	flags.StringVar(&c.opts.flavor, "flavor", flavorRE2, "write the regex class in the syntax of `FLAVOR`: "+
+		strings.Join(regexFlavors, ", "))
+}
+
+func runGen(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	language := flags.Arg(0)
+	known := false
+	for _, generator := range generators {
+		known = known || language == generator
+	}
+	if !known {
+		return &UsageError{cmd.name, "name a language: " + strings.Join(generators, ", ")}
+	}
+	if err := c.parse(flags, flags.Args()[1:]); err != nil { // flags after the language
+		return err
+	}
+	query := strings.Join(flags.Args(), " ")
+	words, _, queryErr := parseQuery(query)
+	if c.opts.varName == "" {
+		c.opts.varName = goIdent(words)
+	}
+	switch {
+	case queryErr != nil:
+		return &UsageError{cmd.name, queryErr.Error()}
+	case !token.IsIdentifier(c.opts.pkg):
+		return &UsageError{cmd.name, fmt.Sprintf("invalid package name %q", c.opts.pkg)}
+	case !token.IsIdentifier(c.opts.varName):
+		return &UsageError{cmd.name, fmt.Sprintf("invalid variable name %q", c.opts.varName)}
+	case strings.TrimSpace(query) == "":
+		return &UsageError{cmd.name, "missing query words"}
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	recs, err := c.searchRecords(ucd, query)
+	if err != nil {
+		return err
+	}
+	chars := make([]rune, len(recs))
+	for i, rec := range recs {
+		chars[i] = rec.Char
+	}
+	if len(recs) == 0 {
+		return errNoMatch
+	}
+	command := strings.Join(append([]string{"runescan", cmd.name}, args...), " ")
+	switch language {
+	case genRangeTable:
+		return WriteRangeTable(c.stdout, c.opts.pkg, c.opts.varName, command, chars)
+	case genRegex:
+		class, err := RegexClass(MergeRanges(chars), c.opts.flavor)
+		if err != nil {
+			return &UsageError{cmd.name, err.Error()}
+		}
+		fmt.Fprintln(c.stdout, class)
+		return nil
+	case genCSS:
+		fmt.Fprintln(c.stdout, CSSUnicodeRange(MergeRanges(chars)))
+		return nil
+	}
+	return WriteGo(c.stdout, c.opts.pkg, command, recs)
+}
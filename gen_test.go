@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGoIdent(t *testing.T) {
+	var testCases = []struct {
+		name string
+		want string
+	}{
+		{"LEFTWARDS ARROW", "LeftwardsArrow"},
+		{"SMILING CAT FACE WITH HEART-SHAPED EYES", "SmilingCatFaceWithHeartShapedEyes"},
+		{"CJK UNIFIED IDEOGRAPH-4E00", "CjkUnifiedIdeograph4E00"},
+		{"U0080", "U0080"},
+		{"2-EM DASH", "Char2EmDash"},
+	}
+	for _, tc := range testCases {
+		if got := goIdent(tc.name); got != tc.want {
+			t.Errorf("%q: want %q; got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestGoConsts(t *testing.T) {
+	recs := []Record{
+		{Char: 0x0000, Name: "<control>", OldName: "NULL"},
+		{Char: 0x0080, Name: "<control>"},
+		{Char: 0x0F60, Name: "TIBETAN LETTER -A"},
+		{Char: 0x0F68, Name: "TIBETAN LETTER A"},
+		{Char: 0x2190, Name: "LEFTWARDS ARROW"},
+	}
+	got := []string{}
+	for _, c := range goConsts(recs) {
+		got = append(got, c.ident)
+	}
+	want := []string{"Null", "U0080", "TibetanLetterAU0F60", "TibetanLetterAU0F68", "LeftwardsArrow"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\n\twant: %q\n\tgot:  %q", want, got)
+	}
+}
+
+func TestRun_gen(t *testing.T) {
+	status, output, stderr := runArgs("gen", "go", "-pkg", "quotes", "quote")
+	want := `// Code generated by "runescan gen go -pkg quotes quote"; DO NOT EDIT.
+
+package quotes
+
+// Characters found by "runescan gen go -pkg quotes quote".
+const (
+	Apostrophe                       = '\'' // U+0027 APOSTROPHE (APOSTROPHE-QUOTE)
+	AplFunctionalSymbolQuoteUnderbar = '⍘'  // U+2358 APL FUNCTIONAL SYMBOL QUOTE UNDERBAR
+	AplFunctionalSymbolQuoteQuad     = '⍞'  // U+235E APL FUNCTIONAL SYMBOL QUOTE QUAD
+)
+`
+	if status != exitMatch || output != want {
+		t.Errorf("\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q", exitMatch, want, status, output, stderr)
+	}
+
+	var outputCases = []struct {
+		args []string
+		want string
+	}{
+		{[]string{"gen", "regex", "quote"}, `[\x{27}\x{2358}\x{235E}]` + "\n"},
+		{[]string{"gen", "regex", "-flavor", "python", "quote"}, `[\u0027\u2358\u235E]` + "\n"},
+		{[]string{"gen", "css", "smiling"}, "unicode-range: U+263A, U+1F601, U+1F638, U+1F63A-1F63B, U+1F642;\n"},
+	}
+	for _, tc := range outputCases {
+		status, output, stderr := runArgs(tc.args...)
+		if status != exitMatch || output != tc.want {
+			t.Errorf("%q:\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q", tc.args, exitMatch, tc.want, status, output, stderr)
+		}
+	}
+
+	var usageCases = []struct {
+		args   []string
+		stderr string
+	}{
+		{[]string{"gen", "rust", "quote"}, "name a language: go"},
+		{[]string{"gen"}, "name a language: go"},
+		{[]string{"gen", "go", "-pkg", "my-chars", "quote"}, `invalid package name "my-chars"`},
+		{[]string{"gen", "go"}, "missing query words"},
+		{[]string{"gen", "rangetable", "-var", "my-table", "quote"}, `invalid variable name "my-table"`},
+		{[]string{"gen", "regex", "-flavor", "posix", "quote"}, `unknown regular expression flavor "posix"`},
+	}
+	for _, tc := range usageCases {
+		status, _, stderr := runArgs(tc.args...)
+		if status != exitUsage || !strings.Contains(stderr, tc.stderr) {
+			t.Errorf("%q:\n\twant: %d %q\n\tgot:  %d %q", tc.args, exitUsage, tc.stderr, status, stderr)
+		}
+	}
+	if status, _, _ := runArgs("gen", "go", "nothing"); status != exitNoMatch {
+		t.Errorf("no match: want status %d; got %d", exitNoMatch, status)
+	}
+}
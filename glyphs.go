@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// defaultGlyphSize is the size of rendered glyphs, in pixels per em,
+// unless told otherwise.
+const defaultGlyphSize = 64
+
+// maxGlyphSize is the largest size glyphs are drawn at.
+const maxGlyphSize = 1024
+
+// maxSheetPixels is the largest contact sheet render writes, in
+// pixels: 256 MiB of RGBA.
+const maxSheetPixels = 1 << 26
+
+// Layout of contact sheets, in pixels. Captions use a 7x13 bitmap
+// font, so a caption line of a cell holds captionChars characters.
+const (
+	sheetMargin  = 8
+	captionChars = 14
+	captionLine  = 13
+)
+
+// Colors of contact sheets.
+var (
+	sheetBackground = image.White
+	sheetGrid       = image.NewUniform(color.Gray{0xCC})
+	sheetCaption    = image.NewUniform(color.Gray{0x55})
+)
+
+// GlyphRenderer draws the glyphs of a font, at a size, as images of
+// single characters or contact sheets of many. It is safe for
+// concurrent use.
+type GlyphRenderer struct {
+	font   *Font
+	mu     sync.Mutex // guards face, which keeps buffers between calls
+	face   font.Face
+	width  int // of a glyph cell, in pixels
+	height int
+	ascent fixed.Int26_6
+}
+
+// NewGlyphRenderer returns a renderer for the glyphs of f, which is
+// rasterized at size pixels per em.
+func NewGlyphRenderer(f *Font, size int) (*GlyphRenderer, error) {
+	collection, err := opentype.ParseCollection(f.data)
+	if err != nil {
+		return nil, err
+	}
+	outlines, err := collection.Font(0)
+	if err != nil {
+		return nil, err
+	}
+	face, err := opentype.NewFace(outlines, &opentype.FaceOptions{
+		Size: float64(size), DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	metrics := face.Metrics()
+	height := max((metrics.Ascent + metrics.Descent).Ceil(), size)
+	return &GlyphRenderer{font: f, face: face, width: height, height: height, ascent: metrics.Ascent}, nil
+}
+
+// drawGlyph draws the glyph of char in black on dst, centered in the
+// cell at origin. It reports whether the font has a glyph for char.
+func (g *GlyphRenderer) drawGlyph(dst draw.Image, origin image.Point, char rune) bool {
+	if !g.font.Has(char) {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	advance, _ := g.face.GlyphAdvance(char)
+	drawer := &font.Drawer{Dst: dst, Src: image.Black, Face: g.face,
+		Dot: fixed.Point26_6{
+			X: fixed.I(origin.X) + (fixed.I(g.width)-advance)/2,
+			Y: fixed.I(origin.Y) + g.ascent,
+		}}
+	drawer.DrawString(string(char))
+	return true
+}
+
+// Glyph returns an image of the glyph of char on a transparent
+// background, or false if the font has no glyph for it.
+func (g *GlyphRenderer) Glyph(char rune) (*image.RGBA, bool) {
+	img := image.NewRGBA(image.Rect(0, 0, g.width, g.height))
+	if !g.drawGlyph(img, image.Point{}, char) {
+		return nil, false
+	}
+	return img, true
+}
+
+// caption returns s cut to fit a caption line, ending in "..." if
+// it was cut.
+func caption(s string) string {
+	if len(s) <= captionChars {
+		return s
+	}
+	return s[:captionChars-3] + "..."
+}
+
+// sheetLayout returns the number of columns and rows of a contact
+// sheet of n characters, columns to a row, and the size of its cells.
+func (g *GlyphRenderer) sheetLayout(n, columns int) (cols, rows int, cell image.Point) {
+	cols = max(min(columns, n), 1)
+	rows = (n + cols - 1) / cols
+	cell = image.Pt(max(g.width, captionChars*7)+2*sheetMargin, g.height+2*captionLine+2*sheetMargin)
+	return cols, rows, cell
+}
+
+// SheetPixels returns the number of pixels of the contact sheet
+// Sheet draws for n characters, columns to a row.
+func (g *GlyphRenderer) SheetPixels(n, columns int) int {
+	cols, rows, cell := g.sheetLayout(n, columns)
+	return (cols*cell.X + 1) * (rows*cell.Y + 1)
+}
+
+// Sheet returns a contact sheet of the glyphs of recs, columns to a
+// row, each captioned with its code point and name. Characters the
+// font has no glyph for get an empty cell. Its size grows with recs,
+// so callers check it with SheetPixels first.
+func (g *GlyphRenderer) Sheet(recs []Record, columns int) *image.RGBA {
+	columns, rows, cell := g.sheetLayout(len(recs), columns)
+	cellWidth, cellHeight := cell.X, cell.Y
+	sheet := image.NewRGBA(image.Rect(0, 0, columns*cellWidth+1, rows*cellHeight+1))
+	draw.Draw(sheet, sheet.Bounds(), sheetBackground, image.Point{}, draw.Src)
+	for x := 0; x <= columns; x++ {
+		draw.Draw(sheet, image.Rect(x*cellWidth, 0, x*cellWidth+1, rows*cellHeight+1), sheetGrid, image.Point{}, draw.Src)
+	}
+	for y := 0; y <= rows; y++ {
+		draw.Draw(sheet, image.Rect(0, y*cellHeight, columns*cellWidth+1, y*cellHeight+1), sheetGrid, image.Point{}, draw.Src)
+	}
+	for i, rec := range recs {
+		cell := image.Pt(i%columns*cellWidth, i/columns*cellHeight)
+		g.drawGlyph(sheet, cell.Add(image.Pt((cellWidth-g.width)/2, sheetMargin)), rec.Char)
+		captions := &font.Drawer{Dst: sheet, Src: sheetCaption, Face: basicfont.Face7x13}
+		for line, text := range []string{fmt.Sprintf("U+%04X", rec.Char), caption(rec.FullName())} {
+			captions.Dot = fixed.P(cell.X+(cellWidth-7*len(text))/2,
+				cell.Y+sheetMargin+g.height+(line+1)*captionLine)
+			captions.DrawString(text)
+		}
+	}
+	return sheet
+}
+
+// encodePNG returns img as a PNG file.
+func encodePNG(img image.Image) ([]byte, error) {
+	var b bytes.Buffer
+	err := png.Encode(&b, img)
+	return b.Bytes(), err
+}
+
+// checkGlyphSize returns a usage error if size, the value of -size,
+// is not a size glyphs are drawn at.
+func checkGlyphSize(cmd *command, size int) error {
+	if size < 1 || size > maxGlyphSize {
+		return &UsageError{cmd.name, fmt.Sprintf("invalid -size %d: use 1 to %d", size, maxGlyphSize)}
+	}
+	return nil
+}
+
+func renderFlags(c *cli, flags *flag.FlagSet) {
+	flags.StringVar(&c.opts.font, "font", "", "draw the glyphs of the TrueType or OpenType font in `FILE`")
+	flags.IntVar(&c.opts.size, "size", defaultGlyphSize, "draw glyphs `N` pixels per em")
+	flags.StringVar(&c.opts.dir, "o", ".", "write a PNG file per character, such as U+2190.png, to `DIR`")
+	flags.StringVar(&c.opts.sheet, "sheet", "", "write a contact sheet of all the characters to the PNG `FILE` instead")
+	flags.IntVar(&c.opts.columns, "columns", 8, "lay out contact sheets `N` characters to a row")
+}
+
+func runRender(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	query := strings.Join(flags.Args(), " ")
+	_, _, queryErr := parseQuery(query)
+	sizeErr := checkGlyphSize(cmd, c.opts.size)
+	switch {
+	case c.opts.font == "":
+		return &UsageError{cmd.name, "missing -font"}
+	case sizeErr != nil:
+		return sizeErr
+	case c.opts.columns < 1:
+		return &UsageError{cmd.name, fmt.Sprintf("invalid -columns %d", c.opts.columns)}
+	case strings.TrimSpace(query) == "":
+		return &UsageError{cmd.name, "missing query words"}
+	case queryErr != nil:
+		return &UsageError{cmd.name, queryErr.Error()}
+	}
+	f, err := c.loadFont(cmd, c.opts.font)
+	if err != nil {
+		return err
+	}
+	renderer, err := NewGlyphRenderer(f, c.opts.size)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.Name, err)
+	}
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return err
+	}
+	found, err := c.searchRecords(ucd, query)
+	if err != nil {
+		return err
+	}
+	recs, missing := []Record{}, 0
+	for _, rec := range found {
+		if !f.Has(rec.Char) {
+			missing++
+			continue
+		}
+		recs = append(recs, rec)
+	}
+	if missing > 0 {
+		fmt.Fprintf(c.stderr, "runescan: %s has no glyph for %d of the %d characters found\n",
+			f.Name, missing, missing+len(recs))
+	}
+	if len(recs) == 0 {
+		return errNoMatch
+	}
+	write := func(path string, img image.Image) error {
+		data, err := encodePNG(img)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout, path)
+		return nil
+	}
+	if c.opts.sheet != "" {
+		if renderer.SheetPixels(len(recs), c.opts.columns) > maxSheetPixels {
+			return &UsageError{cmd.name, fmt.Sprintf("a contact sheet of %d characters at -size %d is too large; "+
+				"narrow the query or use a smaller -size", len(recs), c.opts.size)}
+		}
+		return write(c.opts.sheet, renderer.Sheet(recs, c.opts.columns))
+	}
+	if err := os.MkdirAll(c.opts.dir, 0o755); err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		img, _ := renderer.Glyph(rec.Char)
+		if err := write(filepath.Join(c.opts.dir, fmt.Sprintf("U+%04X.png", rec.Char)), img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// inked returns the number of pixels of img that are not transparent
+// or white.
+func inked(img image.Image, r image.Rectangle) int {
+	n := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			red, green, blue, alpha := img.At(x, y).RGBA()
+			if alpha != 0 && (red != 0xFFFF || green != 0xFFFF || blue != 0xFFFF) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func goRegular(t *testing.T) *Font {
+	t.Helper()
+	f, err := ParseFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestGlyphRenderer(t *testing.T) {
+	g, err := NewGlyphRenderer(goRegular(t), 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, ok := g.Glyph('A')
+	if !ok || img.Bounds().Dx() < 32 || img.Bounds().Dx() != img.Bounds().Dy() {
+		t.Fatalf("A: want a square image of at least 32 pixels; got %v %v", ok, img.Bounds())
+	}
+	if n := inked(img, img.Bounds()); n == 0 {
+		t.Errorf("A: want glyph drawn; got blank image")
+	}
+	if _, ok := g.Glyph(0x1F600); ok {
+		t.Errorf("U+1F600: want no glyph")
+	}
+
+	recs := []Record{{Char: 'A', Name: "LATIN CAPITAL LETTER A"}, {Char: 'b', Name: "LATIN SMALL LETTER B"},
+		{Char: 0x1F600, Name: "GRINNING FACE"}}
+	sheet := g.Sheet(recs, 2)
+	if want, got := sheet.Bounds().Dx()*sheet.Bounds().Dy(), g.SheetPixels(len(recs), 2); got != want {
+		t.Errorf("SheetPixels: want %d; got %d", want, got)
+	}
+	cellWidth := max(g.width, captionChars*7) + 2*sheetMargin
+	cellHeight := g.height + 2*captionLine + 2*sheetMargin
+	if want := image.Rect(0, 0, 2*cellWidth+1, 2*cellHeight+1); sheet.Bounds() != want {
+		t.Fatalf("sheet: want bounds %v; got %v", want, sheet.Bounds())
+	}
+	glyphArea := func(i int) image.Rectangle {
+		cell := image.Pt(i%2*cellWidth, i/2*cellHeight)
+		return image.Rect(1, sheetMargin, cellWidth, sheetMargin+g.height).Add(cell)
+	}
+	for i, want := range []bool{true, true, false} {
+		if got := inked(sheet, glyphArea(i)) > 0; got != want {
+			t.Errorf("sheet cell %d: want glyph %v; got %v", i, want, got)
+		}
+	}
+}
+
+func TestCaption(t *testing.T) {
+	for s, want := range map[string]string{
+		"GRINNING FACE":          "GRINNING FACE",
+		"LATIN CAPITAL LETTER A": "LATIN CAPIT...",
+	} {
+		if got := caption(s); got != want {
+			t.Errorf("%q: want %q; got %q", s, want, got)
+		}
+	}
+}
+
+func TestRun_render(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "goregular.ttf")
+	if err := os.WriteFile(path, goregular.TTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	status, _, stderr := runArgs("render", "-font", path, "-size", "16", "-o", out, "cat")
+	if status != exitNoMatch || !strings.Contains(stderr, "no glyph for 7 of the 7 characters found") {
+		t.Errorf("cat: want status %d and a warning; got %d %q", exitNoMatch, status, stderr)
+	}
+
+	status, output, stderr := runArgs("render", "-font", path, "-size", "16", "-o", out, "quote")
+	want := filepath.Join(out, "U+0027.png") + "\n"
+	if status != exitMatch || output != want || !strings.Contains(stderr, "no glyph for 2 of the 3 characters found") {
+		t.Fatalf("quote:\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q", exitMatch, want, status, output, stderr)
+	}
+	file, err := os.Open(filepath.Join(out, "U+0027.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if img, err := png.Decode(file); err != nil || inked(img, img.Bounds()) == 0 {
+		t.Errorf("U+0027.png: want apostrophe drawn; got %v", err)
+	}
+
+	sheet := filepath.Join(dir, "sheet.png")
+	status, output, _ = runArgs("render", "-font", path, "-sheet", sheet, "quote")
+	if status != exitMatch || output != sheet+"\n" {
+		t.Errorf("sheet: want %d %q; got %d %q", exitMatch, sheet+"\n", status, output)
+	}
+
+	for _, args := range [][]string{
+		{"render", "quote"},
+		{"render", "-font", path},
+		{"render", "-font", path, "-size", "0", "quote"},
+		{"render", "-font", path, "-size", "1025", "quote"},
+		{"render", "-font", path + ".missing", "quote"},
+	} {
+		if status, _, _ := runArgs(args...); status != exitUsage {
+			t.Errorf("%q: want status %d; got %d", args, exitUsage, status)
+		}
+	}
+}
+
+func TestServer_glyph(t *testing.T) {
+	ucd, err := LoadUCD(strings.NewReader(ucdSample), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGlyphRenderer(goRegular(t), 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		glyphs *GlyphRenderer
+		path   string
+		status int
+	}{
+		{g, "/glyph/U+0041.png", http.StatusOK},
+		{g, "/glyph/U+1F600.png", http.StatusNotFound},
+		{g, "/glyph/U+0041.gif", http.StatusNotFound},
+		{g, "/glyph/0041.png", http.StatusNotFound},
+		{g, "/glyph/U+XYZ.png", http.StatusNotFound},
+		{nil, "/glyph/U+0041.png", http.StatusNotFound},
+	} {
+		srv := httptest.NewServer(newServer(ucd, tc.glyphs))
+		response, err := srv.Client().Get(srv.URL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		srv.Close()
+		if response.StatusCode != tc.status {
+			t.Errorf("%s: want status %d; got %d", tc.path, tc.status, response.StatusCode)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		if got := response.Header.Get("Content-Type"); got != "image/png" {
+			t.Errorf("%s: want image/png; got %q", tc.path, got)
+		}
+		if _, err := png.Decode(bytes.NewReader(body)); err != nil {
+			t.Errorf("%s: %v", tc.path, err)
+		}
+	}
+}
+
+func TestRun_renderSheetLimit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "goregular.ttf")
+	if err := os.WriteFile(path, goregular.TTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var ucd strings.Builder
+	for char := '!'; char <= '~'; char++ {
+		fmt.Fprintf(&ucd, "%04X;SAMPLE SIGN;So;0;ON;;;;;N;;;;;\n", char)
+	}
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: ucd.String()}
+	}
+	sheet := filepath.Join(dir, "sheet.png")
+	status, _, stderr := runArgs("render", "-font", path, "-size", "1024", "-sheet", sheet, "sample")
+	if status != exitUsage || !strings.Contains(stderr, "too large") {
+		t.Errorf("want status %d and a usage error; got %d %q", exitUsage, status, stderr)
+	}
+	if _, err := os.Stat(sheet); err == nil {
+		t.Errorf("want no sheet written")
+	}
+	if status, _, _ := runArgs("render", "-font", path, "-size", "16", "-sheet", sheet, "sample"); status != exitMatch {
+		t.Errorf("-size 16: want status %d; got %d", exitMatch, status)
+	}
+}
+
+func TestRun_serveSize(t *testing.T) {
+	for _, size := range []string{"0", "1025"} {
+		status, _, stderr := runArgs("serve", "-addr", "127.0.0.1:0", "-size", size)
+		if status != exitUsage || !strings.Contains(stderr, "invalid -size") {
+			t.Errorf("-size %s: want status %d; got %d %q", size, exitUsage, status, stderr)
+		}
+	}
+}
@@ -0,0 +1,591 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// lspCompletionLimit is the most characters a completion offers.
+// More make the list incomplete, so editors ask again as the user
+// types.
+const lspCompletionLimit = 100
+
+// JSON-RPC error codes.
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// rpcError is the error of a JSON-RPC response.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// rpcMessage is a JSON-RPC request or notification, which has no ID.
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// rpcResponse is the response to a request. Result is left out of
+// error responses only.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcNotification is a message from the server that needs no reply.
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// readLSPMessage reads the body of a message, which follows headers
+// ending with a blank line, among them Content-Length.
+func readLSPMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("Content-Length: %v", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+// writeLSPMessage writes v as JSON with its Content-Length header.
+func writeLSPMessage(w io.Writer, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+// lspPosition is a position in a document: a 0-based line, and a
+// column in UTF-16 code units, which is what LSP clients count.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange    `json:"range"`
+	Severity int         `json:"severity"`
+	Code     interface{} `json:"code"` // a string for ours, any for others
+	Source   string      `json:"source"`
+	Message  string      `json:"message"`
+}
+
+// Diagnostic severities.
+const (
+	lspError   = 1
+	lspWarning = 2
+)
+
+// utf16Len returns the length of s in UTF-16 code units.
+func utf16Len(s string) int {
+	n := 0
+	for _, char := range s {
+		n++
+		if char > 0xFFFF {
+			n++
+		}
+	}
+	return n
+}
+
+// positionAt returns the position of a byte offset in text.
+func positionAt(text string, offset int) lspPosition {
+	start := strings.LastIndexByte(text[:offset], '\n') + 1
+	return lspPosition{strings.Count(text[:offset], "\n"), utf16Len(text[start:offset])}
+}
+
+// offsetAt returns the byte offset in text of the character at pos,
+// or of the end of the line for positions past it.
+func offsetAt(text string, pos lspPosition) int {
+	start := 0
+	for line := 0; line < pos.Line; line++ {
+		next := strings.IndexByte(text[start:], '\n')
+		if next < 0 {
+			return len(text)
+		}
+		start += next + 1
+	}
+	end := strings.IndexByte(text[start:], '\n')
+	if end < 0 {
+		end = len(text) - start
+	}
+	units := 0
+	for i, char := range text[start : start+end] {
+		width := utf16Len(string(char))
+		if units+width > pos.Character {
+			return start + i
+		}
+		units += width
+	}
+	return start + end
+}
+
+// lspDocument is a document open in the editor.
+type lspDocument struct {
+	text     string
+	language string // LSP language identifier, such as "go"
+}
+
+// lspServer answers Language Server Protocol requests about the
+// characters in the documents an editor has open.
+type lspServer struct {
+	ucd      *UCD
+	docs     map[string]*lspDocument
+	out      io.Writer
+	shutdown bool
+}
+
+func newLSPServer(ucd *UCD, out io.Writer) *lspServer {
+	return &lspServer{ucd: ucd, docs: map[string]*lspDocument{}, out: out}
+}
+
+// serve handles the messages read from in until the client sends
+// the exit notification or closes in. Exiting without a shutdown
+// request first is an error, as the protocol says.
+func (s *lspServer) serve(in io.Reader) error {
+	r := bufio.NewReader(in)
+	for {
+		body, err := readLSPMessage(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.reply(json.RawMessage("null"), nil, &rpcError{rpcParseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		result, err := s.handle(msg.Method, msg.Params)
+		if len(msg.ID) == 0 { // a notification, which gets no reply
+			continue
+		}
+		var rpcErr *rpcError
+		if err != nil && !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{rpcInternalError, err.Error()}
+		}
+		if err := s.reply(msg.ID, result, rpcErr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *lspServer) reply(id json.RawMessage, result interface{}, rpcErr *rpcError) error {
+	response := rpcResponse{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		var err error
+		if response.Result, err = json.Marshal(result); err != nil {
+			return err
+		}
+	}
+	return writeLSPMessage(s.out, response)
+}
+
+func (s *lspServer) notify(method string, params interface{}) error {
+	return writeLSPMessage(s.out, rpcNotification{"2.0", method, params})
+}
+
+// lspParams holds the parameters of the requests and notifications
+// the server handles; each fills in those it has.
+type lspParams struct {
+	TextDocument struct {
+		URI        string `json:"uri"`
+		LanguageID string `json:"languageId"`
+		Text       string `json:"text"`
+	} `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+	Position lspPosition `json:"position"`
+	Range    lspRange    `json:"range"`
+	Context  struct {
+		Diagnostics []lspDiagnostic `json:"diagnostics"`
+	} `json:"context"`
+}
+
+// handle runs a method, returning its result.
+func (s *lspServer) handle(method string, raw json.RawMessage) (interface{}, error) {
+	var params lspParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+	uri := params.TextDocument.URI
+	switch method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1, // full text on every change
+				"completionProvider": map[string]interface{}{"triggerCharacters": []string{":", "{"}},
+				"hoverProvider":      true,
+				"codeActionProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "runescan", "version": version},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = &lspDocument{params.TextDocument.Text, params.TextDocument.LanguageID}
+		return nil, s.publishDiagnostics(uri)
+	case "textDocument/didChange":
+		doc, ok := s.docs[uri]
+		if n := len(params.ContentChanges); ok && n > 0 {
+			doc.text = params.ContentChanges[n-1].Text
+		}
+		return nil, s.publishDiagnostics(uri)
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		return nil, s.publishDiagnostics(uri)
+	}
+	doc, ok := s.docs[uri]
+	if !ok && strings.HasPrefix(method, "textDocument/") {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("document %s is not open", uri)}
+	}
+	switch method {
+	case "textDocument/completion":
+		return s.complete(doc, params.Position), nil
+	case "textDocument/hover":
+		return s.hover(doc, params.Position), nil
+	case "textDocument/codeAction":
+		return s.codeActions(uri, doc, params.Range, params.Context.Diagnostics), nil
+	}
+	return nil, &rpcError{rpcMethodNotFound, "unknown method " + method}
+}
+
+// diagnostics returns the bidi controls and invisible characters in
+// doc, as Analyze finds them, leaving out those that join emoji.
+func (s *lspServer) diagnostics(doc *lspDocument) []lspDiagnostic {
+	diagnostics := []lspDiagnostic{}
+	for _, f := range s.ucd.Analyze([]byte(doc.text)).Findings {
+		severity := lspWarning
+		switch {
+		case f.Kind == findBidi:
+			severity = lspError
+		case f.Kind == findInvisible && !s.inEmojiSequence(doc.text, f.Byte):
+		default:
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(doc.text[f.Byte:])
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    lspRange{positionAt(doc.text, f.Byte), positionAt(doc.text, f.Byte+size)},
+			Severity: severity,
+			Code:     f.Kind,
+			Source:   "runescan",
+			Message:  fmt.Sprintf("%s character %s %s", f.Kind, f.Code, f.Name),
+		})
+	}
+	return diagnostics
+}
+
+// inEmojiSequence reports whether the character at offset in text is
+// a zero width joiner between emoji, as in U+1F469 U+200D U+1F4BB
+// (woman technologist), or an emoji presentation selector following
+// one, as in U+2764 U+FE0F (red heart), where they are expected.
+func (s *lspServer) inEmojiSequence(text string, offset int) bool {
+	char, size := utf8.DecodeRuneInString(text[offset:])
+	before, n := utf8.DecodeLastRuneInString(text[:offset])
+	if before == 0xFE0F && char == 0x200D {
+		before, _ = utf8.DecodeLastRuneInString(text[:offset-n])
+	}
+	after, _ := utf8.DecodeRuneInString(text[offset+size:])
+	switch char {
+	case 0x200D:
+		return s.isEmoji(before) && s.isEmoji(after)
+	case 0xFE0F:
+		return s.isEmoji(before)
+	}
+	return false
+}
+
+// isEmoji reports whether char has the Emoji or Extended_Pictographic
+// property.
+func (s *lspServer) isEmoji(char rune) bool {
+	rec, ok := s.ucd.Lookup(char)
+	return ok && (rec.Emoji || rec.ExtendedPictographic)
+}
+
+// publishDiagnostics sends the diagnostics of the document at uri,
+// none if it was closed.
+func (s *lspServer) publishDiagnostics(uri string) error {
+	diagnostics := []lspDiagnostic{}
+	if doc, ok := s.docs[uri]; ok {
+		diagnostics = s.diagnostics(doc)
+	}
+	return s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri": uri, "diagnostics": diagnostics,
+	})
+}
+
+// completionQuery matches what completion is offered for at the end
+// of the text before the cursor: a Python-style \N{NAME escape, or
+// search words after a colon at the start of a word, as in ":cat smi".
+var completionQuery = regexp.MustCompile(`(\\N\{[^}]*|(?:^|\s):[A-Za-z][A-Za-z0-9 -]*)$`)
+
+type lspCompletionItem struct {
+	Label      string      `json:"label"`
+	Detail     string      `json:"detail"`
+	FilterText string      `json:"filterText"`
+	SortText   string      `json:"sortText"`
+	TextEdit   lspTextEdit `json:"textEdit"`
+}
+
+// complete returns the characters whose names match the query before
+// pos: all its words, the last of which may be the start of a word,
+// unless it is followed by a space. After a colon they replace the
+// query; in a \N{ escape their name completes it.
+func (s *lspServer) complete(doc *lspDocument, pos lspPosition) interface{} {
+	items := []lspCompletionItem{}
+	result := map[string]interface{}{"isIncomplete": false, "items": items}
+	cursor := offsetAt(doc.text, pos)
+	lineStart := strings.LastIndexByte(doc.text[:cursor], '\n') + 1
+	match := completionQuery.FindStringIndex(doc.text[lineStart:cursor])
+	if match == nil {
+		return result
+	}
+	start, typed := lineStart+match[0], doc.text[lineStart+match[0]:cursor]
+	escape := strings.HasPrefix(typed, `\N{`)
+	if !escape {
+		start += strings.IndexByte(typed, ':')
+		typed = doc.text[start:cursor]
+	}
+	query := strings.TrimPrefix(strings.TrimPrefix(typed, `\N{`), ":")
+	words := strings.Fields(strings.Replace(query, "-", " ", -1))
+	if len(strings.TrimSpace(query)) < 2 || len(words) == 0 {
+		return result
+	}
+	prefix := ""
+	if !strings.HasSuffix(query, " ") {
+		prefix, words = strings.ToUpper(words[len(words)-1]), words[:len(words)-1]
+	}
+	end := cursor
+	if escape && strings.HasPrefix(doc.text[cursor:], "}") {
+		end++
+	}
+	editRange := lspRange{positionAt(doc.text, start), positionAt(doc.text, end)}
+	for _, fields := range s.ucd.Filter(strings.Join(words, " ")) {
+		if !hasWordPrefix(fields[2], prefix) {
+			continue
+		}
+		newText := fields[1]
+		if escape {
+			char, size := utf8.DecodeRuneInString(fields[1])
+			rec, ok := s.ucd.Lookup(char)
+			if size != len(fields[1]) || !ok || rec.FullName() != fields[2] || strings.HasPrefix(rec.Name, "<") {
+				continue // aliases and characters without names have no escape
+			}
+			newText = `\N{` + rec.Name + `}`
+		}
+		if len(items) == lspCompletionLimit {
+			result["isIncomplete"] = true
+			break
+		}
+		items = append(items, lspCompletionItem{
+			Label:      fields[1] + " " + fields[2],
+			Detail:     fields[0],
+			FilterText: typed,
+			SortText:   fmt.Sprintf("%04d", len(items)),
+			TextEdit:   lspTextEdit{editRange, newText},
+		})
+	}
+	result["items"] = items
+	return result
+}
+
+// hasWordPrefix reports whether a word of name starts with prefix,
+// which is upper case.
+func hasWordPrefix(name, prefix string) bool {
+	for _, word := range strings.Fields(strings.Replace(strings.ToUpper(name), "-", " ", -1)) {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hover describes the non-ASCII character at pos, if any.
+func (s *lspServer) hover(doc *lspDocument, pos lspPosition) interface{} {
+	offset := offsetAt(doc.text, pos)
+	if offset == len(doc.text) {
+		return nil
+	}
+	char, size := utf8.DecodeRuneInString(doc.text[offset:])
+	if char < utf8.RuneSelf || (char == utf8.RuneError && size == 1) {
+		return nil
+	}
+	category := "Cn"
+	if rec, ok := s.ucd.Lookup(char); ok {
+		category = rec.Category
+	}
+	text := fmt.Sprintf("**U+%04X** %s\n\ncategory: %s  \nblock: %s",
+		char, s.ucd.Name(char), category, s.ucd.Block(char))
+	return map[string]interface{}{
+		"contents": map[string]string{"kind": "markdown", "value": text},
+		"range":    lspRange{positionAt(doc.text, offset), positionAt(doc.text, offset+size)},
+	}
+}
+
+type lspCodeAction struct {
+	Title       string          `json:"title"`
+	Kind        string          `json:"kind"`
+	Diagnostics []lspDiagnostic `json:"diagnostics,omitempty"`
+	Edit        struct {
+		Changes map[string][]lspTextEdit `json:"changes"`
+	} `json:"edit"`
+}
+
+// codeActions offers to replace each non-ASCII character in rng, or
+// at its start if it is empty, with its escape in the language of
+// doc. Replacing a character diagnostics report is a quick fix.
+func (s *lspServer) codeActions(uri string, doc *lspDocument, rng lspRange, diagnostics []lspDiagnostic) []lspCodeAction {
+	actions := []lspCodeAction{}
+	start, end := offsetAt(doc.text, rng.Start), offsetAt(doc.text, rng.End)
+	if end <= start {
+		_, size := utf8.DecodeRuneInString(doc.text[start:])
+		end = start + size
+	}
+	for offset, char := range doc.text[start:end] {
+		offset += start
+		if char < utf8.RuneSelf || char == utf8.RuneError {
+			continue
+		}
+		charRange := lspRange{positionAt(doc.text, offset), positionAt(doc.text, offset+utf8.RuneLen(char))}
+		escape := escapeFor(doc.language, char)
+		action := lspCodeAction{
+			Title: fmt.Sprintf("Replace U+%04X %s with %s", char, s.ucd.Name(char), escape),
+			Kind:  "refactor.rewrite",
+		}
+		for _, d := range diagnostics {
+			if d.Range == charRange {
+				action.Kind = "quickfix"
+				action.Diagnostics = append(action.Diagnostics, d)
+			}
+		}
+		action.Edit.Changes = map[string][]lspTextEdit{uri: {{charRange, escape}}}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// escapeFor returns the escape of char in source code of language,
+// an LSP language identifier. Languages not listed get the \u and \U
+// escapes of Go, Python and C.
+func escapeFor(language string, char rune) string {
+	switch language {
+	case "javascript", "javascriptreact", "typescript", "typescriptreact", "rust", "swift", "ruby", "php":
+		return fmt.Sprintf(`\u{%X}`, char)
+	case "java", "csharp", "json", "jsonc":
+		if char > 0xFFFF {
+			high, low := 0xD800+(char-0x10000)>>10, 0xDC00+(char-0x10000)&0x3FF
+			return fmt.Sprintf(`\u%04X\u%04X`, high, low)
+		}
+	case "html", "xml":
+		return fmt.Sprintf("&#x%X;", char)
+	case "css", "scss", "less":
+		return fmt.Sprintf(`\%X `, char)
+	}
+	if char > 0xFFFF {
+		return fmt.Sprintf(`\U%08X`, char)
+	}
+	return fmt.Sprintf(`\u%04X`, char)
+}
+
+// loadEmojiData adds the emoji properties to ucd, unless it has them
+// already.
+func (c *cli) loadEmojiData(ucd *UCD) error {
+	if ucd.has(func(rec Record) bool { return rec.Emoji }) {
+		return nil
+	}
+	file, err := c.openFile(EmojiDataFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := ucd.ReadEmojiData(file); err != nil {
+		return fmt.Errorf("%s: %w", EmojiDataFile, err)
+	}
+	return nil
+}
+
+func runLSP(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return &UsageError{cmd.name, "the protocol is spoken on standard input and output"}
+	}
+	c.quiet = true // stderr may be the editor's log, not a terminal
+	ucd, err := c.loadSearchUCD()
+	if err != nil {
+		return err
+	}
+	if err := c.loadProperties(ucd); err != nil {
+		fmt.Fprintf(c.stderr, "runescan: %v; hovers will not show blocks\n", err)
+	}
+	if err := c.loadEmojiData(ucd); err != nil {
+		fmt.Fprintf(c.stderr, "runescan: %v; joiners in emoji sequences will be reported\n", err)
+	}
+	return newLSPServer(ucd, c.stdout).serve(c.stdin)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPositions(t *testing.T) {
+	text := "a\u00e9\U0001F680b\nxy"
+	var testCases = []struct {
+		offset int
+		pos    lspPosition
+	}{
+		{0, lspPosition{0, 0}},
+		{1, lspPosition{0, 1}},
+		{3, lspPosition{0, 2}},
+		{7, lspPosition{0, 4}},
+		{9, lspPosition{1, 0}},
+		{10, lspPosition{1, 1}},
+	}
+	for _, tc := range testCases {
+		if got := positionAt(text, tc.offset); got != tc.pos {
+			t.Errorf("positionAt %d: want %v; got %v", tc.offset, tc.pos, got)
+		}
+		if got := offsetAt(text, tc.pos); got != tc.offset {
+			t.Errorf("offsetAt %v: want %d; got %d", tc.pos, tc.offset, got)
+		}
+	}
+	// Inside a surrogate pair, past the end of a line, past the end.
+	for pos, want := range map[lspPosition]int{{0, 3}: 3, {0, 9}: 8, {5, 0}: len(text)} {
+		if got := offsetAt(text, pos); got != want {
+			t.Errorf("offsetAt %v: want %d; got %d", pos, want, got)
+		}
+	}
+}
+
+func TestEscapeFor(t *testing.T) {
+	var testCases = []struct {
+		language string
+		char     rune
+		want     string
+	}{
+		{"go", 0x202E, `\u202E`},
+		{"python", 0x1F680, `\U0001F680`},
+		{"rust", 0x202E, `\u{202E}`},
+		{"javascript", 0x1F680, `\u{1F680}`},
+		{"java", 0x1F680, `\uD83D\uDE80`},
+		{"json", 0x200B, `\u200B`},
+		{"html", 0x00A0, "&#xA0;"},
+		{"css", 0x00A0, `\A0 `},
+		{"", 0x00E9, `\u00E9`},
+	}
+	for _, tc := range testCases {
+		if got := escapeFor(tc.language, tc.char); got != tc.want {
+			t.Errorf("%s U+%04X: want %s; got %s", tc.language, tc.char, tc.want, got)
+		}
+	}
+}
+
+// lspRequest returns a message with its header; id 0 makes it a
+// notification.
+func lspRequest(id int, method string, params string) string {
+	body := fmt.Sprintf(`{"jsonrpc":"2.0","method":%q,"params":%s}`, method, params)
+	if id != 0 {
+		body = fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":%s}`, id, method, params)
+	}
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func TestRun_lsp(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: analyzeUCD, BlocksFile: blocksSample, ScriptsFile: scriptsSample}
+	}
+	doc := `{"uri":"file:///a.py"}`
+	text := "s = '\u00e9\u202e'\\n:latin small le\\nt = '\\\\N{LATIN SMALL LETTER E}'\\n"
+	diagnostic := `{"range":{"start":{"line":0,"character":6},"end":{"line":0,"character":7}},` +
+		`"severity":1,"code":"bidi-control","source":"runescan",` +
+		`"message":"bidi-control character U+202E RIGHT-TO-LEFT OVERRIDE"}`
+	input := lspRequest(1, "initialize", `{"capabilities":{}}`) +
+		lspRequest(0, "initialized", `{}`) +
+		lspRequest(0, "textDocument/didOpen",
+			`{"textDocument":{"uri":"file:///a.py","languageId":"python","version":1,"text":"`+text+`"}}`) +
+		lspRequest(2, "textDocument/hover", `{"textDocument":`+doc+`,"position":{"line":0,"character":5}}`) +
+		lspRequest(3, "textDocument/hover", `{"textDocument":`+doc+`,"position":{"line":0,"character":0}}`) +
+		lspRequest(4, "textDocument/completion", `{"textDocument":`+doc+`,"position":{"line":1,"character":15}}`) +
+		lspRequest(5, "textDocument/completion", `{"textDocument":`+doc+`,"position":{"line":2,"character":28}}`) +
+		lspRequest(6, "textDocument/codeAction", `{"textDocument":`+doc+
+			`,"range":{"start":{"line":0,"character":6},"end":{"line":0,"character":6}},"context":{"diagnostics":[`+diagnostic+`]}}`) +
+		lspRequest(7, "textDocument/rename", `{"textDocument":`+doc+`}`) +
+		lspRequest(0, "textDocument/didClose", `{"textDocument":`+doc+`}`) +
+		lspRequest(8, "shutdown", `null`) +
+		lspRequest(0, "exit", `null`)
+	var stdout, stderr strings.Builder
+	status := run([]string{"lsp"}, strings.NewReader(input), &stdout, &stderr)
+	if status != exitMatch {
+		t.Fatalf("want status %d; got %d %q", exitMatch, status, stderr.String())
+	}
+	got := []string{}
+	r := bufio.NewReader(strings.NewReader(stdout.String()))
+	for {
+		body, err := readLSPMessage(r)
+		if err != nil {
+			break
+		}
+		got = append(got, string(body))
+	}
+	names := map[rune]string{'a': "LATIN SMALL LETTER A", 'b': "LATIN SMALL LETTER B",
+		0xE9: "LATIN SMALL LETTER E WITH ACUTE (LATIN SMALL LETTER E ACUTE)"}
+	item := func(n int, char rune, filterText string, line, start, end int, newText string) string {
+		return fmt.Sprintf(`{"label":"%c %s","detail":"U+%04X","filterText":%q,"sortText":"%04d",`+
+			`"textEdit":{"range":{"start":{"line":%d,"character":%d},"end":{"line":%d,"character":%d}},"newText":%q}}`,
+			char, names[char], char, filterText, n, line, start, line, end, newText)
+	}
+	want := []string{
+		`{"jsonrpc":"2.0","id":1,"result":{"capabilities":{"codeActionProvider":true,` +
+			`"completionProvider":{"triggerCharacters":[":","{"]},"hoverProvider":true,"textDocumentSync":1},` +
+			`"serverInfo":{"name":"runescan","version":"devel"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"diagnostics":[` + diagnostic +
+			`],"uri":"file:///a.py"}}`,
+		`{"jsonrpc":"2.0","id":2,"result":{"contents":{"kind":"markdown","value":"**U+00E9** ` +
+			`LATIN SMALL LETTER E WITH ACUTE (LATIN SMALL LETTER E ACUTE)\n\ncategory: Ll  \nblock: Latin-1 Supplement"},` +
+			`"range":{"start":{"line":0,"character":5},"end":{"line":0,"character":6}}}}`,
+		`{"jsonrpc":"2.0","id":3,"result":null}`,
+		`{"jsonrpc":"2.0","id":4,"result":{"isIncomplete":false,"items":[` +
+			item(0, 'a', ":latin small le", 1, 0, 15, "a") + "," +
+			item(1, 'b', ":latin small le", 1, 0, 15, "b") + "," +
+			item(2, 0xE9, ":latin small le", 1, 0, 15, "\u00e9") + `]}}`,
+		`{"jsonrpc":"2.0","id":5,"result":{"isIncomplete":false,"items":[` +
+			item(0, 0xE9, `\N{LATIN SMALL LETTER E`, 2, 5, 29, `\N{LATIN SMALL LETTER E WITH ACUTE}`) + `]}}`,
+		`{"jsonrpc":"2.0","id":6,"result":[{"title":"Replace U+202E RIGHT-TO-LEFT OVERRIDE with \\u202E",` +
+			`"kind":"quickfix","diagnostics":[` + diagnostic + `],"edit":{"changes":{"file:///a.py":[` +
+			`{"range":{"start":{"line":0,"character":6},"end":{"line":0,"character":7}},"newText":"\\u202E"}]}}}]}`,
+		`{"jsonrpc":"2.0","id":7,"error":{"code":-32601,"message":"unknown method textDocument/rename"}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"diagnostics":[],"uri":"file:///a.py"}}`,
+		`{"jsonrpc":"2.0","id":8,"result":null}`,
+	}
+	for i := range want {
+		var wantValue, gotValue interface{}
+		if err := json.Unmarshal([]byte(want[i]), &wantValue); err != nil {
+			t.Fatalf("message %d: %v: %s", i, err, want[i])
+		}
+		if i < len(got) {
+			json.Unmarshal([]byte(got[i]), &gotValue)
+		}
+		if !reflect.DeepEqual(gotValue, wantValue) {
+			t.Errorf("message %d:\n\twant: %s\n\tgot:  %v", i, want[i], got[i:min(i+1, len(got))])
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("want %d messages; got %d", len(want), len(got))
+	}
+
+	status = run([]string{"lsp"}, strings.NewReader(lspRequest(0, "exit", `null`)), &stdout, &stderr)
+	if status == exitMatch {
+		t.Errorf("exit without shutdown: want failure; got status %d", status)
+	}
+}
+
+func TestLSPServer_diagnostics_emoji(t *testing.T) {
+	ucd, err := LoadUCD(strings.NewReader(analyzeUCD+`200D;ZERO WIDTH JOINER;Cf;0;BN;;;;;N;;;;;
+2764;HEAVY BLACK HEART;So;0;ON;;;;;N;;;;;
+FE0F;VARIATION SELECTOR-16;Mn;0;NSM;;;;;N;;;;;
+1F469;WOMAN;So;0;ON;;;;;N;;;;;
+1F4BB;PERSONAL COMPUTER;So;0;ON;;;;;N;;;;;
+1F525;FIRE;So;0;ON;;;;;N;;;;;
+`), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ucd.ReadEmojiData(strings.NewReader("2764 ; Emoji\n1F469 ; Emoji\n1F4BB ; Emoji\n1F525 ; Emoji\n")); err != nil {
+		t.Fatal(err)
+	}
+	s := newLSPServer(ucd, io.Discard)
+	for text, want := range map[string][]string{
+		"\U0001F469\u200d\U0001F4BB":       {},
+		"\u2764\ufe0f\u200d\U0001F525":     {},
+		"a\u200db \U0001F469\u200d":        {"U+200D", "U+200D"},
+		"\u200b\U0001F469\u200b\U0001F4BB": {"U+200B", "U+200B"},
+	} {
+		got := []string{}
+		for _, d := range s.diagnostics(&lspDocument{text: text}) {
+			got = append(got, strings.Fields(d.Message)[2])
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%+q: want diagnostics for %v; got %v", text, want, got)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Data files for normalization.
+const (
+	CompositionExclusionsFile = "CompositionExclusions.txt"
+	NormalizationTestFile     = "NormalizationTest.txt"
+)
+
+// NFD returns s in Normalization Form D: every character replaced by
+// its full canonical decomposition, from field 5 of UnicodeData.txt,
+// and runs of combining marks put in canonical order.
+func (u *UCD) NFD(s string) string {
+	return u.decomposeString(s, false)
+}
+
+// NFKD returns s in Normalization Form KD, which is NFD using the
+// compatibility decompositions as well, such as "<compat> 0020 0301".
+func (u *UCD) NFKD(s string) string {
+	return u.decomposeString(s, true)
+}
+
+func (u *UCD) decomposeString(s string, compat bool) string {
+	chars := []rune{}
+	for _, char := range s {
+		chars = u.decompose(chars, char, compat)
+	}
+	u.reorder(chars)
+	return string(chars)
+}
+
+// decompose appends the full decomposition of char to chars. Hangul
+// syllables are decomposed algorithmically.
+func (u *UCD) decompose(chars []rune, char rune, compat bool) []rune {
+	if s := char - hangulBase; 0 <= s && s < hangulCount {
+		chars = append(chars, jamoLBase+s/jamoNCount, jamoVBase+(s%jamoNCount)/jamoTCount)
+		if t := s % jamoTCount; t > 0 {
+			chars = append(chars, jamoTBase+t)
+		}
+		return chars
+	}
+	mapping := u.decompositionMapping(char, compat)
+	if mapping == nil {
+		return append(chars, char)
+	}
+	for _, part := range mapping {
+		chars = u.decompose(chars, part, compat)
+	}
+	return chars
+}
+
+// decompositionMapping returns the decomposition mapping of char, or
+// nil if it has none. Compatibility mappings, which start with a tag
+// such as "<compat>", are left out unless compat is set.
+func (u *UCD) decompositionMapping(char rune, compat bool) []rune {
+	rec, ok := u.Lookup(char)
+	if !ok || rec.Decomposition == "" {
+		return nil
+	}
+	fields := strings.Fields(rec.Decomposition)
+	if strings.HasPrefix(fields[0], "<") {
+		if !compat {
+			return nil
+		}
+		fields = fields[1:]
+	}
+	mapping := []rune{}
+	for _, field := range fields {
+		code, err := strconv.ParseInt(field, 16, 32)
+		if err != nil {
+			return nil
+		}
+		mapping = append(mapping, rune(code))
+	}
+	return mapping
+}
+
+// combiningClass returns the Canonical_Combining_Class of char.
+func (u *UCD) combiningClass(char rune) int {
+	rec, _ := u.Lookup(char)
+	return rec.CombiningClass
+}
+
+// reorder sorts each run of combining marks in chars by combining
+// class, keeping marks of the same class in order.
+func (u *UCD) reorder(chars []rune) {
+	for start := 0; start < len(chars); {
+		if u.combiningClass(chars[start]) == 0 {
+			start++
+			continue
+		}
+		end := start + 1
+		for end < len(chars) && u.combiningClass(chars[end]) != 0 {
+			end++
+		}
+		run := chars[start:end]
+		sort.SliceStable(run, func(i, j int) bool {
+			return u.combiningClass(run[i]) < u.combiningClass(run[j])
+		})
+		start = end
+	}
+}
+
+// Normalizer converts text to any of the four Unicode normalization
+// forms. The composed forms need the pairs of characters canonical
+// composition joins, which it builds from the UCD.
+type Normalizer struct {
+	ucd          *UCD
+	compositions map[[2]rune]rune
+}
+
+// NewNormalizer returns a Normalizer for the characters in u, reading
+// the characters composition must not produce from exclusions, in
+// the format of CompositionExclusions.txt. Characters with singleton
+// decompositions or decompositions starting with a combining mark
+// are excluded as well, as UAX #15 requires.
+func NewNormalizer(u *UCD, exclusions io.Reader) (*Normalizer, error) {
+	excluded := map[rune]bool{}
+	err := ScanProperties(exclusions, func(first, last rune, _ []string) error {
+		for char := first; char <= last; char++ {
+			excluded[char] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	n := &Normalizer{ucd: u, compositions: map[[2]rune]rune{}}
+	for _, rec := range u.Records {
+		mapping := u.decompositionMapping(rec.Char, false)
+		if len(mapping) != 2 || excluded[rec.Char] ||
+			rec.CombiningClass != 0 || u.combiningClass(mapping[0]) != 0 {
+			continue
+		}
+		n.compositions[[2]rune{mapping[0], mapping[1]}] = rec.Char
+	}
+	return n, nil
+}
+
+// NFD returns s in Normalization Form D.
+func (n *Normalizer) NFD(s string) string { return n.ucd.NFD(s) }
+
+// NFKD returns s in Normalization Form KD.
+func (n *Normalizer) NFKD(s string) string { return n.ucd.NFKD(s) }
+
+// NFC returns s in Normalization Form C: canonically decomposed and
+// then recomposed.
+func (n *Normalizer) NFC(s string) string { return n.compose(n.ucd.NFD(s)) }
+
+// NFKC returns s in Normalization Form KC: decomposed with the
+// compatibility mappings and then canonically recomposed.
+func (n *Normalizer) NFKC(s string) string { return n.compose(n.ucd.NFKD(s)) }
+
+// Normalize returns s in form, one of "NFC", "NFD", "NFKC" or "NFKD",
+// in any case.
+func (n *Normalizer) Normalize(form, s string) (string, error) {
+	switch strings.ToUpper(form) {
+	case "NFC":
+		return n.NFC(s), nil
+	case "NFD":
+		return n.NFD(s), nil
+	case "NFKC":
+		return n.NFKC(s), nil
+	case "NFKD":
+		return n.NFKD(s), nil
+	}
+	return "", fmt.Errorf("unknown normalization form %q", form)
+}
+
+// compose applies the canonical composition algorithm to decomposed
+// text: each character not blocked from the last starter is joined
+// with it when they have a primary composite.
+func (n *Normalizer) compose(s string) string {
+	result := []rune{}
+	starter, lastClass := -1, 0
+	for _, char := range s {
+		class := n.ucd.combiningClass(char)
+		if starter >= 0 {
+			adjacent := starter == len(result)-1
+			if adjacent || (lastClass != 0 && lastClass < class) {
+				if composite, ok := n.composite(result[starter], char); ok {
+					result[starter] = composite
+					continue
+				}
+			}
+		}
+		if class == 0 {
+			starter = len(result)
+		}
+		result = append(result, char)
+		lastClass = class
+	}
+	return string(result)
+}
+
+// composite returns the primary composite of a starter and the
+// character that follows it, if there is one. Hangul syllables are
+// composed algorithmically.
+func (n *Normalizer) composite(starter, char rune) (rune, bool) {
+	if l, v := starter-jamoLBase, char-jamoVBase; 0 <= l && l < jamoLCount && 0 <= v && v < jamoVCount {
+		return hangulBase + (l*jamoVCount+v)*jamoTCount, true
+	}
+	if s, t := starter-hangulBase, char-jamoTBase; 0 <= s && s < hangulCount && s%jamoTCount == 0 &&
+		0 < t && t < jamoTCount {
+		return starter + t, true
+	}
+	composite, ok := n.compositions[[2]rune{starter, char}]
+	return composite, ok
+}
+
+// loadNormalizer builds a Normalizer from the configured UCD file
+// and CompositionExclusions.txt.
+func (c *cli) loadNormalizer() (*Normalizer, error) {
+	ucd, err := c.loadUCD()
+	if err != nil {
+		return nil, err
+	}
+	exclusions, err := c.openFile(CompositionExclusionsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer exclusions.Close()
+	return NewNormalizer(ucd, exclusions)
+}
+
+func normalizeFlags(c *cli, flags *flag.FlagSet) {
+	flags.StringVar(&c.opts.form, "form", "NFC", "normalization `FORM`: NFC, NFD, NFKC or NFKD")
+	flags.BoolVar(&c.opts.list, "list", false, "list the characters of the result instead of printing it")
+}
+
+func runNormalize(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	c.opts.form = strings.ToUpper(c.opts.form)
+	composed := c.opts.form == "NFC" || c.opts.form == "NFKC"
+	if !composed && c.opts.form != "NFD" && c.opts.form != "NFKD" {
+		return &UsageError{cmd.name, fmt.Sprintf("unknown normalization form %q", c.opts.form)}
+	}
+	text := strings.Join(flags.Args(), " ") + "\n"
+	if flags.NArg() == 0 {
+		input, err := io.ReadAll(c.stdin)
+		if err != nil {
+			return err
+		}
+		text = string(input)
+	}
+	// Only composition needs CompositionExclusions.txt.
+	n := &Normalizer{}
+	var err error
+	if composed {
+		n, err = c.loadNormalizer()
+	} else {
+		n.ucd, err = c.loadUCD()
+	}
+	if err != nil {
+		return err
+	}
+	result, _ := n.Normalize(c.opts.form, text)
+	if !c.opts.list {
+		fmt.Fprint(c.stdout, result)
+		return nil
+	}
+	results := [][3]string{}
+	for _, char := range strings.TrimSuffix(result, "\n") {
+		results = append(results, sequenceFields(n.ucd, string(char)))
+	}
+	return c.output(results, n.ucd)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// normalizeUCD holds the UnicodeData.txt lines the normalization
+// tests need.
+const normalizeUCD = confusablesUCD + `0020;SPACE;Zs;0;WS;;;;;N;;;;;
+0041;LATIN CAPITAL LETTER A;Lu;0;L;;;;;N;;;;0061;
+0066;LATIN SMALL LETTER F;Ll;0;L;;;;;N;;;0046;;0046
+0069;LATIN SMALL LETTER I;Ll;0;L;;;;;N;;;0049;;0049
+00B4;ACUTE ACCENT;Sk;0;ON;<compat> 0020 0301;;;;N;SPACING ACUTE;;;;
+00C5;LATIN CAPITAL LETTER A WITH RING ABOVE;Lu;0;L;0041 030A;;;;N;LATIN CAPITAL LETTER A RING;;;00E5;
+0302;COMBINING CIRCUMFLEX ACCENT;Mn;230;NSM;;;;;N;NON-SPACING CIRCUMFLEX;;;;
+030A;COMBINING RING ABOVE;Mn;230;NSM;;;;;N;NON-SPACING RING ABOVE;;;;
+0915;DEVANAGARI LETTER KA;Lo;0;L;;;;;N;;;;;
+093C;DEVANAGARI SIGN NUKTA;Mn;7;NSM;;;;;N;;;;;
+0958;DEVANAGARI LETTER QA;Lo;0;L;0915 093C;;;;N;;;;;
+1EB9;LATIN SMALL LETTER E WITH DOT BELOW;Ll;0;L;0065 0323;;;;N;;;1EB8;;1EB8
+1EC7;LATIN SMALL LETTER E WITH CIRCUMFLEX AND DOT BELOW;Ll;0;L;1EB9 0302;;;;N;;;1EC6;;1EC6
+212B;ANGSTROM SIGN;Lu;0;L;00C5;;;;N;ANGSTROM UNIT;;;00E5;
+AC00;<Hangul Syllable, First>;Lo;0;L;;;;;N;;;;;
+D7A3;<Hangul Syllable, Last>;Lo;0;L;;;;;N;;;;;
+FB01;LATIN SMALL LIGATURE FI;Ll;0;L;<compat> 0066 0069;;;;N;;;;;
+`
+
+// exclusionsSample holds lines of CompositionExclusions.txt.
+const exclusionsSample = `# CompositionExclusions.txt
+
+# (1) Script Specifics
+0958    #  DEVANAGARI LETTER QA
+`
+
+// normalizationSample holds lines in the format of
+// NormalizationTest.txt for the characters in normalizeUCD.
+const normalizationSample = `@Part0 # Specific cases
+00E9;00E9;0065 0301;00E9;0065 0301; # LATIN SMALL LETTER E WITH ACUTE
+0065 0323 0302;1EC7;0065 0323 0302;1EC7;0065 0323 0302; # canonical composition in two steps
+0065 0302 0323;1EC7;0065 0323 0302;1EC7;0065 0323 0302; # canonical ordering
+0958;0915 093C;0915 093C;0915 093C;0915 093C; # composition exclusion
+212B;00C5;0041 030A;00C5;0041 030A; # singleton decomposition
+FB01;FB01;FB01;0066 0069;0066 0069; # compatibility decomposition
+00B4;00B4;00B4;0020 0301;0020 0301; # compatibility decomposition to a combining mark
+AC01;AC01;1100 1161 11A8;AC01;1100 1161 11A8; # Hangul syllable
+1100 1161 11A8;AC01;1100 1161 11A8;AC01;1100 1161 11A8; # Hangul jamo
+0065 0301 0301;00E9 0301;0065 0301 0301;00E9 0301;0065 0301 0301; # blocked mark
+`
+
+func loadNormalizerSample(t *testing.T) *Normalizer {
+	t.Helper()
+	ucd, err := LoadUCD(strings.NewReader(normalizeUCD), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := NewNormalizer(ucd, strings.NewReader(exclusionsSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestUCD_NFD(t *testing.T) {
+	n := loadNormalizerSample(t)
+	var testCases = []struct {
+		text string
+		want string
+	}{
+		{"abc", "abc"},
+		{"\u00e9", "e\u0301"},
+		{"e\u0301\u0323", "e\u0323\u0301"},
+		{"\u00e9\u0323", "e\u0323\u0301"},
+		{"\u1ec7", "e\u0323\u0302"},
+		{"\uac01", "\u1100\u1161\u11a8"},
+	}
+	for _, tc := range testCases {
+		if got := n.ucd.NFD(tc.text); got != tc.want {
+			t.Errorf("NFD(%+q): want %+q; got %+q", tc.text, tc.want, got)
+		}
+	}
+}
+
+// checkNormalizationTest checks n against the conformance tests in
+// NormalizationTest.txt format read from r, returning the code
+// points listed in part 1, which tests single characters.
+func checkNormalizationTest(t *testing.T, n *Normalizer, r io.Reader) map[rune]bool {
+	t.Helper()
+	part1 := map[rune]bool{}
+	part := ""
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if strings.HasPrefix(line, "@") {
+			part = strings.TrimSpace(line)
+			continue
+		}
+		fields := strings.Split(line, ";")
+		if len(fields) < 5 {
+			continue
+		}
+		c := make([]string, 6)
+		for i, field := range fields[:5] {
+			chars, err := parseCodePoints(field)
+			if err != nil {
+				t.Fatalf("line %d: %v", lineNum, err)
+			}
+			c[i+1] = string(chars)
+		}
+		if part == "@Part1" {
+			part1[[]rune(c[1])[0]] = true
+		}
+		// The invariants stated in NormalizationTest.txt.
+		checks := []struct {
+			form  string
+			want  int
+			cases []int
+		}{
+			{"NFC", 2, []int{1, 2, 3}}, {"NFC", 4, []int{4, 5}},
+			{"NFD", 3, []int{1, 2, 3}}, {"NFD", 5, []int{4, 5}},
+			{"NFKC", 4, []int{1, 2, 3, 4, 5}},
+			{"NFKD", 5, []int{1, 2, 3, 4, 5}},
+		}
+		for _, check := range checks {
+			for _, i := range check.cases {
+				if got, _ := n.Normalize(check.form, c[i]); got != c[check.want] {
+					t.Errorf("line %d: %s(c%d) = %+q; want c%d %+q",
+						lineNum, check.form, i, got, check.want, c[check.want])
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return part1
+}
+
+func TestNormalizer(t *testing.T) {
+	n := loadNormalizerSample(t)
+	checkNormalizationTest(t, n, strings.NewReader(normalizationSample))
+	if _, err := n.Normalize("NFX", "a"); err == nil {
+		t.Error("want error for unknown form")
+	}
+}
+
+// TestNormalizer_conformance runs the official conformance tests
+// when NormalizationTest.txt and the files it needs are in the UCD
+// directory.
+func TestNormalizer_conformance(t *testing.T) {
+	ucdPath, err := getUCDPath()
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := DirSource(filepath.Dir(ucdPath))
+	files := map[string]io.ReadCloser{}
+	for _, name := range []string{UCDFile, CompositionExclusionsFile, NormalizationTestFile} {
+		file, err := dir.Open(name)
+		if err != nil {
+			t.Skipf("%s not available: %v", name, err)
+		}
+		defer file.Close()
+		files[name] = file
+	}
+	ucd, err := LoadUCD(files[UCDFile], ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := NewNormalizer(ucd, files[CompositionExclusionsFile])
+	if err != nil {
+		t.Fatal(err)
+	}
+	part1 := checkNormalizationTest(t, n, files[NormalizationTestFile])
+	if testing.Short() {
+		return
+	}
+	// Characters not listed in part 1 are unchanged by all forms.
+	for char := rune(0); char <= 0x10FFFF; char++ {
+		if part1[char] || 0xD800 <= char && char <= 0xDFFF {
+			continue
+		}
+		s := string(char)
+		if n.NFC(s) != s || n.NFD(s) != s || n.NFKC(s) != s || n.NFKD(s) != s {
+			t.Errorf("U+%04X: changed by normalization", char)
+		}
+	}
+}
+
+func TestRun_normalize(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: normalizeUCD, CompositionExclusionsFile: exclusionsSample}
+	}
+	var testCases = []struct {
+		args   []string
+		stdin  string
+		status int
+		output string
+	}{
+		{[]string{"normalize", "e\u0323\u0302"}, "", exitMatch, "\u1ec7\n"},
+		{[]string{"normalize", "-form", "nfkd", "\ufb01"}, "", exitMatch, "fi\n"},
+		{[]string{"normalize", "-form", "NFD"}, "\u00e9\n\u212b\n", exitMatch, "e\u0301\nA\u030a\n"},
+		{[]string{"normalize", "-form", "NFD", "-list", "\u00e9"}, "", exitMatch,
+			"U+0065\te\tLATIN SMALL LETTER E\n" +
+				"U+0301\t\u0301\tCOMBINING ACUTE ACCENT (NON-SPACING ACUTE)\n"},
+		{[]string{"normalize", "-form", "NFX", "a"}, "", exitUsage, ""},
+		{[]string{"search", "-d", "ACUTE"}, "", exitMatch,
+			"U+00E9\t\u00e9\tLATIN SMALL LETTER E WITH ACUTE (LATIN SMALL LETTER E ACUTE)\n\tNFD: U+0065 U+0301\n" +
+				"U+0301\t\u0301\tCOMBINING ACUTE ACCENT (NON-SPACING ACUTE)\n" +
+				"U+00B4\t\u00b4\tACUTE ACCENT (SPACING ACUTE)\n\tNFKD: U+0020 U+0301\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			var stdout, stderr strings.Builder
+			status := run(tc.args, strings.NewReader(tc.stdin), &stdout, &stderr)
+			if status != tc.status || stdout.String() != tc.output {
+				t.Errorf("\n\twant: %d %q\n\tgot:  %d %q\n\tstderr: %q",
+					tc.status, tc.output, status, stdout.String(), stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ProgressFunc wraps the body of a download of size bytes (-1 if
+// unknown) so reading it reports progress. label names the download.
+type ProgressFunc func(body io.Reader, size int64, label string) io.Reader
+
+// Intervals between progress reports.
+const (
+	liveInterval  = 200 * time.Millisecond
+	plainInterval = 5 * time.Second
+)
+
+// ProgressReader reports how much of a download has been read. On a
+// terminal it redraws a single line with the percentage, transfer
+// rate and estimated time left; elsewhere it writes a plain line at
+// every quarter of the download, or every few seconds if the size
+// is unknown, so logs stay readable.
+type ProgressReader struct {
+	r     io.Reader
+	w     io.Writer
+	label string
+	size  int64
+	read  int64
+	live  bool
+	now   func() time.Time
+
+	start    time.Time
+	reported time.Time
+	quarter  int64
+	finished bool
+}
+
+// NewProgressReader returns a ProgressReader reporting on w as r is
+// read. live selects the single-line display for terminals.
+func NewProgressReader(r io.Reader, size int64, label string, w io.Writer, live bool) *ProgressReader {
+	p := &ProgressReader{r: r, w: w, label: label, size: size, live: live, now: time.Now}
+	p.start = p.now()
+	p.reported = p.start
+	if !live {
+		fmt.Fprintf(w, "downloading %s (%s)\n", label, formatSize(size))
+	}
+	return p
+}
+
+func (p *ProgressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.read += int64(n)
+	now := p.now()
+	switch {
+	case err == io.EOF:
+		p.finish(now)
+	case p.live && now.Sub(p.reported) >= liveInterval:
+		p.reported = now
+		fmt.Fprintf(p.w, "\r%s\x1b[K", p.status(now))
+	case !p.live && p.size > 0 && p.read*4/p.size > p.quarter && p.read < p.size:
+		p.quarter = p.read * 4 / p.size
+		p.reported = now
+		fmt.Fprintf(p.w, "%s\n", p.status(now))
+	case !p.live && p.size <= 0 && now.Sub(p.reported) >= plainInterval:
+		p.reported = now
+		fmt.Fprintf(p.w, "%s\n", p.status(now))
+	}
+	return n, err
+}
+
+// finish writes the final report, once.
+func (p *ProgressReader) finish(now time.Time) {
+	if p.finished {
+		return
+	}
+	p.finished = true
+	elapsed := now.Sub(p.start)
+	line := fmt.Sprintf("%s: %s in %s (%s/s)", p.label, formatBytes(p.read),
+		elapsed.Round(100*time.Millisecond), formatBytes(rate(p.read, elapsed)))
+	if p.live {
+		fmt.Fprintf(p.w, "\r%s\x1b[K\n", line)
+	} else {
+		fmt.Fprintln(p.w, line)
+	}
+}
+
+// status describes the download so far.
+func (p *ProgressReader) status(now time.Time) string {
+	elapsed := now.Sub(p.start)
+	bps := rate(p.read, elapsed)
+	if p.size <= 0 {
+		return fmt.Sprintf("%s: %s, %s/s", p.label, formatBytes(p.read), formatBytes(bps))
+	}
+	eta := "?"
+	if bps > 0 {
+		eta = (time.Duration(p.size-p.read) * time.Second / time.Duration(bps)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("%s: %3d%% %s of %s, %s/s, ETA %s", p.label, p.read*100/p.size,
+		formatBytes(p.read), formatBytes(p.size), formatBytes(bps), eta)
+}
+
+// rate returns bytes per second.
+func rate(n int64, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(n) / elapsed.Seconds())
+}
+
+// formatBytes formats n with a binary unit, as in "1.8 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatSize is formatBytes for sizes that may be unknown.
+func formatSize(size int64) string {
+	if size < 0 {
+		return "size unknown"
+	}
+	return formatBytes(size)
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressTo returns a ProgressFunc reporting on w, drawing a live
+// line if w is a terminal.
+func progressTo(w io.Writer) ProgressFunc {
+	live := isTerminal(w)
+	return func(body io.Reader, size int64, label string) io.Reader {
+		return NewProgressReader(body, size, label, w, live)
+	}
+}
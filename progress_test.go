@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clockedReader reads chunk bytes at a time, advancing a fake clock
+// by tick on every read.
+type clockedReader struct {
+	r     io.Reader
+	chunk int
+	clock *time.Time
+	tick  time.Duration
+}
+
+func (c *clockedReader) Read(buf []byte) (int, error) {
+	*c.clock = c.clock.Add(c.tick)
+	if len(buf) > c.chunk {
+		buf = buf[:c.chunk]
+	}
+	return c.r.Read(buf)
+}
+
+// readWithProgress reads size bytes, 100 per second, through a
+// ProgressReader and returns what it reported.
+func readWithProgress(t *testing.T, size, announced int64, live bool) string {
+	t.Helper()
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	body := &clockedReader{strings.NewReader(strings.Repeat("x", int(size))), 100, &clock, time.Second}
+	p := NewProgressReader(body, announced, "UnicodeData.txt", &out, live)
+	p.now = func() time.Time { return clock }
+	p.start, p.reported = clock, clock
+	if _, err := io.Copy(io.Discard, p); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestProgressReader_plain(t *testing.T) {
+	got := readWithProgress(t, 1000, 1000, false)
+	want := "downloading UnicodeData.txt (1000 B)\n" +
+		"UnicodeData.txt:  30% 300 B of 1000 B, 100 B/s, ETA 7s\n" +
+		"UnicodeData.txt:  50% 500 B of 1000 B, 100 B/s, ETA 5s\n" +
+		"UnicodeData.txt:  80% 800 B of 1000 B, 100 B/s, ETA 2s\n" +
+		"UnicodeData.txt: 1000 B in 11s (90 B/s)\n"
+	if got != want {
+		t.Errorf("\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestProgressReader_live(t *testing.T) {
+	got := readWithProgress(t, 500, 500, true)
+	if strings.Count(got, "\r") != 6 || !strings.HasSuffix(got, "\n") {
+		t.Errorf("want 5 redraws and a final line; got %q", got)
+	}
+	if !strings.Contains(got, "\rUnicodeData.txt:  40% 200 B of 500 B, 100 B/s, ETA 3s\x1b[K") {
+		t.Errorf("missing status line in %q", got)
+	}
+}
+
+func TestProgressReader_unknownSize(t *testing.T) {
+	got := readWithProgress(t, 1000, -1, false)
+	if !strings.HasPrefix(got, "downloading UnicodeData.txt (size unknown)\n") ||
+		!strings.Contains(got, "UnicodeData.txt: 500 B, 100 B/s\n") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		0: "0 B", 1023: "1023 B", 1024: "1.0 KiB",
+		1887000: "1.8 MiB", 3 << 30: "3.0 GiB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d): want %q; got %q", n, want, got)
+		}
+	}
+}
+
+func TestHTTPSource_progress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(lines3Dto43))
+		}))
+	defer srv.Close()
+	var out bytes.Buffer
+	dir := t.TempDir()
+	src := &HTTPSource{
+		Mirrors:  []string{srv.URL},
+		Cache:    func(name string) string { return filepath.Join(dir, name) },
+		Progress: progressTo(&out),
+	}
+	readAll(t, src, UCDFile)
+	if !strings.Contains(out.String(), "downloading "+srv.URL+"/"+UCDFile) {
+		t.Errorf("no progress report in %q", out.String())
+	}
+}
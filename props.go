@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ScanProperties calls fn with each line of a UCD property file such
+// as CompositionExclusions.txt or DerivedAge.txt, where lines look
+// like
+//
+//	2190..21FF    ; Arrows   # comment
+//
+// first and last delimit the code point range, which may be a single
+// code point, and fields holds the other fields, trimmed. Comments
+// and blank lines are skipped. Errors are *ParseError values.
+func ScanProperties(r io.Reader, fn func(first, last rune, fields []string) error) error {
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		first, last, err := parseRange(fields[0])
+		if err != nil {
+			return &ParseError{Line: lineNum, Field: "code point range", Value: fields[0], Err: err}
+		}
+		if err := fn(first, last, fields[1:]); err != nil {
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) {
+				parseErr.Line = lineNum
+			}
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseRange parses a code point such as "00E9" or a range such as
+// "2190..21FF".
+func parseRange(field string) (rune, rune, error) {
+	firstHex, lastHex, isRange := strings.Cut(field, "..")
+	first, err := strconv.ParseInt(firstHex, 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return rune(first), rune(first), nil
+	}
+	last, err := strconv.ParseInt(lastHex, 16, 32)
+	if err == nil && last < first {
+		err = errors.New("range ends before it starts")
+	}
+	return rune(first), rune(last), err
+}
+
+// Data files with the block and script of each character.
+const (
+	BlocksFile  = "Blocks.txt"
+	ScriptsFile = "Scripts.txt"
+)
+
+// blockRange is a line of Blocks.txt. Blocks cover unassigned code
+// points too, so they are kept apart from the records.
+type blockRange struct {
+	first, last rune
+	name        string
+}
+
+// ReadBlocks sets the Block property of the records from Blocks.txt.
+func (u *UCD) ReadBlocks(r io.Reader) error {
+	return ScanProperties(r, func(first, last rune, fields []string) error {
+		if len(fields) == 0 {
+			return &ParseError{Field: "block", Err: errors.New("missing")}
+		}
+		u.blocks = append(u.blocks, blockRange{first, last, fields[0]})
+		u.update(first, last, func(rec *Record) { rec.Block = fields[0] })
+		return nil
+	})
+}
+
+// ReadScripts sets the Script property of the records from
+// Scripts.txt, which uses long names such as "Latin".
+func (u *UCD) ReadScripts(r io.Reader) error {
+	u.scripts = true
+	return ScanProperties(r, func(first, last rune, fields []string) error {
+		if len(fields) == 0 {
+			return &ParseError{Field: "script", Err: errors.New("missing")}
+		}
+		u.update(first, last, func(rec *Record) { rec.Script = fields[0] })
+		return nil
+	})
+}
+
+// Block returns the name of the block containing char, which may be
+// unassigned, or "No_Block".
+func (u *UCD) Block(char rune) string {
+	if rec, ok := u.Lookup(char); ok && rec.Block != "" {
+		return rec.Block
+	}
+	for _, block := range u.blocks {
+		if block.first <= char && char <= block.last {
+			return block.name
+		}
+	}
+	return "No_Block"
+}
+
+// Script returns the script of char, or "Unknown".
+func (u *UCD) Script(char rune) string {
+	if rec, ok := u.Lookup(char); ok && rec.Script != "" {
+		return rec.Script
+	}
+	return "Unknown"
+}
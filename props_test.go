@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScanProperties(t *testing.T) {
+	text := "\ufeff# Blocks\n\n0000..007F; Basic Latin\n00E9 ; x ; y # comment\n"
+	got := []string{}
+	err := ScanProperties(strings.NewReader(text), func(first, last rune, fields []string) error {
+		got = append(got, string([]rune{first, last})+"|"+strings.Join(fields, "|"))
+		return nil
+	})
+	want := []string{"\x00\x7f|Basic Latin", "éé|x|y"}
+	if err != nil || strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("want %q; got %q (error %v)", want, got, err)
+	}
+	err = ScanProperties(strings.NewReader("# header\n0080..007F; Bad\n"),
+		func(rune, rune, []string) error { return nil })
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2: code point range") {
+		t.Errorf("want error on line 2; got %v", err)
+	}
+}
+
+func TestUCD_ReadBlocks(t *testing.T) {
+	ucd, err := LoadUCD(strings.NewReader(analyzeUCD), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ucd.ReadBlocks(strings.NewReader(blocksSample)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ucd.ReadScripts(strings.NewReader(scriptsSample)); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		char          rune
+		block, script string
+	}{
+		{'A', "Basic Latin", "Latin"},
+		{'\u00e9', "Latin-1 Supplement", "Latin"},
+		{'\u0378', "Greek and Coptic", "Unknown"}, // unassigned
+		{'\ue010', "Private Use Area", "Unknown"}, // inside a range
+		{'\U0001F600', "No_Block", "Unknown"},
+	}
+	for _, tc := range testCases {
+		if block, script := ucd.Block(tc.char), ucd.Script(tc.char); block != tc.block || script != tc.script {
+			t.Errorf("%U: want %q, %q; got %q, %q", tc.char, tc.block, tc.script, block, script)
+		}
+	}
+	rec, _ := ucd.Lookup('\ue010')
+	if rec.Block != "Private Use Area" {
+		t.Errorf("want Block property set on range; got %q", rec.Block)
+	}
+}
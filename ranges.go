@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// RuneRange is a range of consecutive code points, from First to
+// Last inclusive.
+type RuneRange struct {
+	First, Last rune
+}
+
+// MergeRanges returns the code points of chars as the fewest ranges,
+// in order.
+func MergeRanges(chars []rune) []RuneRange {
+	sorted := append([]rune{}, chars...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	ranges := []RuneRange{}
+	for _, char := range sorted {
+		if n := len(ranges); n > 0 && char <= ranges[n-1].Last+1 {
+			ranges[n-1].Last = char
+			continue
+		}
+		ranges = append(ranges, RuneRange{char, char})
+	}
+	return ranges
+}
+
+// Regular expression syntaxes RegexClass writes.
+const (
+	flavorRE2    = "re2"
+	flavorPCRE   = "pcre"
+	flavorJS     = "js"
+	flavorPython = "python"
+)
+
+var regexFlavors = []string{flavorRE2, flavorPCRE, flavorJS, flavorPython}
+
+// RegexClass returns a character class matching the code points of
+// ranges in the regular expression syntax of flavor. Letters and
+// digits are written as they are, other code points escaped. The
+// JavaScript class is a regular expression literal with the u flag,
+// which its \u{...} escapes need.
+func RegexClass(ranges []RuneRange, flavor string) (string, error) {
+	var escape func(rune) string
+	switch flavor {
+	case flavorRE2, flavorPCRE:
+		escape = func(char rune) string { return fmt.Sprintf(`\x{%X}`, char) }
+	case flavorJS:
+		escape = func(char rune) string { return fmt.Sprintf(`\u{%X}`, char) }
+	case flavorPython:
+		escape = func(char rune) string {
+			if char > 0xFFFF {
+				return fmt.Sprintf(`\U%08X`, char)
+			}
+			return fmt.Sprintf(`\u%04X`, char)
+		}
+	default:
+		return "", fmt.Errorf("unknown regular expression flavor %q", flavor)
+	}
+	item := func(char rune) string {
+		if char < 0x80 && (unicode.IsLetter(char) || unicode.IsDigit(char)) {
+			return string(char)
+		}
+		return escape(char)
+	}
+	var class strings.Builder
+	class.WriteString("[")
+	for _, r := range ranges {
+		class.WriteString(item(r.First))
+		switch {
+		case r.Last == r.First+1:
+			class.WriteString(item(r.Last))
+		case r.Last > r.First:
+			class.WriteString("-" + item(r.Last))
+		}
+	}
+	class.WriteString("]")
+	if flavor == flavorJS {
+		return "/" + class.String() + "/u", nil
+	}
+	return class.String(), nil
+}
+
+// CSSUnicodeRange returns a CSS unicode-range descriptor for ranges,
+// as in "unicode-range: U+2190-21FF, U+2B05;".
+func CSSUnicodeRange(ranges []RuneRange) string {
+	items := make([]string, len(ranges))
+	for i, r := range ranges {
+		items[i] = fmt.Sprintf("U+%X", r.First)
+		if r.Last > r.First {
+			items[i] += fmt.Sprintf("-%X", r.Last)
+		}
+	}
+	return "unicode-range: " + strings.Join(items, ", ") + ";"
+}
+
+// strideRange is a range of a unicode.RangeTable: the code points
+// from lo to hi, stride apart.
+type strideRange struct {
+	lo, hi, stride rune
+}
+
+// strideRanges returns the code points of chars, which are sorted,
+// as the ranges of a unicode.RangeTable. Each takes as many code
+// points as it can that are the same distance apart, except that a
+// run of consecutive code points after a gap gets a range of its own.
+func strideRanges(chars []rune) []strideRange {
+	ranges := []strideRange{}
+	for i := 0; i < len(chars); {
+		r := strideRange{chars[i], chars[i], 1}
+		next := i + 1
+		if next < len(chars) {
+			stride := chars[next] - chars[i]
+			if stride == 1 || next+1 == len(chars) || chars[next+1]-chars[next] != 1 {
+				r.hi, r.stride = chars[next], stride
+				for next++; next < len(chars) && chars[next]-chars[next-1] == stride; next++ {
+					r.hi = chars[next]
+				}
+			}
+		}
+		ranges = append(ranges, r)
+		i = next
+	}
+	return ranges
+}
+
+// WriteRangeTable writes a gofmt-formatted Go file declaring a
+// unicode.RangeTable called name for the code points of chars, in
+// package pkg, with the header of generated files.
+func WriteRangeTable(w io.Writer, pkg, name, command string, chars []rune) error {
+	sorted := []rune{}
+	for _, r := range MergeRanges(chars) {
+		for char := r.First; char <= r.Last; char++ {
+			sorted = append(sorted, char)
+		}
+	}
+	split := sort.Search(len(sorted), func(i int) bool { return sorted[i] > 0xFFFF })
+	r16, r32 := strideRanges(sorted[:split]), strideRanges(sorted[split:])
+	latinOffset := 0
+	for _, r := range r16 {
+		if r.hi <= unicode.MaxLatin1 {
+			latinOffset++
+		}
+	}
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by %q; DO NOT EDIT.\n\n", command)
+	fmt.Fprintf(&src, "package %s\n\nimport \"unicode\"\n\n", pkg)
+	fmt.Fprintf(&src, "// %s holds the characters found by %q.\n", name, command)
+	fmt.Fprintf(&src, "var %s = &unicode.RangeTable{\n", name)
+	for _, table := range []struct {
+		field  string
+		ranges []strideRange
+	}{
+		{"R16: []unicode.Range16", r16}, {"R32: []unicode.Range32", r32},
+	} {
+		if len(table.ranges) == 0 {
+			continue
+		}
+		fmt.Fprintf(&src, "%s{\n", table.field)
+		for _, r := range table.ranges {
+			fmt.Fprintf(&src, "{0x%04x, 0x%04x, %d},\n", r.lo, r.hi, r.stride)
+		}
+		fmt.Fprintf(&src, "},\n")
+	}
+	if latinOffset > 0 {
+		fmt.Fprintf(&src, "LatinOffset: %d,\n", latinOffset)
+	}
+	fmt.Fprintf(&src, "}\n")
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
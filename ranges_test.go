@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeRanges(t *testing.T) {
+	got := MergeRanges([]rune{0x2193, 'a', 0x2190, 0x2191, 'b', 0x2192, 0x2B05, 'a'})
+	want := []RuneRange{{'a', 'b'}, {0x2190, 0x2193}, {0x2B05, 0x2B05}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\n\twant: %v\n\tgot:  %v", want, got)
+	}
+}
+
+func TestRegexClass(t *testing.T) {
+	ranges := []RuneRange{{'0', '9'}, {'-', '.'}, {0x2190, 0x21FF}, {0x1F600, 0x1F600}}
+	var testCases = []struct {
+		flavor string
+		want   string
+	}{
+		{flavorRE2, `[0-9\x{2D}\x{2E}\x{2190}-\x{21FF}\x{1F600}]`},
+		{flavorPCRE, `[0-9\x{2D}\x{2E}\x{2190}-\x{21FF}\x{1F600}]`},
+		{flavorJS, `/[0-9\u{2D}\u{2E}\u{2190}-\u{21FF}\u{1F600}]/u`},
+		{flavorPython, `[0-9\u002D\u002E\u2190-\u21FF\U0001F600]`},
+	}
+	for _, tc := range testCases {
+		got, err := RegexClass(ranges, tc.flavor)
+		if err != nil || got != tc.want {
+			t.Errorf("%s:\n\twant: %s\n\tgot:  %s %v", tc.flavor, tc.want, got, err)
+		}
+	}
+	if _, err := RegexClass(ranges, "posix"); err == nil {
+		t.Errorf("posix: want error")
+	}
+}
+
+func TestCSSUnicodeRange(t *testing.T) {
+	got := CSSUnicodeRange([]RuneRange{{0x2190, 0x21FF}, {0x2B05, 0x2B05}})
+	if want := "unicode-range: U+2190-21FF, U+2B05;"; got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+}
+
+func TestStrideRanges(t *testing.T) {
+	var testCases = []struct {
+		chars []rune
+		want  []strideRange
+	}{
+		{[]rune{1, 2, 3, 10}, []strideRange{{1, 3, 1}, {10, 10, 1}}},
+		{[]rune{1, 5, 6, 7}, []strideRange{{1, 1, 1}, {5, 7, 1}}},
+		{[]rune{'A', 'C', 'E', 'F'}, []strideRange{{'A', 'E', 2}, {'F', 'F', 1}}},
+		{[]rune{1, 3}, []strideRange{{1, 3, 2}}},
+		{[]rune{}, []strideRange{}},
+	}
+	for _, tc := range testCases {
+		if got := strideRanges(tc.chars); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v:\n\twant: %v\n\tgot:  %v", tc.chars, tc.want, got)
+		}
+	}
+}
+
+func TestWriteRangeTable(t *testing.T) {
+	var b strings.Builder
+	chars := []rune{0x1F600, 'E', 'A', 'C', 0x2190, 0x2191, 0x2192, 0x2193}
+	if err := WriteRangeTable(&b, "chars", "Arrows", "runescan gen rangetable arrow", chars); err != nil {
+		t.Fatal(err)
+	}
+	want := `// Code generated by "runescan gen rangetable arrow"; DO NOT EDIT.
+
+package chars
+
+import "unicode"
+
+// Arrows holds the characters found by "runescan gen rangetable arrow".
+var Arrows = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x0041, 0x0045, 2},
+		{0x2190, 0x2193, 1},
+	},
+	R32: []unicode.Range32{
+		{0x1f600, 0x1f600, 1},
+	},
+	LatinOffset: 1,
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("\n\twant: %q\n\tgot:  %q", want, got)
+	}
+}
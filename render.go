@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// Data files with the properties that set display width.
+const (
+	EastAsianWidthFile = "EastAsianWidth.txt"
+	EmojiDataFile      = "emoji/emoji-data.txt"
+)
+
+// Characters substituted for those that are unsafe or invisible on
+// a terminal.
+const (
+	dottedCircle = '\u25CC' // base for combining marks
+	dottedSquare = '\u2B1A' // format characters and line separators
+	replacement  = '\uFFFD' // unassigned, surrogate and C1 control code points
+	firstStrong  = '\u2068' // FIRST STRONG ISOLATE
+	popIsolate   = '\u2069' // POP DIRECTIONAL ISOLATE
+)
+
+// ReadEastAsianWidth sets the EastAsianWidth property of the records
+// from EastAsianWidth.txt.
+func (u *UCD) ReadEastAsianWidth(r io.Reader) error {
+	return ScanProperties(r, func(first, last rune, fields []string) error {
+		if len(fields) == 0 {
+			return &ParseError{Field: "width", Err: errors.New("missing")}
+		}
+		u.update(first, last, func(rec *Record) { rec.EastAsianWidth = fields[0] })
+		return nil
+	})
+}
+
+// ReadEmojiData sets the emoji properties of the records from
+// emoji-data.txt.
+func (u *UCD) ReadEmojiData(r io.Reader) error {
+	return ScanProperties(r, func(first, last rune, fields []string) error {
+		if len(fields) == 0 {
+			return &ParseError{Field: "property", Err: errors.New("missing")}
+		}
+		switch fields[0] {
+		case "Emoji":
+			u.update(first, last, func(rec *Record) { rec.Emoji = true })
+		case "Emoji_Presentation":
+			u.update(first, last, func(rec *Record) { rec.EmojiPresentation = true })
+		case "Extended_Pictographic":
+			u.update(first, last, func(rec *Record) { rec.ExtendedPictographic = true })
+		}
+		return nil
+	})
+}
+
+// Glyph returns a rendering of char that is safe to write to a
+// terminal, and its width in columns. Combining marks are shown on
+// a dotted circle, C0 controls as their Control Pictures, format
+// characters such as bidi overrides as a dotted square, and
+// right-to-left characters inside an isolate, so they cannot
+// reorder the rest of the line. Wide East Asian characters and
+// emoji are two columns wide.
+func (u *UCD) Glyph(char rune) (string, int) {
+	rec, ok := u.Lookup(char)
+	switch {
+	case !ok, rec.Category == "Cs", rec.Category == "Cn":
+		return string(replacement), 1
+	case char < 0x20:
+		return string(0x2400 + char), 1
+	case char == 0x7F:
+		return "\u2421", 1
+	case rec.Category == "Cc":
+		return string(replacement), 1
+	case rec.Category == "Cf", rec.Category == "Zl", rec.Category == "Zp":
+		return string(dottedSquare), 1
+	case rec.Category == "Mn", rec.Category == "Me", rec.Category == "Mc":
+		return string([]rune{dottedCircle, char}), 1
+	}
+	glyph, width := string(char), 1
+	if rec.EastAsianWidth == "W" || rec.EastAsianWidth == "F" || rec.EmojiPresentation {
+		width = 2
+	}
+	if rec.BidiClass == "R" || rec.BidiClass == "AL" {
+		glyph = string(firstStrong) + glyph + string(popIsolate)
+	}
+	return glyph, width
+}
+
+// Glyphs is Glyph for a string.
+func (u *UCD) Glyphs(s string) (string, int) {
+	var b strings.Builder
+	total := 0
+	for _, char := range s {
+		glyph, width := u.Glyph(char)
+		b.WriteString(glyph)
+		total += width
+	}
+	return b.String(), total
+}
+
+// RenderList writes results as List does, but with each character
+// rendered by Glyphs and the columns aligned with spaces, for
+// reading on a terminal.
+func (u *UCD) RenderList(w io.Writer, results [][3]string) error {
+	glyphs, widths := make([]string, len(results)), make([]int, len(results))
+	codeWidth, glyphWidth := 0, 0
+	for i, fields := range results {
+		glyphs[i], widths[i] = u.Glyphs(fields[1])
+		codeWidth = max(codeWidth, len(fields[0]))
+		glyphWidth = max(glyphWidth, widths[i])
+	}
+	for i, fields := range results {
+		_, err := fmt.Fprintf(w, "%-*s  %s%s  %s\n", codeWidth, fields[0],
+			glyphs[i], strings.Repeat(" ", glyphWidth-widths[i]), fields[2])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// has reports whether any record has a property, as those loaded
+// from the XML format have them all.
+func (u *UCD) has(property func(Record) bool) bool {
+	for _, rec := range u.Records {
+		if property(rec) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDisplayData adds the display properties to ucd, unless it has
+// them already. Files that cannot be had only cost alignment, so
+// they are reported and skipped.
+func (c *cli) loadDisplayData(ucd *UCD) error {
+	if ucd.has(func(rec Record) bool { return rec.EastAsianWidth != "" }) {
+		return nil
+	}
+	for _, data := range []struct {
+		name string
+		read func(io.Reader) error
+	}{
+		{EastAsianWidthFile, ucd.ReadEastAsianWidth},
+		{EmojiDataFile, ucd.ReadEmojiData},
+	} {
+		file, err := c.openFile(data.name)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrDownload) {
+			fmt.Fprintf(c.stderr, "runescan: %v; wide characters may be misaligned\n", err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = data.read(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", data.name, err)
+		}
+	}
+	return nil
+}
+
+// output writes results in the configured format. Text is rendered
+// by RenderList in safe mode, using ucd or the configured UCD file
+// if ucd is nil.
+func (c *cli) output(results [][3]string, ucd *UCD) error {
+	if c.cfg.Format == formatJSON || !c.safe {
+		return displayAs(c.stdout, c.cfg.Format, results)
+	}
+	if ucd == nil {
+		var err error
+		if ucd, err = c.loadUCD(); err != nil {
+			return err
+		}
+	}
+	if err := c.loadDisplayData(ucd); err != nil {
+		return err
+	}
+	return ucd.RenderList(c.stdout, results)
+}
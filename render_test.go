@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// renderUCD holds UnicodeData.txt lines for characters that need
+// care on a terminal.
+const renderUCD = `0000;<control>;Cc;0;BN;;;;;N;NULL;;;;
+0009;<control>;Cc;0;S;;;;;N;CHARACTER TABULATION;;;;
+0041;LATIN CAPITAL LETTER A;Lu;0;L;;;;;N;;;;0061;
+007F;<control>;Cc;0;BN;;;;;N;DELETE;;;;
+0085;<control>;Cc;0;B;;;;;N;NEXT LINE (NEL);;;;
+0301;COMBINING ACUTE ACCENT;Mn;230;NSM;;;;;N;NON-SPACING ACUTE;;;;
+05D0;HEBREW LETTER ALEF;Lo;0;R;;;;;N;;;;;
+0627;ARABIC LETTER ALEF;Lo;0;AL;;;;;N;;;;;
+2028;LINE SEPARATOR;Zl;0;WS;;;;;N;;;;;
+202E;RIGHT-TO-LEFT OVERRIDE;Cf;0;RLO;;;;;N;;;;;
+263A;WHITE SMILING FACE;So;0;ON;;;;;N;;;;;
+4E00;<CJK Ideograph, First>;Lo;0;L;;;;;N;;;;;
+9FFF;<CJK Ideograph, Last>;Lo;0;L;;;;;N;;;;;
+FF21;FULLWIDTH LATIN CAPITAL LETTER A;Lu;0;L;<wide> 0041;;;;N;;;;FF41;
+1F600;GRINNING FACE;So;0;ON;;;;;N;;;;;
+`
+
+const eastAsianWidthSample = `# EastAsianWidth.txt
+0000..001F     ; N  # Cc    [32] <control-0000>..<control-001F>
+0041           ; Na # Lu         LATIN CAPITAL LETTER A
+263A           ; N  # So         WHITE SMILING FACE
+4E00..9FFF     ; W  # Lo  [20992] CJK UNIFIED IDEOGRAPH-4E00..CJK UNIFIED IDEOGRAPH-9FFF
+FF21           ; F  # Lu         FULLWIDTH LATIN CAPITAL LETTER A
+`
+
+const emojiDataSample = `# emoji-data.txt
+263A          ; Emoji                # E0.6   [1] (☺)       smiling face
+1F600         ; Emoji                # E1.0   [1] (😀)       grinning face
+1F600         ; Emoji_Presentation   # E1.0   [1] (😀)       grinning face
+1F600         ; Extended_Pictographic# E1.0   [1] (😀)       grinning face
+`
+
+func loadRenderSample(t *testing.T) *UCD {
+	t.Helper()
+	ucd, err := LoadUCD(strings.NewReader(renderUCD), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ucd.ReadEastAsianWidth(strings.NewReader(eastAsianWidthSample)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ucd.ReadEmojiData(strings.NewReader(emojiDataSample)); err != nil {
+		t.Fatal(err)
+	}
+	return ucd
+}
+
+func TestUCD_Glyph(t *testing.T) {
+	ucd := loadRenderSample(t)
+	var testCases = []struct {
+		char  rune
+		glyph string
+		width int
+	}{
+		{'A', "A", 1},
+		{0x0000, "␀", 1},
+		{'\t', "␉", 1},
+		{0x007F, "␡", 1},
+		{0x0085, "�", 1},
+		{0x0301, "◌\u0301", 1},
+		{0x05D0, "\u2068א\u2069", 1},
+		{0x0627, "\u2068ا\u2069", 1},
+		{0x2028, "⬚", 1},
+		{0x202E, "⬚", 1},
+		{0x263A, "☺", 1},
+		{0x4E2D, "中", 2},
+		{0xFF21, "Ａ", 2},
+		{0x1F600, "\U0001f600", 2},
+		{0x0378, "�", 1},
+	}
+	for _, tc := range testCases {
+		glyph, width := ucd.Glyph(tc.char)
+		if glyph != tc.glyph || width != tc.width {
+			t.Errorf("Glyph(U+%04X): want %+q, %d; got %+q, %d",
+				tc.char, tc.glyph, tc.width, glyph, width)
+		}
+	}
+}
+
+func TestUCD_ReadEmojiData(t *testing.T) {
+	ucd := loadRenderSample(t)
+	rec, _ := ucd.Lookup(0x1F600)
+	if !rec.Emoji || !rec.EmojiPresentation || !rec.ExtendedPictographic {
+		t.Errorf("U+1F600: want all emoji properties; got %+v", rec)
+	}
+	rec, _ = ucd.Lookup(0x263A)
+	if !rec.Emoji || rec.EmojiPresentation {
+		t.Errorf("U+263A: want Emoji only; got %+v", rec)
+	}
+}
+
+func TestUCD_RenderList(t *testing.T) {
+	ucd := loadRenderSample(t)
+	var out bytes.Buffer
+	err := ucd.RenderList(&out, [][3]string{
+		{"U+0041", "A", "LATIN CAPITAL LETTER A"},
+		{"U+0301", "\u0301", "COMBINING ACUTE ACCENT"},
+		{"U+1F600", "\U0001f600", "GRINNING FACE"},
+		{"U+202E", "\u202e", "RIGHT-TO-LEFT OVERRIDE"},
+	})
+	want := "U+0041   A   LATIN CAPITAL LETTER A\n" +
+		"U+0301   ◌\u0301   COMBINING ACUTE ACCENT\n" +
+		"U+1F600  \U0001f600  GRINNING FACE\n" +
+		"U+202E   ⬚   RIGHT-TO-LEFT OVERRIDE\n"
+	if err != nil || out.String() != want {
+		t.Errorf("\nwant:\n%s\ngot:\n%s", want, out.String())
+	}
+}
+
+func TestRun_safe(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: renderUCD, EastAsianWidthFile: eastAsianWidthSample,
+			EmojiDataFile: emojiDataSample}
+	}
+	status, output, stderr := runArgs("search", "-safe", "face")
+	want := "U+263A   ☺   WHITE SMILING FACE\nU+1F600  \U0001f600  GRINNING FACE\n"
+	if status != exitMatch || output != want || stderr != "" {
+		t.Errorf("\n\twant: %q\n\tgot:  %d %q\n\tstderr: %q", want, status, output, stderr)
+	}
+
+	newSource = func(*Config, ProgressFunc) DataSource { return MemSource{UCDFile: renderUCD} }
+	status, output, stderr = runArgs("search", "-safe", "override")
+	if status != exitMatch || output != "U+202E  ⬚  RIGHT-TO-LEFT OVERRIDE\n" ||
+		!strings.Contains(stderr, EastAsianWidthFile) {
+		t.Errorf("without width data: got %d %q; stderr: %q", status, output, stderr)
+	}
+}
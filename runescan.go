@@ -1,54 +1,80 @@
 package main
 
 import (
-	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
-	"os/user"
-	"strconv"
+	"path/filepath"
+	"regexp"
 	"strings"
-	"time"
+	"unicode/utf8"
 
 	"github.com/standupdev/strset"
 )
 
 // ParseLine parses a line in the UnicodeData.txt file returning
 // the rune, the name and a set of words build from the name.
-func ParseLine(line string) (rune, string, strset.Set) {
-	fields := strings.Split(line, ";")
-	code, _ := strconv.ParseInt(fields[0], 16, 32)
-	name := fields[1]
-	wordStr := strings.Replace(fields[1], "-", " ", -1)
-	words := strset.MakeFromText(wordStr)
-	if fields[10] != "" {
-		name += fmt.Sprintf(" (%s)", fields[10])
-		wordStr = strings.Replace(fields[10], "-", " ", -1)
-		words.AddAll(strings.Fields(wordStr)...)
+func ParseLine(line string) (rune, string, strset.Set, error) {
+	rec, err := ParseRecord(line)
+	if err != nil {
+		return 0, "", strset.Make(), err
 	}
-	return rune(code), name, words
+	return rec.Char, rec.FullName(), rec.Words(), nil
 }
 
 // filter returns a list where each item is a [3]string with the
 // U+XXXX codepoint, the character (as a string) and the name of the
 // Unicode characters whose name cointains all words in the query.
-func filter(text io.Reader, query string) [][3]string {
+func filter(text io.Reader, query string) ([][3]string, error) {
+	return filterScan(text, ScanText, query)
+}
+
+// filterScan is filter for a UCD file in any format scan reads.
+func filterScan(text io.Reader, scan ScanFunc, query string) ([][3]string, error) {
 	result := [][3]string{}
+	terms := queryTerms(query)
+	err := scan(text, func(rec Record) error {
+		if terms.SubsetOf(rec.Words()) {
+			result = append(result, resultFields(rec))
+		}
+		return nil
+	})
+	return result, err
+}
+
+// queryTerms returns the words a name must contain to match query.
+func queryTerms(query string) strset.Set {
 	query = strings.Replace(query, "-", " ", -1)
-	terms := strset.MakeFromText(strings.ToUpper(query))
-	scanner := bufio.NewScanner(text)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			continue
+	return strset.MakeFromText(strings.ToUpper(query))
+}
+
+// resultFields returns the fields filter lists for rec.
+func resultFields(rec Record) [3]string {
+	return [3]string{fmt.Sprintf("U+%04X", rec.Char),
+		string(rec.Char), rec.FullName()}
+}
+
+// Filter is filter for records already loaded, followed by the
+// matching user aliases, if any were loaded. The query may also have
+// age terms, as parseQuery splits them off, which need the ages read
+// by ReadAges; an invalid one matches nothing.
+func (u *UCD) Filter(query string) [][3]string {
+	result := [][3]string{}
+	words, ages, err := parseQuery(query)
+	if err != nil {
+		return result
+	}
+	terms := queryTerms(words)
+	for _, rec := range u.Records {
+		if terms.SubsetOf(rec.Words()) && matchAges(ages, rec.Age) {
+			result = append(result, resultFields(rec))
 		}
-		char, name, nameWords := ParseLine(line)
-		if terms.SubsetOf(nameWords) {
-			result = append(result,
-				[3]string{fmt.Sprintf("U+%04X", char),
-					string(char), name})
+	}
+	for _, fields := range u.aliases.Filter(words) {
+		if matchAges(ages, u.TextAge(fields[1])) {
+			result = append(result, fields)
 		}
 	}
 	return result
@@ -56,51 +82,186 @@ func filter(text io.Reader, query string) [][3]string {
 
 // List displays the codepoint, the character and the name of the
 // Unicode characters whose name cointain all words in the query.
-func List(text io.Reader, query string) {
-	for _, fields := range filter(text, query) {
-		fmt.Printf("%s\t%s\t%s\n", fields[0], fields[1], fields[2])
+func List(text io.Reader, query string) error {
+	results, err := filter(text, query)
+	display(os.Stdout, results)
+	return err
+}
+
+func display(w io.Writer, results [][3]string) {
+	for _, fields := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", fields[0], fields[1], fields[2])
+	}
+}
+
+// codePoints formats the code points of s as in "U+0065 U+0301".
+func codePoints(s string) string {
+	codes := []string{}
+	for _, char := range s {
+		codes = append(codes, fmt.Sprintf("U+%04X", char))
 	}
+	return strings.Join(codes, " ")
 }
 
-func getUCDPath() string {
-	ucdPath := os.Getenv("UCD_PATH")
-	if ucdPath == "" { // ➊
-		user, err := user.Current()                 // ➋
-		failIf(err)                                 // ➌
-		ucdPath = user.HomeDir + "/UnicodeData.txt" // ➍
+// jsonResult is a result of filter as JSON output shows it.
+type jsonResult struct {
+	Code  string `json:"code"`
+	Char  string `json:"char"`
+	Name  string `json:"name"`
+	NFD   string `json:"nfd,omitempty"`
+	NFKD  string `json:"nfkd,omitempty"`
+	Upper string `json:"uppercase,omitempty"`
+	Lower string `json:"lowercase,omitempty"`
+	Title string `json:"titlecase,omitempty"`
+	Age   string `json:"age,omitempty"`
+}
+
+func newJSONResult(fields [3]string) jsonResult {
+	return jsonResult{Code: fields[0], Char: fields[1], Name: fields[2]}
+}
+
+// displayAs writes results in format: as display does for text, or
+// as a JSON array.
+func displayAs(w io.Writer, format string, results [][3]string) error {
+	if format != formatJSON {
+		display(w, results)
+		return nil
+	}
+	list := make([]jsonResult, len(results))
+	for i, fields := range results {
+		list[i] = newJSONResult(fields)
+	}
+	return writeJSON(w, list)
+}
+
+// details selects what displayDetails adds to each result.
+type details struct {
+	decompositions bool // full canonical and compatibility decompositions
+	casePartners   bool // simple upper, lower and title case mappings
+	ages           bool // the version of Unicode that added the characters
+}
+
+// displayDetails is displayAs for results shown with details of
+// their characters, which are looked up in u. Decompositions and
+// case mappings that leave the character unchanged are left out,
+// and so is the compatibility decomposition when it is the same as
+// the canonical one.
+func displayDetails(w io.Writer, format string, u *UCD, results [][3]string, show details) error {
+	list := detailedResults(u, results, show)
+	if format == formatJSON {
+		return writeJSON(w, list)
+	}
+	for _, result := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.Code, result.Char, result.Name)
+		for _, detail := range [][2]string{
+			{"NFD", result.NFD}, {"NFKD", result.NFKD},
+			{"uppercase", result.Upper}, {"lowercase", result.Lower}, {"titlecase", result.Title},
+			{"age", result.Age},
+		} {
+			if detail[1] != "" {
+				fmt.Fprintf(w, "\t%s: %s\n", detail[0], detail[1])
+			}
+		}
+	}
+	return nil
+}
+
+// detailedResults returns results as JSON output shows them, with
+// the details selected by show.
+func detailedResults(u *UCD, results [][3]string, show details) []jsonResult {
+	list := make([]jsonResult, len(results))
+	for i, fields := range results {
+		result := newJSONResult(fields)
+		if show.decompositions {
+			nfd, nfkd := u.NFD(fields[1]), u.NFKD(fields[1])
+			if nfd != fields[1] {
+				result.NFD = codePoints(nfd)
+			}
+			if nfkd != nfd {
+				result.NFKD = codePoints(nfkd)
+			}
+		}
+		if show.casePartners && utf8.RuneCountInString(fields[1]) == 1 {
+			rec, _ := u.Lookup([]rune(fields[1])[0])
+			result.Upper = mappingText(rec.Upper)
+			result.Lower = mappingText(rec.Lower)
+			if rec.Title != rec.Upper {
+				result.Title = mappingText(rec.Title)
+			}
+		}
+		if show.ages {
+			result.Age = u.TextAge(fields[1])
+		}
+		list[i] = result
 	}
-	return ucdPath
+	return list
 }
 
-func failIf(err error) {
+// mappingText formats a case mapping as in "U+0061 a", or returns ""
+// for a character that maps to itself.
+func mappingText(char rune) string {
+	if char == 0 {
+		return ""
+	}
+	return fmt.Sprintf("U+%04X %c", char, char)
+}
+
+// writeJSON writes results as an indented JSON array.
+func writeJSON(w io.Writer, list []jsonResult) error {
+	return encodeJSON(w, list)
+}
+
+// encodeJSON writes v as indented JSON, leaving characters such as
+// "<" unescaped.
+func encodeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// getUCDPath returns the path of the UCD file: UCD_PATH if set,
+// otherwise UnicodeData.txt in the data directory for the configured
+// Unicode version.
+func getUCDPath() (string, error) {
+	cfg, err := loadConfig(os.Getenv)
 	if err != nil {
-		log.Fatal(err.Error())
+		return "", err
 	}
+	return cfg.DataPath(), nil
 }
 
-func fetchUCD(url, path string, done chan<- bool) { // ➊
+func fetchUCD(url, path string) error { // ➊
 	response, err := http.Get(url)
-	failIf(err)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDownload, err)
+	}
 	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s: %s", ErrDownload, url, response.Status)
+	}
+	return saveUCD(response.Body, path) // ➋
+}
+
+// saveUCD copies a downloaded UCD file to path. A partial file is
+// removed.
+func saveUCD(body io.Reader, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
 	file, err := os.Create(path)
-	failIf(err)
-	defer file.Close()
-	_, err = io.Copy(file, response.Body)
-	failIf(err)
-	done <- true // ➋
-}
-
-func progress(done <-chan bool) { // ➊
-	for { // ➋
-		select { // ➌
-		case <-done: // ➍
-			fmt.Println()
-			return
-		default: // ➎
-			fmt.Print(".")
-			time.Sleep(150 * time.Millisecond)
-		}
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("%w: %v", ErrDownload, err)
 	}
+	return nil
 }
 
 // UCD_URL is the canonical URL for the Unicode Database.
@@ -108,24 +269,75 @@ func progress(done <-chan bool) { // ➊
 // https://standupdev.com/data/UnicodeData.txt
 const UCD_URL = "http://www.unicode.org/Public/UNIDATA/UnicodeData.txt"
 
-func openUCD(path string) (*os.File, error) {
-	ucd, err := os.Open(path)
-	if os.IsNotExist(err) { // ➊
-		fmt.Printf("%s not found\ndownloading %s\n", path, UCD_URL)
-		done := make(chan bool)          // ➊
-		go fetchUCD(UCD_URL, path, done) // ➋
-		progress(done)                   // ➌
-		ucd, err = os.Open(path)         // ➌
+// ucdMirrors are the base URLs UCD files are downloaded from,
+// in order of preference.
+var ucdMirrors = []string{
+	strings.TrimSuffix(UCD_URL, UCDFile),
+	"https://standupdev.com/data/",
+}
+
+// ucdSource returns the DataSource for the UCD file at path: the file
+// itself, then other UCD files in the same directory, then the
+// mirrors, saving downloads next to path and reporting on them with
+// progress, if not nil.
+func ucdSource(path string, mirrors []string, progress ProgressFunc) DataSource {
+	dir := filepath.Dir(path)
+	ucdName, _ := ucdFormat(path)
+	return LayeredSource{
+		FileSource{ucdName: path},
+		DirSource(dir),
+		&HTTPSource{
+			Mirrors:  mirrors,
+			Progress: progress,
+			Cache: func(name string) string {
+				if name == ucdName {
+					return path
+				}
+				return filepath.Join(dir, name)
+			},
+		},
 	}
-	return ucd, err // ➍
 }
 
-func main() {
-	ucd, err := openUCD(getUCDPath()) // ➊
+// newSource builds the DataSource commands read from. Tests replace
+// it to run against in-memory data.
+var newSource = func(cfg *Config, progress ProgressFunc) DataSource {
+	return ucdSource(cfg.DataPath(), cfg.MirrorURLs(), progress)
+}
+
+// readmeVersion finds the version in the ReadMe.txt of a UCD
+// directory, as in "for Version 15.1.0 of the Unicode Standard".
+var readmeVersion = regexp.MustCompile(`Version (\d+\.\d+\.\d+)`)
+
+// latestVersion finds the version "latest" stands for on mirrors.
+// Tests replace it, to stay offline.
+var latestVersion = readmeLatest
+
+// readmeLatest returns the version of Unicode the ReadMe.txt on the
+// mirrors is for, or "" if none of them says.
+func readmeLatest(mirrors []string) string {
+	file, err := (&HTTPSource{Mirrors: mirrors}).Open("ReadMe.txt")
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	text, err := io.ReadAll(io.LimitReader(file, 1<<16))
 	if err != nil {
-		log.Fatal(err.Error())
+		return ""
+	}
+	if match := readmeVersion.FindSubmatch(text); match != nil {
+		return string(match[1])
+	}
+	return ""
+}
+
+func openUCD(src DataSource, name string) (io.ReadCloser, error) {
+	return src.Open(name)
+}
+
+func main() {
+	status := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if status != exitMatch {
+		os.Exit(status)
 	}
-	defer ucd.Close()
-	query := strings.Join(os.Args[1:], " ")
-	List(ucd, query)
 }
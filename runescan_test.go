@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -16,7 +17,10 @@ import (
 const lineLetterA = "0041;LATIN CAPITAL LETTER A;Lu;0;L;;;;;N;;;;0061;"
 
 func TestParseLine(t *testing.T) {
-	rune, name, words := ParseLine(lineLetterA) // ➊
+	rune, name, words, err := ParseLine(lineLetterA) // ➊
+	if err != nil {
+		t.Fatal(err)
+	}
 	if rune != 'A' {
 		t.Errorf("Want: 'A'; got: %q", rune)
 	}
@@ -50,8 +54,8 @@ func TestParseLineWithHyphenAndField10(t *testing.T) {
 
 	for _, tc := range testCases { // ➌
 		t.Run("case "+string(tc.char), func(t *testing.T) {
-			char, name, words := ParseLine(tc.line) // ➍
-			if char != tc.char || name != tc.name ||
+			char, name, words, err := ParseLine(tc.line) // ➍
+			if err != nil || char != tc.char || name != tc.name ||
 				!words.Equal(tc.words) {
 				t.Errorf("\nParseLine(%q)\n-> (%q, %q, %q)", // ➎
 					tc.line, char, name, words)
@@ -90,8 +94,8 @@ func TestFilter(t *testing.T) {
 	for _, tc := range testCases { // ➌
 		t.Run(tc.query, func(t *testing.T) {
 			text := strings.NewReader(lines3Dto43)
-			got := filter(text, tc.query) // ➍
-			if !reflect.DeepEqual(tc.want, got) {
+			got, err := filter(text, tc.query) // ➍
+			if err != nil || !reflect.DeepEqual(tc.want, got) {
 				t.Errorf("query: %q\twant: %q\tgot: %q", // ➎
 					tc.query, tc.want, got)
 			}
@@ -105,7 +109,7 @@ func ExampleList() {
 	// Output: U+003F	?	QUESTION MARK
 }
 
-func ExampleList_2Results() {
+func ExampleList_twoResults() {
 	text := strings.NewReader(lines3Dto43)
 	List(text, "SIGN")
 	// Output:
@@ -113,7 +117,7 @@ func ExampleList_2Results() {
 	// U+003E	>	GREATER-THAN SIGN
 }
 
-func ExampleList_2Words() {
+func ExampleList_twoWords() {
 	text := strings.NewReader(lines3Dto43)
 	List(text, "CAPITAL LATIN")
 	// Output:
@@ -122,6 +126,40 @@ func ExampleList_2Words() {
 	// U+0043	C	LATIN CAPITAL LETTER C
 }
 
+// ucdSample holds the UnicodeData.txt lines the examples need.
+const ucdSample = lines3Dto43 + `0022;QUOTATION MARK;Po;0;ON;;;;;N;;;;;
+0027;APOSTROPHE;Po;0;ON;;;;;N;APOSTROPHE-QUOTE;;;;
+0060;GRAVE ACCENT;Sk;0;ON;;;;;N;SPACING GRAVE;;;;
+00B4;ACUTE ACCENT;Sk;0;ON;<compat> 0020 0301;;;;N;SPACING ACUTE;;;;
+20A2;CRUZEIRO SIGN;Sc;0;ET;;;;;N;;;;;
+2358;APL FUNCTIONAL SYMBOL QUOTE UNDERBAR;So;0;L;;;;;N;;;;;
+235E;APL FUNCTIONAL SYMBOL QUOTE QUAD;So;0;L;;;;;N;;;;;
+263A;WHITE SMILING FACE;So;0;ON;;;;;N;;;;;
+1F408;CAT;So;0;ON;;;;;N;;;;;
+1F431;CAT FACE;So;0;ON;;;;;N;;;;;
+1F600;GRINNING FACE;So;0;ON;;;;;N;;;;;
+1F601;GRINNING FACE WITH SMILING EYES;So;0;ON;;;;;N;;;;;
+1F638;GRINNING CAT FACE WITH SMILING EYES;So;0;ON;;;;;N;;;;;
+1F639;CAT FACE WITH TEARS OF JOY;So;0;ON;;;;;N;;;;;
+1F63A;SMILING CAT FACE WITH OPEN MOUTH;So;0;ON;;;;;N;;;;;
+1F63B;SMILING CAT FACE WITH HEART-SHAPED EYES;So;0;ON;;;;;N;;;;;
+1F63C;CAT FACE WITH WRY SMILE;So;0;ON;;;;;N;;;;;
+1F642;SLIGHTLY SMILING FACE;So;0;ON;;;;;N;;;;;
+`
+
+func TestMain(m *testing.M) {
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDFile: ucdSample}
+	}
+	latestVersion = func([]string) string { return "" }
+	// Keep the settings of whoever runs the tests out of them.
+	os.Setenv("RUNESCAN_CONFIG", os.DevNull)
+	for _, name := range []string{"RUNESCAN_UNICODE", "RUNESCAN_FORMAT", "RUNESCAN_MIRRORS", "RUNESCAN_ALIASES"} {
+		os.Unsetenv(name)
+	}
+	os.Exit(m.Run())
+}
+
 func Example() {
 	oldArgs := os.Args
 	defer func() { os.Args = oldArgs }()
@@ -131,7 +169,7 @@ func Example() {
 	// U+20A2	₢	CRUZEIRO SIGN
 }
 
-func Example_2WordQuery() { // ➊
+func Example_twoWordQuery() { // ➊
 	oldArgs := os.Args // ➋
 	defer func() { os.Args = oldArgs }()
 	os.Args = []string{"", "cat", "smiling"}
@@ -166,8 +204,8 @@ func TestGetUCDPath_isSet(t *testing.T) {
 	defer restore("UCD_PATH", pathBefore, existed)                            // ➋
 	ucdPath := fmt.Sprintf("./TEST%d-UnicodeData.txt", time.Now().UnixNano()) // ➌
 	os.Setenv("UCD_PATH", ucdPath)                                            // ➍
-	got := getUCDPath()                                                       // ➎
-	if got != ucdPath {
+	got, err := getUCDPath()                                                  // ➎
+	if err != nil || got != ucdPath {
 		t.Errorf("getUCDPath() [set]\nwant: %q; got: %q", ucdPath, got)
 	}
 }
@@ -177,15 +215,18 @@ func TestGetUCDPath_default(t *testing.T) {
 	defer restore("UCD_PATH", pathBefore, existed)
 	os.Unsetenv("UCD_PATH")             // ➊
 	ucdPathSuffix := "/UnicodeData.txt" // ➋
-	got := getUCDPath()
-	if !strings.HasSuffix(got, ucdPathSuffix) { // ➌
+	got, err := getUCDPath()
+	if err != nil || !strings.HasSuffix(got, ucdPathSuffix) { // ➌
 		t.Errorf("getUCDPath() [default]\nwant (sufixo): %q; got: %q", ucdPathSuffix, got)
 	}
 }
 
 func TestOpenUCD_local(t *testing.T) {
-	ucdPath := getUCDPath()
-	ucd, err := openUCD(ucdPath)
+	ucdPath := filepath.Join(t.TempDir(), "UnicodeData.txt")
+	if err := os.WriteFile(ucdPath, []byte(lines3Dto43), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ucd, err := openUCD(ucdSource(ucdPath, ucdMirrors, nil), UCDFile)
 	if err != nil {
 		t.Errorf("openUCD(%q):\n%v", ucdPath, err)
 	}
@@ -200,9 +241,9 @@ func TestFetchUCD(t *testing.T) {
 	defer srv.Close()
 
 	ucdPath := fmt.Sprintf("./TEST%d-UnicodeData.txt", time.Now().UnixNano())
-	done := make(chan bool)             // ➊
-	go fetchUCD(srv.URL, ucdPath, done) // ➋
-	_ = <-done                          // ➌
+	if err := fetchUCD(srv.URL, ucdPath); err != nil { // ➊
+		t.Fatalf("fetchUCD: %v", err)
+	}
 	ucd, err := os.Open(ucdPath)
 	if os.IsNotExist(err) {
 		t.Errorf("fetchUCD did not save:%v\n%v", ucdPath, err)
@@ -212,14 +253,22 @@ func TestFetchUCD(t *testing.T) {
 }
 
 func TestOpenUCD_remote(t *testing.T) {
-	if testing.Short() { // ➊
-		t.Skip("skipped test [-test.short option]") // ➋
-	}
-	ucdPath := fmt.Sprintf("./TEST%d-UnicodeData.txt", time.Now().UnixNano()) // ➌
-	ucd, err := openUCD(ucdPath)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(lines3Dto43))
+		}))
+	defer srv.Close()
+	mirrorsBefore := ucdMirrors
+	defer func() { ucdMirrors = mirrorsBefore }()
+	ucdMirrors = []string{srv.URL}
+
+	ucdPath := filepath.Join(t.TempDir(), fmt.Sprintf("TEST%d-UnicodeData.txt", time.Now().UnixNano()))
+	ucd, err := openUCD(ucdSource(ucdPath, ucdMirrors, nil), UCDFile)
 	if err != nil {
-		t.Errorf("openUCD(%q):\n%v", ucdPath, err)
+		t.Fatalf("openUCD(%q):\n%v", ucdPath, err)
 	}
 	ucd.Close()
-	os.Remove(ucdPath)
+	if _, err := os.Stat(ucdPath); err != nil {
+		t.Errorf("download not saved to %q: %v", ucdPath, err)
+	}
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultAddr is where serve listens unless told otherwise.
+const defaultAddr = "localhost:8080"
+
+var searchPage = template.Must(template.New("search").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>runescan</title></head>
+<body>
+  <form action="/" method="GET">
+    <input type="text" name="q" value="{{.Query}}" autofocus>
+    <input type="submit" value="Search">
+  </form>
+  {{- if .Query}}
+  <p>{{len .Results}} found</p>
+  <table>
+  {{- range .Results}}
+    <tr><td>{{index . 0}}</td><td>{{index . 1}}</td><td>{{index . 2}}</td></tr>
+  {{- end}}
+  </table>
+  {{- end}}
+</body></html>
+`))
+
+// newServer returns the handler for the search page, which answers
+// queries from ucd, loaded once at startup, and for PNG images of
+// glyphs at /glyph/U+XXXX.png, drawn by glyphs unless it is nil.
+func newServer(ucd *UCD, glyphs *GlyphRenderer) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/glyph/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/glyph/")
+		code, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, "U+"), ".png"), 16, 32)
+		if glyphs == nil || err != nil || !strings.HasPrefix(name, "U+") || !strings.HasSuffix(name, ".png") {
+			http.NotFound(w, r)
+			return
+		}
+		img, ok := glyphs.Glyph(rune(code))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := encodePNG(img)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(data)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		page := struct {
+			Query   string
+			Results [][3]string
+		}{Query: r.URL.Query().Get("q")}
+		if page.Query != "" {
+			page.Results = ucd.Filter(page.Query)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		searchPage.Execute(w, page)
+	})
+	return mux
+}
+
+func serveFlags(c *cli, flags *flag.FlagSet) {
+	flags.StringVar(&c.opts.addr, "addr", defaultAddr, "listen on `HOST:PORT`")
+	flags.StringVar(&c.opts.font, "font", "", "serve images of the glyphs of the TrueType or OpenType font in `FILE`")
+	flags.IntVar(&c.opts.size, "size", defaultGlyphSize, "draw glyphs `N` pixels per em")
+}
+
+func runServe(c *cli, cmd *command, args []string) error {
+	flags := c.flagSet(cmd)
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return &UsageError{cmd.name, "unexpected arguments"}
+	}
+	if err := checkGlyphSize(cmd, c.opts.size); err != nil {
+		return err
+	}
+	var glyphs *GlyphRenderer
+	if c.opts.font != "" {
+		f, err := c.loadFont(cmd, c.opts.font)
+		if err != nil {
+			return err
+		}
+		if glyphs, err = NewGlyphRenderer(f, c.opts.size); err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	ucd, err := c.loadSearchUCD()
+	if err != nil {
+		return err
+	}
+	if err := c.loadAgesIfAvailable(ucd); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "serving HTTP on http://%s/\n", c.opts.addr)
+	return http.ListenAndServe(c.opts.addr, newServer(ucd, glyphs))
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/standupdev/strset"
+)
+
+// Record holds the properties of one code point. The text loader
+// fills the fields found in UnicodeData.txt; the XML loader fills
+// those and also Age, Block, Script, EastAsianWidth and the emoji
+// properties, which the Read methods of UCD add from their own
+// files. Upper, Lower and Title are zero when the character maps to
+// itself.
+type Record struct {
+	Char           rune
+	Name           string // Unicode name, or "<control>" etc.
+	Category       string // General_Category, e.g. "Lu"
+	CombiningClass int
+	BidiClass      string
+	Decomposition  string // e.g. "<compat> 0020 0301"
+	Decimal        string
+	Digit          string
+	Numeric        string
+	Mirrored       bool
+	OldName        string // Unicode 1.0 name
+	Upper          rune
+	Lower          rune
+	Title          rune
+
+	Age                  string
+	Block                string
+	Script               string // "Latn" in the XML, "Latin" in Scripts.txt
+	EastAsianWidth       string
+	Emoji                bool
+	EmojiPresentation    bool
+	ExtendedPictographic bool
+}
+
+// FullName returns the name followed by the Unicode 1.0 name in
+// parentheses, when there is one, as List displays it.
+func (r Record) FullName() string {
+	if r.OldName != "" {
+		return fmt.Sprintf("%s (%s)", r.Name, r.OldName)
+	}
+	return r.Name
+}
+
+// Words returns the set of words in the name and old name, with
+// hyphenated words split apart.
+func (r Record) Words() strset.Set {
+	return strset.Make(r.WordList()...)
+}
+
+// WordList returns the words of Words in order of appearance.
+func (r Record) WordList() []string {
+	if r.IsRangeMarker() {
+		return nil // it names no character
+	}
+	words := strings.Fields(strings.Replace(r.Name, "-", " ", -1))
+	seen := map[string]bool{}
+	for _, word := range words {
+		seen[word] = true
+	}
+	for _, word := range strings.Fields(strings.Replace(r.OldName, "-", " ", -1)) {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// IsRangeMarker reports whether r is one of the "<..., First>" or
+// "<..., Last>" lines UnicodeData.txt uses to delimit ranges such
+// as the CJK ideographs, instead of an actual character.
+func (r Record) IsRangeMarker() bool {
+	return strings.HasPrefix(r.Name, "<") &&
+		(strings.HasSuffix(r.Name, ", First>") || strings.HasSuffix(r.Name, ", Last>"))
+}
+
+// ParseRecord parses all fields of a line in UnicodeData.txt.
+// Errors are *ParseError values with Line left as zero.
+func ParseRecord(line string) (Record, error) {
+	fields := strings.Split(line, ";")
+	if len(fields) < 11 {
+		return Record{}, &ParseError{Field: "line", Value: line,
+			Err: fmt.Errorf("%d fields, want at least 11", len(fields))}
+	}
+	for len(fields) < 15 { // trailing empty fields may be left out
+		fields = append(fields, "")
+	}
+	code, err := strconv.ParseInt(fields[0], 16, 32)
+	if err != nil {
+		return Record{}, &ParseError{Field: "code point", Value: fields[0], Err: err}
+	}
+	rec := Record{
+		Char:          rune(code),
+		Name:          fields[1],
+		Category:      fields[2],
+		BidiClass:     fields[4],
+		Decomposition: fields[5],
+		Decimal:       fields[6],
+		Digit:         fields[7],
+		Numeric:       fields[8],
+		Mirrored:      fields[9] == "Y",
+		OldName:       fields[10],
+	}
+	if fields[3] != "" {
+		if rec.CombiningClass, err = strconv.Atoi(fields[3]); err != nil {
+			return Record{}, &ParseError{Field: "combining class", Value: fields[3], Err: err}
+		}
+	}
+	mappings := []struct {
+		field string
+		value string
+		dest  *rune
+	}{
+		{"uppercase mapping", fields[12], &rec.Upper},
+		{"lowercase mapping", fields[13], &rec.Lower},
+		{"titlecase mapping", fields[14], &rec.Title},
+	}
+	for _, m := range mappings {
+		if *m.dest, err = parseMapping(rec.Char, m.value); err != nil {
+			return Record{}, &ParseError{Field: m.field, Value: m.value, Err: err}
+		}
+	}
+	if fields[14] == "" { // an empty titlecase field means "same as uppercase"
+		rec.Title = rec.Upper
+	}
+	return rec, nil
+}
+
+// parseMapping parses a hex case mapping, returning 0 when it is
+// empty or maps char to itself.
+func parseMapping(char rune, field string) (rune, error) {
+	if field == "" || field == "#" {
+		return 0, nil
+	}
+	code, err := strconv.ParseInt(field, 16, 32)
+	if err != nil || rune(code) == char {
+		return 0, err
+	}
+	return rune(code), nil
+}
+
+// ScanFunc reads the records in a UCD file, calling fn with each one.
+type ScanFunc func(r io.Reader, fn func(Record) error) error
+
+// ucdFormat returns the name of the UCD file at path and the
+// ScanFunc that reads it: the UAX #42 XML format for files ending
+// in .xml, the UnicodeData.txt format otherwise.
+func ucdFormat(path string) (string, ScanFunc) {
+	if strings.HasSuffix(path, ".xml") {
+		return UCDXMLFile, ScanXML
+	}
+	return UCDFile, ScanText
+}
+
+// ScanText calls fn with each record in UnicodeData.txt text,
+// stopping at the first error fn returns.
+func ScanText(text io.Reader, fn func(Record) error) error {
+	scanner := bufio.NewScanner(text)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		rec, err := ParseRecord(line)
+		if err != nil {
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) {
+				parseErr.Line = lineNum
+			}
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// LoadText reads all records in UnicodeData.txt text.
+func LoadText(text io.Reader) ([]Record, error) {
+	records := []Record{}
+	err := ScanText(text, func(rec Record) error {
+		records = append(records, rec)
+		return nil
+	})
+	return records, err
+}
+
+// UCD holds the records of a UCD file in file order, indexed by code
+// point for describing characters. Code points inside the ranges
+// UnicodeData.txt lists only by their first and last lines, such as
+// CJK ideographs and Hangul syllables, are described too.
+type UCD struct {
+	Records []Record
+	byChar  map[rune]int
+	ranges  []ucdRange
+	blocks  []blockRange // from Blocks.txt
+	scripts bool         // whether Scripts.txt was read
+	aliases Aliases      // from the user's aliases file
+}
+
+// ucdRange is a range delimited by "<..., First>" and "<..., Last>"
+// lines; first is the record of the opening line, or of the first
+// character of a part of the range, once update has split it.
+type ucdRange struct {
+	first Record
+	last  rune
+}
+
+// LoadUCD reads a UCD file with scan and indexes its records.
+func LoadUCD(r io.Reader, scan ScanFunc) (*UCD, error) {
+	ucd := &UCD{byChar: map[rune]int{}}
+	err := scan(r, func(rec Record) error {
+		if rec.IsRangeMarker() {
+			if strings.HasSuffix(rec.Name, ", First>") {
+				ucd.ranges = append(ucd.ranges, ucdRange{first: rec})
+			} else if n := len(ucd.ranges); n > 0 {
+				ucd.ranges[n-1].last = rec.Char
+			}
+			return nil
+		}
+		ucd.byChar[rec.Char] = len(ucd.Records)
+		ucd.Records = append(ucd.Records, rec)
+		return nil
+	})
+	return ucd, err
+}
+
+// Lookup returns the record for char. Characters inside ranges get
+// a copy of the range's properties under their derived name.
+func (u *UCD) Lookup(char rune) (Record, bool) {
+	if i, ok := u.byChar[char]; ok {
+		return u.Records[i], true
+	}
+	for _, rng := range u.ranges {
+		if rng.first.Char <= char && char <= rng.last {
+			rec := rng.first
+			rec.Char = char
+			rec.Name = rangeName(rng.first.Name, char)
+			return rec, true
+		}
+	}
+	return Record{}, false
+}
+
+// update calls fn with the records of the characters from first to
+// last, including the ranges among them. A range that is only partly
+// among them is split, so that fn changes just the characters from
+// first to last: DerivedAge.txt, for one, gives the CJK ideographs
+// several ages.
+func (u *UCD) update(first, last rune, fn func(*Record)) {
+	for char := first; char <= last; char++ {
+		if i, ok := u.byChar[char]; ok {
+			fn(&u.Records[i])
+		}
+	}
+	ranges := make([]ucdRange, 0, len(u.ranges))
+	for _, rng := range u.ranges {
+		if rng.last < first || last < rng.first.Char {
+			ranges = append(ranges, rng)
+			continue
+		}
+		if rng.first.Char < first {
+			ranges = append(ranges, ucdRange{rng.first, first - 1})
+			rng.first.Char = first
+		}
+		after := ucdRange{rng.first, rng.last}
+		after.first.Char = last + 1
+		rng.last = min(rng.last, last)
+		fn(&rng.first)
+		ranges = append(ranges, rng)
+		if after.first.Char <= after.last {
+			ranges = append(ranges, after)
+		}
+	}
+	u.ranges = ranges
+}
+
+// Name returns the name of char, or a code point label such as
+// "<unassigned-0378>" when the UCD has no record for it.
+func (u *UCD) Name(char rune) string {
+	if rec, ok := u.Lookup(char); ok {
+		return rec.FullName()
+	}
+	return fmt.Sprintf("<unassigned-%04X>", char)
+}
+
+// rangeName derives the name of char from the "<..., First>" name of
+// the range that contains it, following the rules of UAX #44 for
+// ideographs and Hangul syllables, and using code point labels for
+// private use and surrogate code points. Ranges read from UCD XML
+// files are named by a pattern, as in "<CJK UNIFIED IDEOGRAPH-#,
+// First>", where # stands for the code point.
+func rangeName(marker string, char rune) string {
+	kind := strings.TrimSuffix(strings.TrimPrefix(marker, "<"), ", First>")
+	switch {
+	case strings.Contains(kind, "#"):
+		return strings.Replace(kind, "#", fmt.Sprintf("%04X", char), 1)
+	case strings.HasPrefix(kind, "CJK Ideograph"):
+		return fmt.Sprintf("CJK UNIFIED IDEOGRAPH-%04X", char)
+	case strings.HasPrefix(kind, "Tangut Ideograph"):
+		return fmt.Sprintf("TANGUT IDEOGRAPH-%04X", char)
+	case kind == "Hangul Syllable":
+		return hangulName(char)
+	case strings.HasSuffix(kind, "Private Use"):
+		return fmt.Sprintf("<private-use-%04X>", char)
+	case strings.HasSuffix(kind, "Surrogate"):
+		return fmt.Sprintf("<surrogate-%04X>", char)
+	}
+	return fmt.Sprintf("<%s-%04X>", strings.ToLower(kind), char)
+}
+
+// Hangul syllable composition constants, from chapter 3 of the
+// Unicode Standard.
+const (
+	hangulBase  = 0xAC00
+	jamoLBase   = 0x1100
+	jamoVBase   = 0x1161
+	jamoTBase   = 0x11A7
+	jamoLCount  = 19
+	jamoVCount  = 21
+	jamoTCount  = 28
+	jamoNCount  = jamoVCount * jamoTCount
+	hangulCount = jamoLCount * jamoNCount
+)
+
+var (
+	jamoL = strings.Split("G GG N D DD R M B BB S SS  J JJ C K T P H", " ")
+	jamoV = strings.Split("A AE YA YAE EO E YEO YE O WA WAE OE YO U WEO WE WI YU EU YI I", " ")
+	jamoT = strings.Split(" G GG GS N NJ NH D L LG LM LB LS LT LP LH M B BS S SS NG J C K T P H", " ")
+)
+
+// hangulName returns the algorithmic name of a Hangul syllable.
+func hangulName(char rune) string {
+	s := int(char - hangulBase)
+	return "HANGUL SYLLABLE " + jamoL[s/jamoNCount] +
+		jamoV[(s%jamoNCount)/jamoTCount] + jamoT[s%jamoTCount]
+}
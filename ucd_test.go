@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRecord(t *testing.T) {
+	var testCases = []struct {
+		line string
+		want Record
+	}{
+		{lineLetterA, Record{Char: 'A', Name: "LATIN CAPITAL LETTER A",
+			Category: "Lu", BidiClass: "L", Lower: 'a'}},
+		{"0061;LATIN SMALL LETTER A;Ll;0;L;;;;;N;;;0041;;0041",
+			Record{Char: 'a', Name: "LATIN SMALL LETTER A",
+				Category: "Ll", BidiClass: "L", Upper: 'A', Title: 'A'}},
+		{"01C6;LATIN SMALL LETTER DZ WITH CARON;Ll;0;L;<compat> 0064 017E;;;;N;LATIN SMALL LETTER D Z HACEK;;01C4;;01C5",
+			Record{Char: 'ǆ', Name: "LATIN SMALL LETTER DZ WITH CARON",
+				Category: "Ll", BidiClass: "L", Decomposition: "<compat> 0064 017E",
+				OldName: "LATIN SMALL LETTER D Z HACEK", Upper: 'Ǆ', Title: 'ǅ'}},
+		{"0301;COMBINING ACUTE ACCENT;Mn;230;NSM;;;;;N;NON-SPACING ACUTE;;;;",
+			Record{Char: '́', Name: "COMBINING ACUTE ACCENT",
+				Category: "Mn", CombiningClass: 230, BidiClass: "NSM",
+				OldName: "NON-SPACING ACUTE"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.want.Name, func(t *testing.T) {
+			got, err := ParseRecord(tc.line)
+			if err != nil || !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("\n\twant: %+v\n\tgot:  %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadText(t *testing.T) {
+	records, err := LoadText(strings.NewReader(lines3Dto43))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 7 {
+		t.Fatalf("want 7 records; got %d", len(records))
+	}
+	if got := records[1].FullName(); got != "GREATER-THAN SIGN" {
+		t.Errorf("want: %q; got %q", "GREATER-THAN SIGN", got)
+	}
+	if !records[1].Mirrored {
+		t.Errorf("GREATER-THAN SIGN should be mirrored")
+	}
+}
+
+func TestUCD_Lookup(t *testing.T) {
+	text := "AC00;<Hangul Syllable, First>;Lo;0;L;;;;;N;;;;;\n" +
+		"D7A3;<Hangul Syllable, Last>;Lo;0;L;;;;;N;;;;;\n" +
+		"4E00;<CJK Ideograph, First>;Lo;0;L;;;;;N;;;;;\n" +
+		"9FFF;<CJK Ideograph, Last>;Lo;0;L;;;;;N;;;;;\n" +
+		"E000;<Private Use, First>;Co;0;L;;;;;N;;;;;\n" +
+		"F8FF;<Private Use, Last>;Co;0;L;;;;;N;;;;;\n"
+	ucd, err := LoadUCD(strings.NewReader(text), ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for char, want := range map[rune]string{
+		'한':    "HANGUL SYLLABLE HAN",
+		'각':    "HANGUL SYLLABLE GAG",
+		'中':    "CJK UNIFIED IDEOGRAPH-4E2D",
+		0xE001: "<private-use-E001>",
+		'A':    "<unassigned-0041>",
+	} {
+		if got := ucd.Name(char); got != want {
+			t.Errorf("Name(U+%04X): want %q; got %q", char, want, got)
+		}
+	}
+}
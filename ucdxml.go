@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// UCDXMLFile is the name of the flat UAX #42 XML file with every
+// property of every code point, except the Unihan ones.
+// ucd.all.flat.xml may be used instead; the Unihan properties are
+// ignored.
+const UCDXMLFile = "ucd.nounihan.flat.xml"
+
+// decompositionTags maps the dt attribute of the XML format to the
+// tag UnicodeData.txt puts in front of the decomposition mapping.
+var decompositionTags = map[string]string{
+	"com": "<compat>", "enc": "<circle>", "fin": "<final>",
+	"font": "<font>", "fra": "<fraction>", "init": "<initial>",
+	"iso": "<isolated>", "med": "<medial>", "nar": "<narrow>",
+	"nb": "<noBreak>", "sml": "<small>", "sqr": "<square>",
+	"sub": "<sub>", "sup": "<super>", "vert": "<vertical>",
+	"wide": "<wide>",
+}
+
+// ScanXML calls fn with a record for each <char> element in a UCD
+// XML file, stopping at the first error fn returns. The file is
+// decoded one element at a time, so memory use does not depend on
+// its size. Elements describing a range of code points, such as the
+// CJK ideographs, give two records, "<..., First>" and "<..., Last>",
+// as the lines of UnicodeData.txt delimiting a range do.
+func ScanXML(r io.Reader, fn func(Record) error) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		elem, ok := token.(xml.StartElement)
+		if !ok || elem.Name.Local != "char" {
+			continue
+		}
+		attrs := map[string]string{}
+		for _, attr := range elem.Attr {
+			attrs[attr.Name.Local] = attr.Value
+		}
+		if err := decoder.Skip(); err != nil { // name aliases etc.
+			return err
+		}
+		recs, err := xmlRecords(attrs)
+		if err != nil {
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) {
+				parseErr.Line, _ = decoder.InputPos()
+			}
+			return err
+		}
+		for _, rec := range recs {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// xmlRecords returns the records of a <char> element: one for a
+// single code point, or the two range markers for a range.
+func xmlRecords(attrs map[string]string) ([]Record, error) {
+	if attrs["cp"] != "" {
+		rec, err := xmlRecord(attrs)
+		return []Record{rec}, err
+	}
+	if attrs["first-cp"] == "" {
+		return nil, nil
+	}
+	last, err := strconv.ParseInt(attrs["last-cp"], 16, 32)
+	if err != nil {
+		return nil, &ParseError{Field: "last-cp", Value: attrs["last-cp"], Err: err}
+	}
+	kind := xmlRangeKind(attrs)
+	attrs["cp"], attrs["na"] = attrs["first-cp"], ""
+	first, err := xmlRecord(attrs)
+	if err != nil {
+		return nil, err
+	}
+	first.Name = "<" + kind + ", First>"
+	end := first
+	end.Char, end.Name = rune(last), "<"+kind+", Last>"
+	return []Record{first, end}, nil
+}
+
+// xmlRangeKind returns the kind of code points a range element
+// describes, for the names of its markers: the pattern of their
+// names, as in "CJK UNIFIED IDEOGRAPH-#", or for unnamed ones a
+// kind rangeName knows, such as "Private Use".
+func xmlRangeKind(attrs map[string]string) string {
+	switch {
+	case attrs["na"] != "":
+		return attrs["na"]
+	case attrs["gc"] == "Co":
+		return "Private Use"
+	case attrs["gc"] == "Cs":
+		return "Surrogate"
+	case attrs["blk"] == "Hangul":
+		return "Hangul Syllable"
+	}
+	return attrs["gc"]
+}
+
+// LoadXML reads all records in a UCD XML file, including range
+// markers.
+func LoadXML(r io.Reader) ([]Record, error) {
+	records := []Record{}
+	err := ScanXML(r, func(rec Record) error {
+		records = append(records, rec)
+		return nil
+	})
+	return records, err
+}
+
+// xmlRecord builds a Record from the attributes of a <char> element,
+// using the same conventions as ParseRecord. Errors are *ParseError
+// values naming the attribute, with Line left as zero.
+func xmlRecord(attrs map[string]string) (Record, error) {
+	code, err := strconv.ParseInt(attrs["cp"], 16, 32)
+	if err != nil {
+		return Record{}, &ParseError{Field: "cp", Value: attrs["cp"], Err: err}
+	}
+	char := rune(code)
+	rec := Record{
+		Char:                 char,
+		Name:                 strings.Replace(attrs["na"], "#", attrs["cp"], -1),
+		Category:             attrs["gc"],
+		BidiClass:            attrs["bc"],
+		Mirrored:             attrs["Bidi_M"] == "Y",
+		OldName:              attrs["na1"],
+		Age:                  attrs["age"],
+		Block:                attrs["blk"],
+		Script:               attrs["sc"],
+		EastAsianWidth:       attrs["ea"],
+		Emoji:                attrs["Emoji"] == "Y",
+		EmojiPresentation:    attrs["EPres"] == "Y",
+		ExtendedPictographic: attrs["ExtPict"] == "Y",
+	}
+	switch {
+	case rec.Name == "" && rec.Category == "Cc":
+		rec.Name = "<control>"
+	case rec.Name == "" && hangulBase <= char && char < hangulBase+hangulCount:
+		rec.Name = hangulName(char)
+	}
+	if ccc := attrs["ccc"]; ccc != "" {
+		if rec.CombiningClass, err = strconv.Atoi(ccc); err != nil {
+			return Record{}, &ParseError{Field: "ccc", Value: ccc, Err: err}
+		}
+	}
+	mappings := []struct {
+		attr string
+		dest *rune
+	}{
+		{"suc", &rec.Upper}, {"slc", &rec.Lower}, {"stc", &rec.Title},
+	}
+	for _, m := range mappings {
+		if *m.dest, err = parseMapping(char, attrs[m.attr]); err != nil {
+			return Record{}, &ParseError{Field: m.attr, Value: attrs[m.attr], Err: err}
+		}
+	}
+	if dm := attrs["dm"]; dm != "#" && dm != "" {
+		rec.Decomposition = dm
+		if tag, ok := decompositionTags[attrs["dt"]]; ok {
+			rec.Decomposition = tag + " " + dm
+		}
+	}
+	switch value := attrs["nv"]; attrs["nt"] {
+	case "De":
+		rec.Decimal, rec.Digit, rec.Numeric = value, value, value
+	case "Di":
+		rec.Digit, rec.Numeric = value, value
+	case "Nu":
+		rec.Numeric = value
+	}
+	return rec, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+const textSample = `0000;<control>;Cc;0;BN;;;;;N;NULL;;;;
+0027;APOSTROPHE;Po;0;ON;;;;;N;APOSTROPHE-QUOTE;;;;
+0031;DIGIT ONE;Nd;0;EN;;1;1;1;N;;;;;
+0041;LATIN CAPITAL LETTER A;Lu;0;L;;;;;N;;;;0061;
+0061;LATIN SMALL LETTER A;Ll;0;L;;;;;N;;;0041;;0041
+00B4;ACUTE ACCENT;Sk;0;ON;<compat> 0020 0301;;;;N;SPACING ACUTE;;;;
+00BD;VULGAR FRACTION ONE HALF;No;0;ON;<fraction> 0031 2044 0032;;;1/2;N;FRACTION ONE HALF;;;;
+00C9;LATIN CAPITAL LETTER E WITH ACUTE;Lu;0;L;0045 0301;;;;N;LATIN CAPITAL LETTER E ACUTE;;;00E9;
+01C5;LATIN CAPITAL LETTER D WITH SMALL LETTER Z WITH CARON;Lt;0;L;<compat> 0044 017E;;;;N;LATIN LETTER CAPITAL D SMALL Z HACEK;;01C4;01C6;01C5
+0301;COMBINING ACUTE ACCENT;Mn;230;NSM;;;;;N;NON-SPACING ACUTE;;;;
+3400;<CJK Ideograph Extension A, First>;Lo;0;L;;;;;N;;;;;
+4DBF;<CJK Ideograph Extension A, Last>;Lo;0;L;;;;;N;;;;;
+F900;CJK COMPATIBILITY IDEOGRAPH-F900;Lo;0;L;8C48;;;;N;;;;;
+1F600;GRINNING FACE;So;0;ON;;;;;N;;;;;
+`
+
+const xmlSample = `<?xml version="1.0" encoding="UTF-8"?>
+<ucd xmlns="http://www.unicode.org/ns/2003/ucd/1.0">
+<description>Unicode 15.1.0</description>
+<repertoire>
+<char cp="0000" age="1.1" na="" na1="NULL" gc="Cc" ccc="0" bc="BN" Bidi_M="N" dt="none" dm="#" nt="None" nv="NaN" suc="#" slc="#" stc="#" blk="ASCII" sc="Zyyy" ea="N" Emoji="N" EPres="N" ExtPict="N"><name-alias alias="NULL" type="control"/></char>
+<char cp="0027" age="1.1" na="APOSTROPHE" na1="APOSTROPHE-QUOTE" gc="Po" ccc="0" bc="ON" Bidi_M="N" dt="none" dm="#" nt="None" nv="NaN" suc="#" slc="#" stc="#" blk="ASCII" sc="Zyyy" ea="Na" Emoji="N" EPres="N" ExtPict="N"/>
+<char cp="0031" age="1.1" na="DIGIT ONE" na1="" gc="Nd" ccc="0" bc="EN" Bidi_M="N" dt="none" dm="#" nt="De" nv="1" suc="#" slc="#" stc="#" blk="ASCII" sc="Zyyy" ea="Na" Emoji="Y" EPres="N" ExtPict="N"/>
+<char cp="0041" age="1.1" na="LATIN CAPITAL LETTER A" na1="" gc="Lu" ccc="0" bc="L" Bidi_M="N" dt="none" dm="#" nt="None" nv="NaN" suc="#" slc="0061" stc="#" blk="ASCII" sc="Latn" ea="Na" Emoji="N" EPres="N" ExtPict="N"/>
+<char cp="0061" age="1.1" na="LATIN SMALL LETTER A" na1="" gc="Ll" ccc="0" bc="L" Bidi_M="N" dt="none" dm="#" nt="None" nv="NaN" suc="0041" slc="#" stc="0041" blk="ASCII" sc="Latn" ea="Na" Emoji="N" EPres="N" ExtPict="N"/>
+<char cp="00B4" age="1.1" na="ACUTE ACCENT" na1="SPACING ACUTE" gc="Sk" ccc="0" bc="ON" Bidi_M="N" dt="com" dm="0020 0301" nt="None" nv="NaN" suc="#" slc="#" stc="#" blk="Latin_1_Sup" sc="Zyyy" ea="A" Emoji="N" EPres="N" ExtPict="N"/>
+<char cp="00BD" age="1.1" na="VULGAR FRACTION ONE HALF" na1="FRACTION ONE HALF" gc="No" ccc="0" bc="ON" Bidi_M="N" dt="fra" dm="0031 2044 0032" nt="Nu" nv="1/2" suc="#" slc="#" stc="#" blk="Latin_1_Sup" sc="Zyyy" ea="A" Emoji="N" EPres="N" ExtPict="N"/>
+<char cp="00C9" age="1.1" na="LATIN CAPITAL LETTER E WITH ACUTE" na1="LATIN CAPITAL LETTER E ACUTE" gc="Lu" ccc="0" bc="L" Bidi_M="N" dt="can" dm="0045 0301" nt="None" nv="NaN" suc="#" slc="00E9" stc="#" blk="Latin_1_Sup" sc="Latn" ea="N" Emoji="N" EPres="N" ExtPict="N"/>
+<char cp="01C5" age="1.1" na="LATIN CAPITAL LETTER D WITH SMALL LETTER Z WITH CARON" na1="LATIN LETTER CAPITAL D SMALL Z HACEK" gc="Lt" ccc="0" bc="L" Bidi_M="N" dt="com" dm="0044 017E" nt="None" nv="NaN" suc="01C4" slc="01C6" stc="#" blk="Latin_Ext_B" sc="Latn" ea="N" Emoji="N" EPres="N" ExtPict="N"/>
+<char cp="0301" age="1.1" na="COMBINING ACUTE ACCENT" na1="NON-SPACING ACUTE" gc="Mn" ccc="230" bc="NSM" Bidi_M="N" dt="none" dm="#" nt="None" nv="NaN" suc="#" slc="#" stc="#" blk="Diacriticals" sc="Zinh" ea="A" Emoji="N" EPres="N" ExtPict="N"/>
+<reserved cp="0378" age="unassigned" na="" gc="Cn"/>
+<char first-cp="3400" last-cp="4DBF" age="3.0" na="CJK UNIFIED IDEOGRAPH-#" gc="Lo" ccc="0" bc="L" Bidi_M="N" dt="none" dm="#" nt="None" nv="NaN" suc="#" slc="#" stc="#" blk="CJK_Ext_A" sc="Hani" ea="W" Emoji="N" EPres="N" ExtPict="N"/>
+<char cp="F900" age="1.1" na="CJK COMPATIBILITY IDEOGRAPH-#" gc="Lo" ccc="0" bc="L" Bidi_M="N" dt="can" dm="8C48" nt="None" nv="NaN" suc="#" slc="#" stc="#" blk="CJK_Compat_Ideographs" sc="Hani" ea="W" Emoji="N" EPres="N" ExtPict="N"/>
+<char cp="1F600" age="6.1" na="GRINNING FACE" na1="" gc="So" ccc="0" bc="ON" Bidi_M="N" dt="none" dm="#" nt="None" nv="NaN" suc="#" slc="#" stc="#" blk="Emoticons" sc="Zyyy" ea="W" Emoji="Y" EPres="Y" ExtPict="Y"/>
+</repertoire>
+</ucd>
+`
+
+// unicodeDataFields returns rec without the properties that only
+// the XML format provides.
+func unicodeDataFields(rec Record) Record {
+	rec.Age, rec.Block, rec.Script, rec.EastAsianWidth = "", "", "", ""
+	rec.Emoji, rec.EmojiPresentation, rec.ExtendedPictographic = false, false, false
+	return rec
+}
+
+// compareLoaders checks that the text and XML files describe every
+// code point alike, whether they list it on its own or in a range.
+func compareLoaders(t *testing.T, text, xml io.Reader) {
+	t.Helper()
+	fromText, err := LoadUCD(text, ScanText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromXML, err := LoadUCD(xml, ScanXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for char := rune(0); char <= unicode.MaxRune; char++ {
+		want, inText := fromText.Lookup(char)
+		got, inXML := fromXML.Lookup(char)
+		if !inText && !inXML {
+			continue
+		}
+		got = unicodeDataFields(got)
+		// The XML numeric values include those derived from Unihan.
+		if want.Numeric == "" && strings.HasPrefix(want.Name, "CJK ") {
+			got.Numeric = ""
+		}
+		switch {
+		case inText && !inXML:
+			t.Errorf("U+%04X %s: missing from XML", char, want.Name)
+		case inXML && !inText:
+			t.Errorf("U+%04X %s: missing from text", char, got.Name)
+		case !reflect.DeepEqual(want, got):
+			t.Errorf("U+%04X\n\ttext: %+v\n\txml:  %+v", char, want, got)
+		}
+	}
+}
+
+func TestLoadXML_agreesWithText(t *testing.T) {
+	compareLoaders(t, strings.NewReader(textSample), strings.NewReader(xmlSample))
+}
+
+// TestLoadXML_fullData compares both loaders over the complete files
+// when they are both in the UCD directory.
+func TestLoadXML_fullData(t *testing.T) {
+	ucdPath, err := getUCDPath()
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := DirSource(filepath.Dir(ucdPath))
+	text, err := dir.Open(UCDFile)
+	if err != nil {
+		t.Skipf("%s not available: %v", UCDFile, err)
+	}
+	defer text.Close()
+	xml, err := dir.Open(UCDXMLFile)
+	if err != nil {
+		t.Skipf("%s not available: %v", UCDXMLFile, err)
+	}
+	defer xml.Close()
+	compareLoaders(t, text, xml)
+}
+
+func TestLoadXML_extraProperties(t *testing.T) {
+	records, err := LoadXML(strings.NewReader(xmlSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Record{Char: 0x1F600, Name: "GRINNING FACE", Category: "So",
+		BidiClass: "ON", Age: "6.1", Block: "Emoticons", Script: "Zyyy",
+		EastAsianWidth: "W", Emoji: true, EmojiPresentation: true,
+		ExtendedPictographic: true}
+	got := records[len(records)-1]
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("\n\twant: %+v\n\tgot:  %+v", want, got)
+	}
+}
+
+func TestFilterScan_XML(t *testing.T) {
+	for _, query := range []string{"ACUTE", "LATIN LETTER", "quote", "GRINNING", "IDEOGRAPH"} {
+		want, err := filter(strings.NewReader(textSample), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := filterScan(strings.NewReader(xmlSample), ScanXML, query)
+		if err != nil || !reflect.DeepEqual(want, got) {
+			t.Errorf("query: %q\n\ttext: %q\n\txml:  %q", query, want, got)
+		}
+	}
+}
+
+func TestRun_info_XMLRange(t *testing.T) {
+	sourceBefore := newSource
+	defer func() { newSource = sourceBefore }()
+	newSource = func(*Config, ProgressFunc) DataSource {
+		return MemSource{UCDXMLFile: xmlSample}
+	}
+	t.Setenv("UCD_PATH", filepath.Join(t.TempDir(), UCDXMLFile))
+	status, output, stderr := runArgs("info", "U+4DB5")
+	want := "U+4DB5\t\u4db5\tCJK UNIFIED IDEOGRAPH-4DB5\n\tcategory: Lo\n\tcombining class: 0\n\tbidi class: L\n"
+	if status != exitMatch || !strings.HasPrefix(output, want) {
+		t.Errorf("\n\twant: %d %q...\n\tgot:  %d %q\n\tstderr: %q", exitMatch, want, status, output, stderr)
+	}
+}
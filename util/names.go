@@ -1,34 +1,361 @@
+// Command names checks Go source files for Trojan Source attacks
+// (CVE-2021-42574) and other misleading characters. It reports bidi
+// control characters in comments and literals, identifiers that mix
+// scripts or contain characters confusable with ASCII, and lists the
+// non-ASCII identifiers with the names of their characters.
+//
+// Usage:
+//
+//	go run ./util [-ucd UnicodeData.txt] [-confusables confusables.txt] PATH...
+//
+// Each PATH is a Go file or a directory searched for Go files. The data
+// files default to those runescan downloads; run
+// "runescan fetch UnicodeData.txt confusables.txt" first. Non-ASCII
+// identifiers alone are not findings: the exit status is 1 only when
+// something else is reported, and 2 on errors.
 package main
 
 import (
-  "io/ioutil"
-  "go/scanner"
-  "go/token"
-  "log"
-  "os"
-  "fmt"
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 func main() {
-  src, err := ioutil.ReadFile(os.Args[1] + ".go")
-  if err != nil {
-    log.Fatal(err.Error())
-  }
-
-  // Initialize the scanner.
-  var s scanner.Scanner
-  fset := token.NewFileSet()                      // positions are relative to fset
-  file := fset.AddFile("", fset.Base(), len(src)) // register input "file"
-  s.Init(file, src, nil /* no error handler */, scanner.ScanComments)
-
-  // Repeated calls to Scan yield the token sequence found in the input.
-  for {
-      _, tok, lit := s.Scan()
-      if tok == token.EOF {
-          break
-      }
-      if tok == token.IDENT {
-        fmt.Println(lit)
-      }
-  }
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run checks the paths in args, returning the exit status.
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("names", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dataDir := defaultDataDir()
+	ucdPath := flags.String("ucd", filepath.Join(dataDir, "UnicodeData.txt"), "`path` of UnicodeData.txt")
+	confPath := flags.String("confusables", filepath.Join(dataDir, "confusables.txt"), "`path` of confusables.txt")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: names [-ucd PATH] [-confusables PATH] PATH...")
+		return 2
+	}
+	c := &checker{out: stdout}
+	if err := c.load(*ucdPath, *confPath); err != nil {
+		fmt.Fprintln(stderr, "names:", err)
+		return 2
+	}
+	for _, path := range flags.Args() {
+		if err := c.walk(path); err != nil {
+			fmt.Fprintln(stderr, "names:", err)
+			return 2
+		}
+	}
+	if c.findings > 0 {
+		return 1
+	}
+	return 0
+}
+
+// defaultDataDir returns the directory runescan downloads the data
+// files for the latest Unicode version to, or the directory of
+// UCD_PATH when set.
+func defaultDataDir() string {
+	if path := os.Getenv("UCD_PATH"); path != "" {
+		return filepath.Dir(path)
+	}
+	cache := os.Getenv("XDG_CACHE_HOME")
+	if cache == "" {
+		home, _ := os.UserHomeDir()
+		cache = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(cache, "runescan", "latest")
+}
+
+// checker holds the character data and counts the findings reported.
+type checker struct {
+	names      map[rune]string
+	ranges     []nameRange
+	prototypes map[rune]string // ASCII look-alikes of non-ASCII characters
+	out        io.Writer
+	findings   int
+}
+
+// nameRange is a range UnicodeData.txt lists by its first and last
+// lines, such as the CJK ideographs.
+type nameRange struct {
+	first, last rune
+	kind        string
+}
+
+// load reads the character names and the confusables.
+func (c *checker) load(ucdPath, confPath string) error {
+	c.names = map[rune]string{}
+	err := readFields(ucdPath, func(fields []string) error {
+		char, err := parseChar(fields[0])
+		if err != nil || len(fields) < 2 {
+			return fmt.Errorf("bad line: %q", strings.Join(fields, ";"))
+		}
+		name := fields[1]
+		switch {
+		case strings.HasSuffix(name, ", First>"):
+			kind := strings.TrimSuffix(strings.TrimPrefix(name, "<"), ", First>")
+			c.ranges = append(c.ranges, nameRange{char, char, kind})
+		case strings.HasSuffix(name, ", Last>") && len(c.ranges) > 0:
+			c.ranges[len(c.ranges)-1].last = char
+		default:
+			c.names[char] = name
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.prototypes = map[rune]string{}
+	return readFields(confPath, func(fields []string) error {
+		if len(fields) < 2 {
+			return fmt.Errorf("bad line: %q", strings.Join(fields, ";"))
+		}
+		source, err := parseChar(fields[0])
+		if err != nil {
+			return nil // a sequence, not a single character
+		}
+		prototype := ""
+		for _, code := range strings.Fields(fields[1]) {
+			char, err := parseChar(code)
+			if err != nil {
+				return err
+			}
+			prototype += string(char)
+		}
+		if source > unicode.MaxASCII && isASCII(prototype) {
+			c.prototypes[source] = prototype
+		}
+		return nil
+	})
+}
+
+// readFields calls fn with the semicolon-separated fields of each
+// line in the file at path, skipping comments and blank lines.
+func readFields(path string, fn func([]string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	lines := bufio.NewScanner(file)
+	for lineNum := 1; lines.Scan(); lineNum++ {
+		line := strings.TrimPrefix(lines.Text(), "\ufeff")
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if err := fn(fields); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+	}
+	return lines.Err()
+}
+
+func parseChar(code string) (rune, error) {
+	n, err := strconv.ParseUint(code, 16, 32)
+	return rune(n), err
+}
+
+func isASCII(s string) bool {
+	for _, char := range s {
+		if char > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// name returns the name of char, as in "U+0440 CYRILLIC SMALL LETTER ER".
+// Ideographs in ranges get their derived names; other characters in
+// ranges, such as Hangul syllables, only the name of the range.
+func (c *checker) name(char rune) string {
+	name, ok := c.names[char]
+	if !ok {
+		name = "<unassigned>"
+		for _, rng := range c.ranges {
+			if rng.first <= char && char <= rng.last {
+				name = "<" + rng.kind + ">"
+				if script := strings.Fields(rng.kind)[0]; strings.Contains(rng.kind, "Ideograph") {
+					if script == "CJK" {
+						script += " UNIFIED"
+					}
+					name = fmt.Sprintf("%s IDEOGRAPH-%04X", strings.ToUpper(script), char)
+				}
+			}
+		}
+	}
+	return fmt.Sprintf("U+%04X %s", char, name)
+}
+
+// walk checks path, or the Go files under it if it is a directory,
+// skipping those the go tool ignores. For compatibility with the old
+// usage, a path without the .go extension is tried with it. As
+// directories are searched recursively, a trailing "/..." is ignored.
+func (c *checker) walk(path string) error {
+	path = strings.TrimSuffix(path, "/...")
+	if path == "..." {
+		path = "."
+	}
+	if _, err := os.Stat(path); err != nil && !strings.HasSuffix(path, ".go") {
+		if _, err := os.Stat(path + ".go"); err == nil {
+			path += ".go"
+		}
+	}
+	return filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		base := entry.Name()
+		if entry.IsDir() {
+			if name != path && (base == "vendor" || base == "testdata" ||
+				strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if name != path && !strings.HasSuffix(base, ".go") {
+			return nil
+		}
+		return c.checkFile(name)
+	})
+}
+
+// checkFile reports on the tokens of the Go file at path.
+func (c *checker) checkFile(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	file := fset.AddFile(path, fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, func(pos token.Position, msg string) {
+		c.report(pos, "%s", msg)
+	}, scanner.ScanComments)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		switch tok {
+		case token.COMMENT, token.STRING, token.CHAR:
+			kind := "comment"
+			if tok != token.COMMENT {
+				kind = "literal"
+			}
+			// The scanner drops carriage returns from raw strings and
+			// comments, so the offsets of lit are not those of src.
+			offset := file.Offset(pos)
+			for _, char := range lit {
+				if unicode.Is(unicode.Bidi_Control, char) {
+					offset += bytes.IndexRune(src[offset:], char)
+					c.report(file.Position(file.Pos(offset)), "bidi control %s in %s", c.name(char), kind)
+					offset += utf8.RuneLen(char)
+				}
+			}
+		case token.IDENT:
+			c.checkIdent(file.Position(pos), lit)
+		}
+	}
+	return nil
+}
+
+// checkIdent reports identifiers that mix scripts or contain
+// characters confusable with ASCII, and lists the characters of other
+// non-ASCII identifiers.
+func (c *checker) checkIdent(pos token.Position, ident string) {
+	if isASCII(ident) {
+		return
+	}
+	if scripts := identScripts(ident); len(scripts) > 1 && !isCJK(scripts) {
+		c.report(pos, "identifier %s mixes scripts %s", ident, strings.Join(scripts, ", "))
+	}
+	for _, char := range ident {
+		if prototype, ok := c.prototypes[char]; ok {
+			c.report(pos, "identifier %s: %s is confusable with %q", ident, c.name(char), prototype)
+		}
+	}
+	fmt.Fprintf(c.out, "%s: non-ASCII identifier %s\n", pos, ident)
+	for _, char := range ident {
+		if char > unicode.MaxASCII {
+			fmt.Fprintf(c.out, "\t%s\n", c.name(char))
+		}
+	}
+}
+
+func (c *checker) report(pos token.Position, format string, args ...interface{}) {
+	c.findings++
+	fmt.Fprintf(c.out, "%s: %s\n", pos, fmt.Sprintf(format, args...))
+}
+
+// identScripts returns the sorted names of the scripts of the
+// characters in ident, leaving out Common and Inherited, which go
+// with any script.
+func identScripts(ident string) []string {
+	seen := map[string]bool{}
+	for _, char := range ident {
+		for name, table := range unicode.Scripts {
+			if name != "Common" && name != "Inherited" && unicode.Is(table, char) {
+				seen[name] = true
+				break
+			}
+		}
+	}
+	scripts := []string{}
+	for name := range seen {
+		scripts = append(scripts, name)
+	}
+	sort.Strings(scripts)
+	return scripts
+}
+
+// cjkScripts are the combinations of scripts UTS #39 allows in a
+// single identifier, as Japanese, Chinese and Korean text mixes them.
+var cjkScripts = [][]string{
+	{"Han", "Hiragana", "Katakana"},
+	{"Han", "Bopomofo"},
+	{"Han", "Hangul"},
+}
+
+// isCJK reports whether scripts are one of the cjkScripts combinations.
+func isCJK(scripts []string) bool {
+	for _, combination := range cjkScripts {
+		allowed := map[string]bool{}
+		for _, name := range combination {
+			allowed[name] = true
+		}
+		n := 0
+		for _, name := range scripts {
+			if allowed[name] {
+				n++
+			}
+		}
+		if n == len(scripts) {
+			return true
+		}
+	}
+	return false
 }
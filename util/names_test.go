@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const ucdSample = `0041;LATIN CAPITAL LETTER A;Lu;0;L;;;;;N;;;;0061;
+0061;LATIN SMALL LETTER A;Ll;0;L;;;;;N;;;0041;;0041
+00E7;LATIN SMALL LETTER C WITH CEDILLA;Ll;0;L;0063 0327;;;;N;LATIN SMALL LETTER C CEDILLA;;00C7;;00C7
+0430;CYRILLIC SMALL LETTER A;Ll;0;L;;;;;N;;;0410;;0410
+202E;RIGHT-TO-LEFT OVERRIDE;Cf;0;RLO;;;;;N;;;;;
+2066;LEFT-TO-RIGHT ISOLATE;Cf;0;LRI;;;;;N;;;;;
+2069;POP DIRECTIONAL ISOLATE;Cf;0;PDI;;;;;N;;;;;
+3042;HIRAGANA LETTER A;Lo;0;L;;;;;N;;;;;
+4E00;<CJK Ideograph, First>;Lo;0;L;;;;;N;;;;;
+9FFF;<CJK Ideograph, Last>;Lo;0;L;;;;;N;;;;;
+`
+
+const confusablesSample = "\ufeff# confusables.txt\n" +
+	"0430 ;\t0061 ;\tMA\t# CYRILLIC SMALL LETTER A \u2192 LATIN SMALL LETTER A\n"
+
+// goSample is a Go file with a finding on each of lines 3 to 6 and
+// a non-ASCII identifier on line 8.
+const goSample = "package sample\n\n" +
+	"var isAdmin = \"user\u202e \u2066// Check if admin\u2069 \u2066\"\n" +
+	"// comment \u202e\n" +
+	"var \u0430 = 1\n" +
+	"var c\u0430t = 2\n" +
+	"\n" +
+	"func la\u00e7o() { _ = \"\u4e00\u3042\" }\n"
+
+func writeFile(t *testing.T, dir, name, text string) string {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	ucd := writeFile(t, dir, "UnicodeData.txt", ucdSample)
+	conf := writeFile(t, dir, "confusables.txt", confusablesSample)
+	src := writeFile(t, dir, "src/sample.go", goSample)
+	writeFile(t, dir, "src/testdata/skipped.go", goSample)
+	writeFile(t, dir, "src/README", "\u202e")
+	clean := writeFile(t, dir, "clean/clean.go", "package clean\n\nfunc \u4e00\u3042() {}\n")
+
+	var stdout, stderr strings.Builder
+	status := run([]string{"-ucd", ucd, "-confusables", conf, filepath.Dir(src)}, &stdout, &stderr)
+	want := []string{
+		src + ":3:20: bidi control U+202E RIGHT-TO-LEFT OVERRIDE in literal",
+		src + ":3:24: bidi control U+2066 LEFT-TO-RIGHT ISOLATE in literal",
+		src + ":3:44: bidi control U+2069 POP DIRECTIONAL ISOLATE in literal",
+		src + ":3:48: bidi control U+2066 LEFT-TO-RIGHT ISOLATE in literal",
+		src + ":4:12: bidi control U+202E RIGHT-TO-LEFT OVERRIDE in comment",
+		src + ":5:5: identifier \u0430: U+0430 CYRILLIC SMALL LETTER A is confusable with \"a\"",
+		src + ":5:5: non-ASCII identifier \u0430",
+		"\tU+0430 CYRILLIC SMALL LETTER A",
+		src + ":6:5: identifier c\u0430t mixes scripts Cyrillic, Latin",
+		src + ":6:5: identifier c\u0430t: U+0430 CYRILLIC SMALL LETTER A is confusable with \"a\"",
+		src + ":6:5: non-ASCII identifier c\u0430t",
+		"\tU+0430 CYRILLIC SMALL LETTER A",
+		src + ":8:6: non-ASCII identifier la\u00e7o",
+		"\tU+00E7 LATIN SMALL LETTER C WITH CEDILLA",
+	}
+	if got := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want:\n%s\ngot:\n%s", strings.Join(want, "\n"), stdout.String())
+	}
+	if status != 1 || stderr.Len() != 0 {
+		t.Errorf("want status 1; got %d (stderr %q)", status, stderr.String())
+	}
+
+	stdout.Reset()
+	status = run([]string{"-ucd", ucd, "-confusables", conf, strings.TrimSuffix(clean, ".go")}, &stdout, &stderr)
+	want = []string{
+		clean + ":3:6: non-ASCII identifier \u4e00\u3042",
+		"\tU+4E00 CJK UNIFIED IDEOGRAPH-4E00",
+		"\tU+3042 HIRAGANA LETTER A",
+	}
+	if got := strings.TrimSuffix(stdout.String(), "\n"); got != strings.Join(want, "\n") || status != 0 {
+		t.Errorf("want status 0 and:\n%s\ngot %d and:\n%s", strings.Join(want, "\n"), status, got)
+	}
+
+	status = run([]string{"-ucd", filepath.Join(dir, "missing.txt"), src}, &stdout, &stderr)
+	if status != 2 || !strings.Contains(stderr.String(), "missing.txt") {
+		t.Errorf("want status 2 for missing data; got %d (stderr %q)", status, stderr.String())
+	}
+}
+
+func TestRun_crlf(t *testing.T) {
+	dir := t.TempDir()
+	ucd := writeFile(t, dir, "UnicodeData.txt", ucdSample)
+	conf := writeFile(t, dir, "confusables.txt", confusablesSample)
+	src := writeFile(t, dir, "crlf.go", "package crlf\r\n\r\n/* a\r\nb \u202e */\r\nvar s = `x\r\n\r\n\u2066`\r\n")
+
+	var stdout, stderr strings.Builder
+	status := run([]string{"-ucd", ucd, "-confusables", conf, src}, &stdout, &stderr)
+	want := src + ":4:3: bidi control U+202E RIGHT-TO-LEFT OVERRIDE in comment\n" +
+		src + ":7:1: bidi control U+2066 LEFT-TO-RIGHT ISOLATE in literal\n"
+	if stdout.String() != want || status != 1 {
+		t.Errorf("want status 1 and:\n%s\ngot %d and:\n%s(stderr %q)", want, status, stdout.String(), stderr.String())
+	}
+}